@@ -202,6 +202,29 @@ func CheckProof(root, mproof, index, value []byte) (bool, error) {
 		hindex, hvalue), nil
 }
 
+// PackProof appends the leaf value to a merkle proof. It is used for weighted
+// census, where the value of the leaf (the weight) is not known by the verifier.
+func PackProof(mproof, value []byte) []byte {
+	return append(append([]byte{}, mproof...), value...)
+}
+
+// UnpackProof splits a proof built with PackProof into the merkle proof and the
+// leaf value. Only existence proofs can be unpacked.
+func UnpackProof(packed []byte) (mproof, value []byte, err error) {
+	mp, err := merkletree.NewProofFromBytes(packed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !mp.Existence {
+		return nil, nil, fmt.Errorf("not an existence proof")
+	}
+	size := merkletree.ElemBytesLen * (len(mp.Siblings) + 1)
+	if len(packed)-size > MaxValueSize {
+		return nil, nil, fmt.Errorf("value len %d can not be bigger than %d", len(packed)-size, MaxValueSize)
+	}
+	return packed[:size], packed[size:], nil
+}
+
 // CheckProof validates a merkle proof and its data
 func (t *Tree) CheckProof(index, value, root, mproof []byte) (bool, error) {
 	t.updateAccessTime()
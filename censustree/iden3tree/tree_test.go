@@ -72,3 +72,36 @@ func TestClaimParsers(t *testing.T) {
 		t.Errorf("should return error to avoid overflow")
 	}
 }
+
+func TestPackProof(t *testing.T) {
+	tr, err := NewTree("test", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := tr.Add([]byte{0, 0, 0, byte(i)}, []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mproof, err := tr.GenProof([]byte{0, 0, 0, 5}, []byte{5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, v, err := UnpackProof(PackProof(mproof, []byte{5}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p, mproof) || !bytes.Equal(v, []byte{5}) {
+		t.Fatalf("unpacked proof does not match: %x %x", p, v)
+	}
+	valid, err := CheckProof(tr.Root(), p, []byte{0, 0, 0, 5}, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Errorf("unpacked proof is invalid")
+	}
+	if _, _, err := UnpackProof(mproof[:10]); err == nil {
+		t.Errorf("truncated proof unpacked")
+	}
+}
@@ -148,7 +148,7 @@ func (ph *VotingHandle) NewProcessTxArgs(ctx context.Context, pid [types.Process
 
 	// question count
 	qCount := uint32(processMeta.QuestionIndexQuestionCountMaxCountMaxValueMaxVoteOverwrites[1])
-	processData.QuestionIndex = &qCount
+	processData.QuestionCount = &qCount
 
 	// max count
 	processData.VoteOptions = &models.ProcessVoteOptions{
@@ -268,9 +268,22 @@ func (ph *VotingHandle) SetResultsTxArgs(ctx context.Context, pid [types.Process
 	return nil, fmt.Errorf("not implemented")
 }
 
-// IncrementQuestionIndexTxArgs
+// IncrementQuestionIndexTxArgs returns a SetProcess tx instance with the current question index of the process
 func (ph *VotingHandle) IncrementQuestionIndexTxArgs(ctx context.Context, pid [types.ProcessIDsize]byte) (*models.SetProcessTx, error) {
-	return nil, fmt.Errorf("not implemented")
+	processData, err := ph.VotingProcess.Get(&ethbind.CallOpts{Context: ctx}, pid)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching process from Ethereum: %w", err)
+	}
+	// create setProcessTx
+	setprocessTxArgs := new(models.SetProcessTx)
+	// process id
+	setprocessTxArgs.ProcessId = pid[:]
+	// process question index
+	qIndex := uint32(processData.QuestionIndexQuestionCountMaxCountMaxValueMaxVoteOverwrites[0])
+	setprocessTxArgs.QuestionIndex = &qIndex
+	setprocessTxArgs.Txtype = models.TxType_SET_PROCESS_QUESTION_INDEX
+
+	return setprocessTxArgs, nil
 }
 
 // SetNamespaceAddressTxArgs
@@ -146,6 +146,45 @@ func HandleVochainOracle(ctx context.Context, event *ethtypes.Log, e *EthereumEv
 		}
 		log.Infof("oracle transaction sent, hash: %x", res.Hash)
 
+	case ethereumEventList["processesQuestionIndexUpdated"]:
+		tctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		setProcessTx, err := processQuestionIndexUpdatedMeta(tctx, &e.ContractsABI[0], event.Data, e.VotingHandle)
+		if err != nil {
+			return fmt.Errorf("cannot obtain process data for creating the transaction: %w", err)
+		}
+		log.Infof("found process %x question index update on ethereum, new index is %d",
+			setProcessTx.ProcessId, setProcessTx.GetQuestionIndex())
+		p, err := e.VochainApp.State.Process(setProcessTx.ProcessId, true)
+		if err != nil {
+			return fmt.Errorf("cannot fetch the process from the Vochain: %w", err)
+		}
+		if p.GetQuestionIndex() >= setProcessTx.GetQuestionIndex() {
+			log.Infof("process question index already updated, skipping")
+			return nil
+		}
+		vtx := models.Tx{}
+		setQuestionTxBytes, err := proto.Marshal(setProcessTx)
+		if err != nil {
+			return fmt.Errorf("cannot marshal setProcess tx: %w", err)
+		}
+		vtx.Signature, err = e.Signer.Sign(setQuestionTxBytes)
+		if err != nil {
+			return fmt.Errorf("cannot sign oracle tx: %w", err)
+		}
+		vtx.Payload = &models.Tx_SetProcess{SetProcess: setProcessTx}
+		tx, err := proto.Marshal(&vtx)
+		if err != nil {
+			return fmt.Errorf("error marshaling process tx: %w", err)
+		}
+		log.Debugf("broadcasting tx: %s", log.FormatProto(setProcessTx))
+
+		res, err := e.VochainApp.SendTX(tx)
+		if err != nil || res == nil {
+			return fmt.Errorf("cannot broadcast tx: %w, res: %+v", err, res)
+		}
+		log.Infof("oracle transaction sent, hash: %x", res.Hash)
+
 	case ethereumEventList["processesCensusUpdated"]:
 		tctx, cancel := context.WithTimeout(ctx, time.Minute)
 		defer cancel()
@@ -228,3 +267,12 @@ func processCensusUpdatedMeta(ctx context.Context, contractABI *abi.ABI, eventDa
 	log.Debugf("processCensusUpdated eventData: %+v", structuredData)
 	return ph.SetCensusTxArgs(ctx, structuredData.ProcessId, structuredData.Namespace)
 }
+
+func processQuestionIndexUpdatedMeta(ctx context.Context, contractABI *abi.ABI, eventData []byte, ph *chain.VotingHandle) (*models.SetProcessTx, error) {
+	structuredData := &contracts.ProcessesQuestionIndexUpdated{}
+	if err := contractABI.UnpackIntoInterface(structuredData, "QuestionIndexUpdated", eventData); err != nil {
+		return nil, fmt.Errorf("cannot unpack QuestionIndexUpdated event: %w", err)
+	}
+	log.Debugf("processQuestionIndexUpdated eventData: %+v", structuredData)
+	return ph.IncrementQuestionIndexTxArgs(ctx, structuredData.ProcessId)
+}
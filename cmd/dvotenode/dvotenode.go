@@ -31,6 +31,7 @@ import (
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/keykeeper"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/txindexer"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
@@ -71,6 +72,7 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	globalCfg.API.Vote = *flag.Bool("voteApi", true, "enable the vote API")
 	globalCfg.API.Tendermint = *flag.Bool("tendermintApi", false, "make the Tendermint API public available")
 	globalCfg.API.Results = *flag.Bool("resultsApi", true, "enable the results API")
+	globalCfg.API.Indexer = *flag.Bool("indexerApi", false, "enable the transaction indexer API")
 	globalCfg.API.Route = *flag.String("apiRoute", "/", "dvote API base route for HTTP and Websockets")
 	globalCfg.API.AllowPrivate = *flag.Bool("apiAllowPrivate", false, "allows private methods over the APIs")
 	globalCfg.API.AllowedAddrs = *flag.String("apiAllowedAddrs", "", "comma delimited list of allowed client ETH addresses for private methods")
@@ -118,6 +120,19 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	globalCfg.VochainConfig.MempoolSize = *flag.Int("vochainMempoolSize", 20000, "vochain mempool size")
 	globalCfg.VochainConfig.KeyKeeperIndex = *flag.Int8("keyKeeperIndex", 0, "if this node is a key keeper, use this index slot")
 	globalCfg.VochainConfig.ImportPreviousCensus = *flag.Bool("importPreviousCensus", false, "if enabled the census downloader will import all existing census")
+	globalCfg.VochainConfig.StateBackend = *flag.String("vochainStateBackend", "iavl", "vochain state database backend (iavl or graviton)")
+	globalCfg.VochainConfig.Pruning = *flag.String("vochainPruning", "archive", "vochain state pruning strategy: archive (keep all), recent (keep the last versions) or every (keep every N versions and the last ones)")
+	globalCfg.VochainConfig.PruningKeepRecent = *flag.Uint64("vochainPruningKeepRecent", 100, "number of recent vochain state versions to keep when pruning")
+	globalCfg.VochainConfig.PruningKeepEvery = *flag.Uint64("vochainPruningKeepEvery", 10000, "keep every N vochain state versions with the every pruning strategy")
+	globalCfg.VochainConfig.RateLimitAddress = *flag.Uint32("vochainRateLimitAddress", 0, "maximum number of transactions accepted from the same address every rate limit window (0 disables it)")
+	globalCfg.VochainConfig.RateLimitProcess = *flag.Uint32("vochainRateLimitProcess", 0, "maximum number of transactions accepted for the same process every rate limit window (0 disables it)")
+	globalCfg.VochainConfig.RateLimitWindow = *flag.Duration("vochainRateLimitWindow", time.Minute, "period of time of the vochain transaction rate limits")
+	globalCfg.VochainConfig.SnapshotInterval = *flag.Int("vochainSnapshotInterval", 1000, "number of blocks between vochain state snapshots (0 disables them)")
+	globalCfg.VochainConfig.SnapshotKeepRecent = *flag.Int("vochainSnapshotKeepRecent", 2, "number of recent vochain state snapshots to keep")
+	globalCfg.VochainConfig.StateSync = *flag.Bool("vochainStateSync", false, "bootstrap the vochain state from a snapshot served by other peers")
+	globalCfg.VochainConfig.StateSyncRPCServers = *flag.StringArray("vochainStateSyncRPCServers", []string{}, "tendermint RPC servers used to verify the state sync snapshot (at least two)")
+	globalCfg.VochainConfig.StateSyncTrustHeight = *flag.Int64("vochainStateSyncTrustHeight", 0, "trusted block height for state sync")
+	globalCfg.VochainConfig.StateSyncTrustHash = *flag.String("vochainStateSyncTrustHash", "", "trusted block hash (of the trusted height) for state sync")
 	// metrics
 	globalCfg.Metrics.Enabled = *flag.Bool("metricsEnabled", false, "enable prometheus metrics")
 	globalCfg.Metrics.RefreshInterval = *flag.Int("metricsRefreshInterval", 5, "metrics refresh interval in seconds")
@@ -166,6 +181,7 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.BindPFlag("api.Census", flag.Lookup("censusApi"))
 	viper.BindPFlag("api.Vote", flag.Lookup("voteApi"))
 	viper.BindPFlag("api.Results", flag.Lookup("resultsApi"))
+	viper.BindPFlag("api.Indexer", flag.Lookup("indexerApi"))
 	viper.BindPFlag("api.Tendermint", flag.Lookup("tendermintApi"))
 	viper.BindPFlag("api.Route", flag.Lookup("apiRoute"))
 	viper.BindPFlag("api.AllowPrivate", flag.Lookup("apiAllowPrivate"))
@@ -220,6 +236,19 @@ func newConfig() (*config.DvoteCfg, config.Error) {
 	viper.BindPFlag("vochainConfig.MempoolSize", flag.Lookup("vochainMempoolSize"))
 	viper.BindPFlag("vochainConfig.KeyKeeperIndex", flag.Lookup("keyKeeperIndex"))
 	viper.BindPFlag("vochainConfig.ImportPreviousCensus", flag.Lookup("importPreviousCensus"))
+	viper.BindPFlag("vochainConfig.StateBackend", flag.Lookup("vochainStateBackend"))
+	viper.BindPFlag("vochainConfig.Pruning", flag.Lookup("vochainPruning"))
+	viper.BindPFlag("vochainConfig.PruningKeepRecent", flag.Lookup("vochainPruningKeepRecent"))
+	viper.BindPFlag("vochainConfig.PruningKeepEvery", flag.Lookup("vochainPruningKeepEvery"))
+	viper.BindPFlag("vochainConfig.RateLimitAddress", flag.Lookup("vochainRateLimitAddress"))
+	viper.BindPFlag("vochainConfig.RateLimitProcess", flag.Lookup("vochainRateLimitProcess"))
+	viper.BindPFlag("vochainConfig.RateLimitWindow", flag.Lookup("vochainRateLimitWindow"))
+	viper.BindPFlag("vochainConfig.SnapshotInterval", flag.Lookup("vochainSnapshotInterval"))
+	viper.BindPFlag("vochainConfig.SnapshotKeepRecent", flag.Lookup("vochainSnapshotKeepRecent"))
+	viper.BindPFlag("vochainConfig.StateSync", flag.Lookup("vochainStateSync"))
+	viper.BindPFlag("vochainConfig.StateSyncRPCServers", flag.Lookup("vochainStateSyncRPCServers"))
+	viper.BindPFlag("vochainConfig.StateSyncTrustHeight", flag.Lookup("vochainStateSyncTrustHeight"))
+	viper.BindPFlag("vochainConfig.StateSyncTrustHash", flag.Lookup("vochainStateSyncTrustHash"))
 
 	// metrics
 	viper.BindPFlag("metrics.Enabled", flag.Lookup("metricsEnabled"))
@@ -381,6 +410,7 @@ func main() {
 	var vnode *vochain.BaseApplication
 	var vinfo *vochaininfo.VochainInfo
 	var sc *scrutinizer.Scrutinizer
+	var ti *txindexer.TxIndexer
 	var kk *keykeeper.KeyKeeper
 	var ma *metrics.Agent
 
@@ -459,13 +489,17 @@ func main() {
 	}
 	if (globalCfg.Mode == types.ModeGateway && globalCfg.API.Vote) || globalCfg.Mode == types.ModeMiner || globalCfg.Mode == types.ModeOracle {
 		scrutinizer := (globalCfg.Mode == types.ModeGateway && globalCfg.API.Results) || (globalCfg.Mode == types.ModeOracle)
-		vnode, sc, vinfo, err = service.Vochain(globalCfg.VochainConfig, scrutinizer, !globalCfg.VochainConfig.NoWaitSync, ma, cm)
+		indexer := globalCfg.Mode == types.ModeGateway && globalCfg.API.Indexer
+		vnode, sc, ti, vinfo, err = service.Vochain(globalCfg.VochainConfig, scrutinizer, indexer, !globalCfg.VochainConfig.NoWaitSync, ma, cm)
 		if err != nil {
 			log.Fatal(err)
 		}
 		defer func() {
 			vnode.Node.Stop()
 			vnode.Node.Wait()
+			if err := vnode.State.Close(); err != nil {
+				log.Warnf("cannot close vochain state: %v", err)
+			}
 		}()
 
 		if globalCfg.Mode == types.ModeGateway && globalCfg.API.Tendermint {
@@ -563,7 +597,7 @@ func main() {
 	if globalCfg.Mode == types.ModeGateway {
 		// dvote API service
 		if globalCfg.API.File || globalCfg.API.Census || globalCfg.API.Vote {
-			if err := service.API(globalCfg.API, pxy, storage, cm, vnode, sc, vinfo, globalCfg.VochainConfig.RPCListen, signer, ma); err != nil {
+			if err := service.API(globalCfg.API, pxy, storage, cm, vnode, sc, ti, vinfo, globalCfg.VochainConfig.RPCListen, signer, ma); err != nil {
 				log.Fatal(err)
 			}
 		}
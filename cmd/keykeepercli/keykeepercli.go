@@ -11,33 +11,43 @@ import (
 
 	flag "github.com/spf13/pflag"
 	"go.vocdoni.io/proto/build/go/models"
+	"golang.org/x/term"
 
 	"go.vocdoni.io/dvote/config"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
-	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/service"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain"
+	"go.vocdoni.io/dvote/vochain/keykeeper"
 )
 
 const (
-	commitmentKeySize = 32
-	MaxQuestions      = 64
-	MaxOptions        = 64
+	MaxQuestions = 64
+	MaxOptions   = 64
 )
 
-type processKeys struct {
-	pubKey        []byte
-	privKey       []byte
-	revealKey     []byte
-	commitmentKey []byte
-	index         int8
-}
-
 type ProcessVotes [][]uint32
 
+const usage = `Usage: keykeepercli [flags] <command>
+
+Commands:
+  results    compute the results of --pid using the keys of --oracles (default)
+  list       list the processes --keyKeeper holds keys for
+  republish  publish again the keys of --keyKeeper missing on the Vochain
+  reveal     reveal the keys of --keyKeeper for the ended or canceled processes
+  export     write an encrypted backup of the keykeeper database to --backup
+  import     restore the keykeeper database from the encrypted --backup
+
+The keykeeper commands use the same data directory as the node, which must be stopped.
+The backup password is read from the KEYKEEPER_BACKUP_PASSWORD environment variable,
+or asked on the terminal if it is not set.
+
+Flags:
+`
+
 func main() {
 	log.Init("info", "stdout")
 
@@ -50,6 +60,12 @@ func main() {
 	dataDir := flag.String("dataDir", fmt.Sprintf("%s/.dvote", home), "datadir")
 	oracles := flag.String("oracles", "", "comma separated list of oracleKey:index")
 	pid := flag.String("pid", "", "process ID")
+	keyKeeper := flag.String("keyKeeper", "", "keykeeper signing key and index as oracleKey:index")
+	backupFile := flag.String("backup", "", "keykeeper backup file")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *dev {
@@ -64,41 +80,52 @@ func main() {
 		DataDir:     *dataDir + "/vochain",
 		LogLevel:    "error",
 	}
+	keyKeeperDir := vconfig.DataDir + "/keykeeper"
 
-	// Parse the oracle keys
-	var keys []*processKeys
-	log.Infof("importing oracle keys")
-	for _, o := range strings.Split(*oracles, ",") {
-		osp := strings.Split(o, ":")
-		if len(osp) != 2 {
-			log.Fatalf("oracle key malformed (%s)", o)
-		}
-		index, err := strconv.Atoi(osp[1])
-		if err != nil {
+	command := flag.Arg(0)
+	switch command {
+	case "", "results":
+		if err := results(&vconfig, keyKeeperDir, *pid, *oracles); err != nil {
 			log.Fatal(err)
 		}
-		signer := ethereum.NewSignKeys()
-		if err = signer.AddHexKey(osp[0]); err != nil {
+	case "list", "republish", "reveal":
+		if err := recovery(&vconfig, keyKeeperDir, *keyKeeper, command); err != nil {
 			log.Fatal(err)
 		}
-		pk, err := generateKeys(*pid, int8(index), signer)
-		if err != nil {
+	case "export", "import":
+		if err := backup(keyKeeperDir, *backupFile, command); err != nil {
 			log.Fatal(err)
 		}
-		keys = append(keys, pk)
-		log.Infof("process key: %x", pk.privKey)
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
+}
 
-	// Create Vochain service
-	vnode, _, _, err := service.Vochain(&vconfig, false, true, nil, nil)
+// parseOracleKey parses an oracleKey:index pair
+func parseOracleKey(o string) (*ethereum.SignKeys, int8, error) {
+	osp := strings.Split(o, ":")
+	if len(osp) != 2 {
+		return nil, 0, fmt.Errorf("oracle key malformed (%s)", o)
+	}
+	index, err := strconv.Atoi(osp[1])
 	if err != nil {
-		log.Fatal(err)
+		return nil, 0, err
+	}
+	signer := ethereum.NewSignKeys()
+	if err = signer.AddHexKey(osp[0]); err != nil {
+		return nil, 0, err
 	}
-	defer func() {
-		vnode.Node.Stop()
-		vnode.Node.Wait()
-	}()
+	return signer, int8(index), nil
+}
 
+// startVochain starts the Vochain node and waits until it is ready
+func startVochain(vconfig *config.VochainCfg) (*vochain.BaseApplication, error) {
+	// Create Vochain service
+	vnode, _, _, _, err := service.Vochain(vconfig, false, false, true, nil, nil)
+	if err != nil {
+		return nil, err
+	}
 	// Wait for Vochain to be ready
 	var h, hPrev int64
 	for vnode.Node == nil {
@@ -110,53 +137,224 @@ func main() {
 		log.Infof("[vochain info] replaying block %d at %d b/s",
 			h, (h-hPrev)/5)
 	}
-	pidb, err := hex.DecodeString(*pid)
+	return vnode, nil
+}
+
+// waitBlocks waits for the Vochain to commit n blocks, so the transactions
+// sent are included before stopping the node
+func waitBlocks(vnode *vochain.BaseApplication, n int64) {
+	header := vnode.State.Header(true)
+	if header == nil {
+		return
+	}
+	for h := header.Height; h < header.Height+n; {
+		time.Sleep(time.Second * 2)
+		if current := vnode.State.Header(true); current != nil {
+			h = current.Height
+		}
+	}
+}
+
+func stopVochain(vnode *vochain.BaseApplication) {
+	if err := vnode.Node.Stop(); err != nil {
+		log.Warn(err)
+	}
+	vnode.Node.Wait()
+}
+
+// results computes the results of a process decrypting the votes with the keys
+// derived from the oracle keys. The keys stored by previous keykeeper versions,
+// which cannot be derived, are read from the keykeeper database if it exists.
+func results(vconfig *config.VochainCfg, keyKeeperDir, pid, oracles string) error {
+	pidb, err := hex.DecodeString(pid)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	// Parse the oracle keys
+	type oracleKey struct {
+		signer *ethereum.SignKeys
+		index  int8
+	}
+	var keys []oracleKey
+	log.Infof("importing oracle keys")
+	for _, o := range strings.Split(oracles, ",") {
+		signer, index, err := parseOracleKey(o)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, oracleKey{signer, index})
+	}
+
+	var legacyKey []byte
+	var legacyIndex int8
+	if _, err := os.Stat(keyKeeperDir); err == nil {
+		storage, err := db.NewBadgerDB(keyKeeperDir)
+		if err != nil {
+			return err
+		}
+		legacyKey, legacyIndex, err = keykeeper.LegacyProcessKey(storage, pidb)
+		storage.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	vnode, err := startVochain(vconfig)
+	if err != nil {
+		return err
 	}
+	defer stopVochain(vnode)
 	process, err := vnode.State.Process(pidb, true)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	for _, k := range keys {
-		process.EncryptionPrivateKeys[k.index] = fmt.Sprintf("%x", k.privKey)
+		if legacyKey != nil && k.index == legacyIndex {
+			log.Infof("process key: %x (stored)", legacyKey)
+			process.EncryptionPrivateKeys[k.index] = fmt.Sprintf("%x", legacyKey)
+			continue
+		}
+		dealing, err := vnode.State.KeyDealing(pidb, uint32(k.index), true)
+		if err != nil {
+			return err
+		}
+		priv := keykeeper.DeriveKey(k.signer, pidb, k.index, dealing != nil)
+		log.Infof("process key: %x", priv)
+		process.EncryptionPrivateKeys[k.index] = fmt.Sprintf("%x", priv)
 	}
 
-	log.Infof("computing results for %s", *pid)
-	votes, err := computeNonLiveResults(*pid, process, vnode.State)
+	log.Infof("computing results for %s", pid)
+	votes, err := computeNonLiveResults(pid, process, vnode.State)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-
 	log.Infof("results: %v", votes)
+	return nil
 }
 
-func generateKeys(pid string, index int8, signer *ethereum.SignKeys) (*processKeys, error) {
-	// Generate keys
-	pb, err := hex.DecodeString(pid)
+// recovery runs the keykeeper recovery commands
+func recovery(vconfig *config.VochainCfg, keyKeeperDir, keyKeeper, command string) error {
+	signer, index, err := parseOracleKey(keyKeeper)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	vnode, err := startVochain(vconfig)
+	if err != nil {
+		return err
+	}
+	defer stopVochain(vnode)
+	kk, err := keykeeper.NewKeyKeeper(keyKeeperDir, vnode, signer, index)
+	if err != nil {
+		return err
+	}
+	kk.Rollback()
+
+	switch command {
+	case "list":
+		processes, err := kk.Processes()
+		if err != nil {
+			return err
+		}
+		for _, hp := range processes {
+			status := "unknown"
+			if p, err := vnode.State.Process(hp.ProcessID, true); err == nil {
+				status = p.Status.String()
+			}
+			fmt.Printf("%x status:%s legacy:%t revealHeight:%d\n", []byte(hp.ProcessID), status, hp.Legacy, hp.RevealHeight)
+		}
+		log.Infof("keykeeper %d holds keys for %d processes", index, len(processes))
+	case "republish":
+		published, err := kk.RepublishKeys()
+		if err != nil {
+			return err
+		}
+		log.Infof("published keys for %d processes", published)
+		waitBlocks(vnode, 2)
+	case "reveal":
+		kk.RevealUnpublished()
+		waitBlocks(vnode, 2)
+	}
+	return nil
+}
+
+// backupPasswordEnv is the environment variable holding the backup password.
+// The password is not a flag, so it does not end up on the shell history or
+// on the process list.
+const backupPasswordEnv = "KEYKEEPER_BACKUP_PASSWORD"
+
+// backupPassword returns the backup password from backupPasswordEnv or, if not
+// set, asks for it on the terminal. The new passwords are asked twice.
+func backupPassword(confirm bool) (string, error) {
+	if password := os.Getenv(backupPasswordEnv); password != "" {
+		return password, nil
 	}
-	// Add the index in order to win some extra entropy
-	pb = append(pb, byte(index))
-	// Private ed25519 key
-	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", ethereum.HashRaw(append(signer.Private.D.Bytes()[:], pb[:]...))))
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no backup password, set %s or run on a terminal", backupPasswordEnv)
+	}
+	read := func(prompt string) (string, error) {
+		fmt.Fprint(os.Stderr, prompt)
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	password, err := read("Backup password: ")
 	if err != nil {
-		return nil, fmt.Errorf("cannot generate encryption key: (%s)", err)
+		return "", fmt.Errorf("cannot read backup password: %w", err)
+	}
+	if password == "" {
+		return "", fmt.Errorf("empty backup password")
 	}
-	// Reveal and commitment keys
-	ckb := snarks.Poseidon.Hash(priv.Bytes())
-	ck := ckb[:commitmentKeySize]
-	ckhash := snarks.Poseidon.Hash(ckb)[:commitmentKeySize]
+	if confirm {
+		repeated, err := read("Repeat the backup password: ")
+		if err != nil {
+			return "", fmt.Errorf("cannot read backup password: %w", err)
+		}
+		if repeated != password {
+			return "", fmt.Errorf("backup passwords do not match")
+		}
+	}
+	return password, nil
+}
 
-	pk := &processKeys{
-		privKey:       priv.Bytes(),
-		pubKey:        priv.Public().Bytes(),
-		revealKey:     ck,
-		commitmentKey: ckhash,
-		index:         index,
+// backup exports or imports the encrypted backup of the keykeeper database
+func backup(keyKeeperDir, backupFile, command string) error {
+	if backupFile == "" {
+		return fmt.Errorf("missing backup file")
+	}
+	password, err := backupPassword(command == "export")
+	if err != nil {
+		return err
+	}
+	storage, err := db.NewBadgerDB(keyKeeperDir)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	var entries int
+	if command == "export" {
+		f, err := os.OpenFile(backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if entries, err = keykeeper.ExportBackup(storage, f, password); err != nil {
+			return err
+		}
+		log.Infof("exported %d keykeeper entries to %s", entries, backupFile)
+		return f.Close()
+	}
+	f, err := os.Open(backupFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if entries, err = keykeeper.ImportBackup(storage, f, password); err != nil {
+		return err
 	}
-	return pk, nil
+	log.Infof("imported %d keykeeper entries from %s", entries, backupFile)
+	return nil
 }
 
 func emptyProcess() ProcessVotes {
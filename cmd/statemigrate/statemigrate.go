@@ -0,0 +1,64 @@
+// statemigrate copies the Vochain state trees (app, process and vote) from a
+// state backend to another one and checks both hold the same content.
+//
+// The app hash depends on the backend, so a migrated state can only be used by
+// a node if all the nodes of the network switch to the same backend.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/vochain"
+)
+
+func main() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+	logLevel := flag.String("logLevel", "info", "log level (debug, info, warn, error, fatal)")
+	from := flag.String("from", fmt.Sprintf("%s/.dvote/vochain/data", home), "source state data directory")
+	fromBackend := flag.String("fromBackend", vochain.StateBackendIAVL, "source state backend (iavl or graviton)")
+	to := flag.String("to", "", "destination state data directory, it must be empty")
+	toBackend := flag.String("toBackend", vochain.StateBackendGraviton, "destination state backend (iavl or graviton)")
+	verifyOnly := flag.Bool("verifyOnly", false, "only check the source and destination states hold the same content")
+	flag.Parse()
+	log.Init(*logLevel, "stdout")
+
+	if *to == "" {
+		log.Fatal("the destination state data directory is required")
+	}
+	src, err := vochain.NewStateWithBackend(*from, *fromBackend)
+	if err != nil {
+		log.Fatalf("cannot open source state: %v", err)
+	}
+	dst, err := vochain.NewStateWithBackend(*to, *toBackend)
+	if err != nil {
+		log.Fatalf("cannot open destination state: %v", err)
+	}
+
+	if *verifyOnly {
+		if err := vochain.CompareState(src, dst); err != nil {
+			log.Fatalf("states differ: %v", err)
+		}
+		log.Infof("%s state at %s and %s state at %s hold the same content", *fromBackend, *from, *toBackend, *to)
+		return
+	}
+	log.Infof("migrating %s state at %s to %s state at %s", *fromBackend, *from, *toBackend, *to)
+	if err := vochain.MigrateState(src, dst); err != nil {
+		log.Fatalf("cannot migrate state: %v", err)
+	}
+	if h := dst.Header(true); h != nil {
+		log.Infof("migrated state at height %d", h.Height)
+	}
+	for _, s := range []*vochain.State{src, dst} {
+		if err := s.Store.Close(); err != nil {
+			log.Error(err)
+		}
+	}
+	log.Info("migration completed")
+}
@@ -1,6 +1,10 @@
 package config
 
-import "go.vocdoni.io/dvote/types"
+import (
+	"time"
+
+	"go.vocdoni.io/dvote/types"
+)
 
 // DvoteCfg stores global configs for dvote
 type DvoteCfg struct {
@@ -72,6 +76,7 @@ type API struct {
 	Tendermint bool
 	Vote       bool
 	Results    bool
+	Indexer    bool
 	// AllowPrivate allow to use private methods
 	AllowPrivate bool
 	// AllowedAddrs allowed addresses to interact with
@@ -194,6 +199,32 @@ type VochainCfg struct {
 	ImportPreviousCensus bool
 	// Enable Prometheus metrics from tendermint
 	TendermintMetrics bool
+	// StateBackend is the database used to store the state trees (iavl or graviton)
+	StateBackend string
+	// Pruning is the state pruning strategy (archive, recent or every)
+	Pruning string
+	// PruningKeepRecent is the number of recent state versions kept when pruning
+	PruningKeepRecent uint64
+	// PruningKeepEvery keeps every PruningKeepEvery state version with the every pruning strategy
+	PruningKeepEvery uint64
+	// RateLimitAddress is the maximum number of transactions accepted in the mempool from the same address every RateLimitWindow, zero disables it
+	RateLimitAddress uint32
+	// RateLimitProcess is the maximum number of transactions accepted in the mempool for the same process every RateLimitWindow, zero disables it
+	RateLimitProcess uint32
+	// RateLimitWindow is the period of time of the transaction rate limits
+	RateLimitWindow time.Duration
+	// SnapshotInterval is the number of blocks between state snapshots, zero disables them
+	SnapshotInterval int
+	// SnapshotKeepRecent is the number of recent state snapshots to keep on disk
+	SnapshotKeepRecent int
+	// StateSync if true the node will try to bootstrap its state from a snapshot served by other peers
+	StateSync bool
+	// StateSyncRPCServers are the Tendermint RPC servers used for light client verification on state sync
+	StateSyncRPCServers []string
+	// StateSyncTrustHeight is a trusted block height used for light client verification on state sync
+	StateSyncTrustHeight int64
+	// StateSyncTrustHash is the block hash of StateSyncTrustHeight
+	StateSyncTrustHash string
 }
 
 // OracleCfg includes all possible config params needed by the Oracle
@@ -0,0 +1,256 @@
+package snarks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/crypto/bn256"
+)
+
+// ProofSize is the size of a binary encoded Groth16 proof: A (G1), B (G2) and C (G1)
+const ProofSize = 64 + 128 + 64
+
+// FieldSize is the order of the BN254 scalar field, public inputs must be lower than it
+var FieldSize, _ = new(big.Int).SetString(
+	"21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
+
+// VerificationKey is a Groth16 verification key over the BN254 curve
+type VerificationKey struct {
+	Alpha *bn256.G1
+	Beta  *bn256.G2
+	Gamma *bn256.G2
+	Delta *bn256.G2
+	// IC has one element per public input plus one
+	IC []*bn256.G1
+}
+
+// Proof is a Groth16 proof over the BN254 curve
+type Proof struct {
+	A *bn256.G1
+	B *bn256.G2
+	C *bn256.G1
+}
+
+// vkJSON is the verification key format exported by snarkjs
+type vkJSON struct {
+	Protocol string     `json:"protocol"`
+	NPublic  int        `json:"nPublic"`
+	Alpha    []string   `json:"vk_alpha_1"`
+	Beta     [][]string `json:"vk_beta_2"`
+	Gamma    [][]string `json:"vk_gamma_2"`
+	Delta    [][]string `json:"vk_delta_2"`
+	IC       [][]string `json:"IC"`
+}
+
+// proofJSON is the proof format exported by snarkjs
+type proofJSON struct {
+	A []string   `json:"pi_a"`
+	B [][]string `json:"pi_b"`
+	C []string   `json:"pi_c"`
+}
+
+// NumPublicInputs returns the number of public inputs expected by the verification key
+func (vk *VerificationKey) NumPublicInputs() int {
+	return len(vk.IC) - 1
+}
+
+// MarshalJSON encodes the verification key using the snarkjs format
+func (vk *VerificationKey) MarshalJSON() ([]byte, error) {
+	j := vkJSON{
+		Protocol: "groth16",
+		NPublic:  vk.NumPublicInputs(),
+		Alpha:    g1ToStrings(vk.Alpha),
+		Beta:     g2ToStrings(vk.Beta),
+		Gamma:    g2ToStrings(vk.Gamma),
+		Delta:    g2ToStrings(vk.Delta),
+	}
+	for _, ic := range vk.IC {
+		j.IC = append(j.IC, g1ToStrings(ic))
+	}
+	return json.Marshal(j)
+}
+
+// UnmarshalJSON decodes a verification key in the snarkjs format
+func (vk *VerificationKey) UnmarshalJSON(data []byte) error {
+	var j vkJSON
+	var err error
+	if err = json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Protocol != "" && j.Protocol != "groth16" {
+		return fmt.Errorf("unsupported protocol %s", j.Protocol)
+	}
+	if vk.Alpha, err = g1FromStrings(j.Alpha); err != nil {
+		return fmt.Errorf("vk_alpha_1: %w", err)
+	}
+	if vk.Beta, err = g2FromStrings(j.Beta); err != nil {
+		return fmt.Errorf("vk_beta_2: %w", err)
+	}
+	if vk.Gamma, err = g2FromStrings(j.Gamma); err != nil {
+		return fmt.Errorf("vk_gamma_2: %w", err)
+	}
+	if vk.Delta, err = g2FromStrings(j.Delta); err != nil {
+		return fmt.Errorf("vk_delta_2: %w", err)
+	}
+	if len(j.IC) == 0 {
+		return fmt.Errorf("IC is empty")
+	}
+	vk.IC = make([]*bn256.G1, len(j.IC))
+	for i, ic := range j.IC {
+		if vk.IC[i], err = g1FromStrings(ic); err != nil {
+			return fmt.Errorf("IC %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the proof using the snarkjs format
+func (p *Proof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(proofJSON{
+		A: g1ToStrings(p.A),
+		B: g2ToStrings(p.B),
+		C: g1ToStrings(p.C),
+	})
+}
+
+// UnmarshalJSON decodes a proof in the snarkjs format
+func (p *Proof) UnmarshalJSON(data []byte) error {
+	var j proofJSON
+	var err error
+	if err = json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if p.A, err = g1FromStrings(j.A); err != nil {
+		return fmt.Errorf("pi_a: %w", err)
+	}
+	if p.B, err = g2FromStrings(j.B); err != nil {
+		return fmt.Errorf("pi_b: %w", err)
+	}
+	if p.C, err = g1FromStrings(j.C); err != nil {
+		return fmt.Errorf("pi_c: %w", err)
+	}
+	return nil
+}
+
+// Bytes returns the binary encoding of the proof (A|B|C), as used by the EVM precompiles
+func (p *Proof) Bytes() []byte {
+	b := append(p.A.Marshal(), p.B.Marshal()...)
+	return append(b, p.C.Marshal()...)
+}
+
+// ProofFromBytes decodes a binary encoded proof, the points must be on the curve
+func ProofFromBytes(b []byte) (*Proof, error) {
+	if len(b) != ProofSize {
+		return nil, fmt.Errorf("wrong proof size %d, expected %d", len(b), ProofSize)
+	}
+	p := &Proof{A: new(bn256.G1), B: new(bn256.G2), C: new(bn256.G1)}
+	if _, err := p.A.Unmarshal(b[:64]); err != nil {
+		return nil, fmt.Errorf("invalid A point: %w", err)
+	}
+	if _, err := p.B.Unmarshal(b[64:192]); err != nil {
+		return nil, fmt.Errorf("invalid B point: %w", err)
+	}
+	if _, err := p.C.Unmarshal(b[192:]); err != nil {
+		return nil, fmt.Errorf("invalid C point: %w", err)
+	}
+	return p, nil
+}
+
+// Verify checks a Groth16 proof against the verification key and the public inputs
+func Verify(vk *VerificationKey, proof *Proof, inputs []*big.Int) error {
+	if vk == nil || proof == nil {
+		return fmt.Errorf("verification key or proof are nil")
+	}
+	if len(inputs) != vk.NumPublicInputs() {
+		return fmt.Errorf("wrong number of public inputs %d, expected %d", len(inputs), vk.NumPublicInputs())
+	}
+	// vkX = IC[0] + sum(inputs[i] * IC[i+1])
+	vkX := new(bn256.G1).Set(vk.IC[0])
+	for i, in := range inputs {
+		if in.Sign() < 0 || in.Cmp(FieldSize) >= 0 {
+			return fmt.Errorf("public input %d is not a field element", i)
+		}
+		vkX.Add(vkX, new(bn256.G1).ScalarMult(vk.IC[i+1], in))
+	}
+	// e(-A, B) * e(alpha, beta) * e(vkX, gamma) * e(C, delta) == 1
+	if !bn256.PairingCheck(
+		[]*bn256.G1{new(bn256.G1).Neg(proof.A), vk.Alpha, vkX, proof.C},
+		[]*bn256.G2{proof.B, vk.Beta, vk.Gamma, vk.Delta},
+	) {
+		return fmt.Errorf("invalid proof")
+	}
+	return nil
+}
+
+// BytesToInput converts a little-endian encoded hash (such as the Poseidon
+// hashes and census roots) to a public input
+func BytesToInput(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+// g1ToStrings returns the affine coordinates of a G1 point as snarkjs does
+func g1ToStrings(p *bn256.G1) []string {
+	m := p.Marshal()
+	return []string{
+		new(big.Int).SetBytes(m[:32]).String(),
+		new(big.Int).SetBytes(m[32:]).String(),
+		"1",
+	}
+}
+
+// g2ToStrings returns the affine coordinates of a G2 point as snarkjs does.
+// Note that snarkjs puts the real part first, while the EVM encoding used by
+// bn256 puts the imaginary part first.
+func g2ToStrings(p *bn256.G2) [][]string {
+	m := p.Marshal()
+	c := func(i int) string { return new(big.Int).SetBytes(m[i*32 : (i+1)*32]).String() }
+	return [][]string{{c(1), c(0)}, {c(3), c(2)}, {"1", "0"}}
+}
+
+func g1FromStrings(s []string) (*bn256.G1, error) {
+	if len(s) < 2 {
+		return nil, fmt.Errorf("wrong number of coordinates")
+	}
+	m, err := numbersToBytes(s[0], s[1])
+	if err != nil {
+		return nil, err
+	}
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(m); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func g2FromStrings(s [][]string) (*bn256.G2, error) {
+	if len(s) < 2 || len(s[0]) != 2 || len(s[1]) != 2 {
+		return nil, fmt.Errorf("wrong number of coordinates")
+	}
+	m, err := numbersToBytes(s[0][1], s[0][0], s[1][1], s[1][0])
+	if err != nil {
+		return nil, err
+	}
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(m); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// numbersToBytes encodes decimal numbers as concatenated 32 bytes big-endian integers
+func numbersToBytes(numbers ...string) ([]byte, error) {
+	m := make([]byte, 32*len(numbers))
+	for i, s := range numbers {
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok || n.Sign() < 0 || n.BitLen() > 256 {
+			return nil, fmt.Errorf("invalid coordinate %q", s)
+		}
+		copy(m[(i+1)*32-len(n.Bytes()):], n.Bytes())
+	}
+	return m, nil
+}
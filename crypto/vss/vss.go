@@ -0,0 +1,168 @@
+// Package vss implements Feldman verifiable secret sharing over the BabyJubJub
+// subgroup. A secret is split into shares so that any threshold of them can
+// rebuild it, while each share can be verified against the public commitments
+// of the sharing polynomial without knowing the secret.
+package vss
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// Order is the order of the field of the secrets and shares
+var Order = babyjub.SubOrder
+
+// ScalarSize is the size of an encoded secret or share
+const ScalarSize = 32
+
+// Split shares secret among the holders identified by xs (which must be
+// distinct and not zero), so that any threshold of the shares rebuild it. It
+// returns the share of each holder and the commitments of the polynomial.
+func Split(secret *big.Int, threshold int, xs []uint32, rnd io.Reader) ([]*big.Int, []*babyjub.Point, error) {
+	if threshold < 1 || threshold > len(xs) {
+		return nil, nil, fmt.Errorf("invalid threshold %d for %d holders", threshold, len(xs))
+	}
+	if secret.Sign() < 0 || secret.Cmp(Order) >= 0 {
+		return nil, nil, fmt.Errorf("secret out of the field")
+	}
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	seen := make(map[uint32]bool)
+	for _, x := range xs {
+		if x == 0 || seen[x] {
+			return nil, nil, fmt.Errorf("invalid or duplicated holder %d", x)
+		}
+		seen[x] = true
+	}
+	coefs := []*big.Int{new(big.Int).Set(secret)}
+	for i := 1; i < threshold; i++ {
+		c, err := rand.Int(rnd, Order)
+		if err != nil {
+			return nil, nil, err
+		}
+		coefs = append(coefs, c)
+	}
+	commitments := make([]*babyjub.Point, threshold)
+	for i, c := range coefs {
+		commitments[i] = babyjub.NewPoint().Mul(c, babyjub.B8)
+	}
+	shares := make([]*big.Int, len(xs))
+	for i, x := range xs {
+		// Horner evaluation of the polynomial at x
+		share := new(big.Int)
+		for j := threshold - 1; j >= 0; j-- {
+			share.Mul(share, big.NewInt(int64(x)))
+			share.Add(share, coefs[j])
+			share.Mod(share, Order)
+		}
+		shares[i] = share
+	}
+	return shares, commitments, nil
+}
+
+// VerifyShare returns true if share is the value at x of the polynomial
+// committed by commitments
+func VerifyShare(x uint32, share *big.Int, commitments []*babyjub.Point) bool {
+	if x == 0 || share.Sign() < 0 || share.Cmp(Order) >= 0 || len(commitments) == 0 {
+		return false
+	}
+	expected := babyjub.NewPoint()
+	xi := big.NewInt(1)
+	for _, c := range commitments {
+		expected.Add(expected, babyjub.NewPoint().Mul(xi, c))
+		xi.Mul(xi, big.NewInt(int64(x)))
+		xi.Mod(xi, Order)
+	}
+	got := babyjub.NewPoint().Mul(share, babyjub.B8)
+	return got.X.Cmp(expected.X) == 0 && got.Y.Cmp(expected.Y) == 0
+}
+
+// Combine rebuilds the secret from the shares of the holders xs, using
+// Lagrange interpolation. The number of shares must be at least the threshold
+// used on Split, otherwise the result is not the secret.
+func Combine(xs []uint32, shares []*big.Int) (*big.Int, error) {
+	if len(xs) == 0 || len(xs) != len(shares) {
+		return nil, fmt.Errorf("invalid number of shares")
+	}
+	secret := new(big.Int)
+	for i, xi := range xs {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for j, xj := range xs {
+			if i == j {
+				continue
+			}
+			if xi == xj {
+				return nil, fmt.Errorf("duplicated holder %d", xi)
+			}
+			num.Mul(num, big.NewInt(int64(xj)))
+			num.Mod(num, Order)
+			den.Mul(den, new(big.Int).Sub(big.NewInt(int64(xj)), big.NewInt(int64(xi))))
+			den.Mod(den, Order)
+		}
+		if den.ModInverse(den, Order) == nil {
+			return nil, fmt.Errorf("cannot interpolate holder %d", xi)
+		}
+		term := new(big.Int).Mul(shares[i], num)
+		term.Mul(term, den)
+		secret.Add(secret, term)
+		secret.Mod(secret, Order)
+	}
+	return secret, nil
+}
+
+// ScalarBytes encodes a secret or share as ScalarSize big-endian bytes
+func ScalarBytes(s *big.Int) []byte {
+	b := make([]byte, ScalarSize)
+	sb := s.Bytes()
+	copy(b[ScalarSize-len(sb):], sb)
+	return b
+}
+
+// ScalarFromBytes decodes a secret or share, which must be in the field
+func ScalarFromBytes(b []byte) (*big.Int, error) {
+	if len(b) != ScalarSize {
+		return nil, fmt.Errorf("invalid scalar size %d", len(b))
+	}
+	s := new(big.Int).SetBytes(b)
+	if s.Cmp(Order) >= 0 {
+		return nil, fmt.Errorf("scalar out of the field")
+	}
+	return s, nil
+}
+
+// EncodeCommitments returns the compressed commitments
+func EncodeCommitments(commitments []*babyjub.Point) [][]byte {
+	encoded := make([][]byte, len(commitments))
+	for i, c := range commitments {
+		comp := c.Compress()
+		encoded[i] = comp[:]
+	}
+	return encoded
+}
+
+// DecodeCommitments decodes compressed commitments, which must be points of
+// the subgroup
+func DecodeCommitments(encoded [][]byte) ([]*babyjub.Point, error) {
+	commitments := make([]*babyjub.Point, len(encoded))
+	for i, e := range encoded {
+		var comp [32]byte
+		if len(e) != len(comp) {
+			return nil, fmt.Errorf("invalid commitment %d size", i)
+		}
+		copy(comp[:], e)
+		p, err := babyjub.NewPoint().Decompress(comp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid commitment %d: %w", i, err)
+		}
+		if !p.InSubGroup() {
+			return nil, fmt.Errorf("commitment %d not in the subgroup", i)
+		}
+		commitments[i] = p
+	}
+	return commitments, nil
+}
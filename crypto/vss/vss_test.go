@@ -0,0 +1,77 @@
+package vss
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret, err := rand.Int(rand.Reader, Order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	xs := []uint32{1, 2, 4, 7, 9}
+	shares, commitments, err := Split(secret, 3, xs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commitments) != 3 {
+		t.Fatalf("got %d commitments, expected 3", len(commitments))
+	}
+	for i, x := range xs {
+		if !VerifyShare(x, shares[i], commitments) {
+			t.Errorf("valid share of holder %d not verified", x)
+		}
+	}
+	if VerifyShare(xs[0], new(big.Int).Add(shares[0], big.NewInt(1)), commitments) {
+		t.Error("invalid share verified")
+	}
+	if VerifyShare(xs[1], shares[0], commitments) {
+		t.Error("share verified for the wrong holder")
+	}
+
+	// any threshold of shares rebuild the secret
+	for _, idx := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var sxs []uint32
+		var sshares []*big.Int
+		for _, i := range idx {
+			sxs = append(sxs, xs[i])
+			sshares = append(sshares, shares[i])
+		}
+		got, err := Combine(sxs, sshares)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Cmp(secret) != 0 {
+			t.Errorf("shares %v did not rebuild the secret", idx)
+		}
+	}
+	got, err := Combine(xs[:2], shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(secret) == 0 {
+		t.Error("secret rebuilt with less shares than the threshold")
+	}
+
+	// encoding round trips
+	decoded, err := DecodeCommitments(EncodeCommitments(commitments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyShare(xs[0], shares[0], decoded) {
+		t.Error("share not verified with the decoded commitments")
+	}
+	s, err := ScalarFromBytes(ScalarBytes(shares[0]))
+	if err != nil || s.Cmp(shares[0]) != 0 {
+		t.Errorf("scalar encoding does not round trip (%v)", err)
+	}
+
+	if _, _, err := Split(secret, 3, []uint32{1, 2}, nil); err == nil {
+		t.Error("threshold greater than the holders accepted")
+	}
+	if _, _, err := Split(secret, 2, []uint32{1, 0, 3}, nil); err == nil {
+		t.Error("holder zero accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// loadBlock returns the block identified by the request hash or, if no hash
+// is provided, by the request height
+func (r *Router) loadBlock(request routerRequest) (*tmtypes.Block, error) {
+	var block *tmtypes.Block
+	switch {
+	case len(request.Hash) > 0:
+		if len(request.Hash) != types.BlockHashSize {
+			return nil, fmt.Errorf("malformed hash")
+		}
+		block = r.vocapp.Node.BlockStore().LoadBlockByHash(request.Hash)
+	case request.Height > 0:
+		block = r.vocapp.Node.BlockStore().LoadBlock(int64(request.Height))
+	default:
+		return nil, fmt.Errorf("no block height or hash provided")
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block not found")
+	}
+	return block, nil
+}
+
+func (r *Router) getBlock(request routerRequest) {
+	block, err := r.loadBlock(request)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get block: (%s)", err))
+		return
+	}
+	info := &types.BlockInfo{
+		Height:          uint32(block.Height),
+		Hash:            block.Hash().Bytes(),
+		LastBlockHash:   block.LastBlockID.Hash.Bytes(),
+		ProposerAddress: block.ProposerAddress.Bytes(),
+		AppHash:         block.AppHash.Bytes(),
+		Timestamp:       block.Time.Unix(),
+		TxHashes:        make([]types.HexBytes, len(block.Txs)),
+	}
+	for i, tx := range block.Txs {
+		hash := vochain.TxKey(tx)
+		info.TxHashes[i] = hash[:]
+	}
+	var response types.MetaResponse
+	response.Block = info
+	request.Send(r.buildReply(request, &response))
+}
+
+// getBlockTxs returns the decoded transactions of a block, starting from the
+// transaction index request.From. Transactions which cannot be decoded are null.
+func (r *Router) getBlockTxs(request routerRequest) {
+	block, err := r.loadBlock(request)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get block transactions: (%s)", err))
+		return
+	}
+	if request.ListSize > MaxListSize || request.ListSize <= 0 {
+		request.ListSize = MaxListSize
+	}
+	if request.From < 0 {
+		r.sendError(request, "cannot get block transactions: (invalid from)")
+		return
+	}
+	var response types.MetaResponse
+	for i := request.From; i < int64(len(block.Txs)) && i < request.From+request.ListSize; i++ {
+		tx, err := vochain.UnmarshalTx(block.Txs[i])
+		if err != nil {
+			response.Txs = append(response.Txs, json.RawMessage("null"))
+			continue
+		}
+		txJSON, err := protojson.Marshal(tx)
+		if err != nil {
+			r.sendError(request, fmt.Sprintf("cannot marshal transaction %d: (%s)", i, err))
+			return
+		}
+		response.Txs = append(response.Txs, txJSON)
+	}
+	response.Height = new(uint32)
+	*response.Height = uint32(block.Height)
+	response.BlockTimestamp = int32(block.Time.Unix())
+	request.Send(r.buildReply(request, &response))
+}
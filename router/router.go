@@ -22,6 +22,7 @@ import (
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/txindexer"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
@@ -110,6 +111,7 @@ type Router struct {
 	vocinfo      *vochaininfo.VochainInfo
 	allowPrivate bool
 	Scrutinizer  *scrutinizer.Scrutinizer
+	TxIndexer    *txindexer.TxIndexer
 	PrivateCalls uint64
 	PublicCalls  uint64
 	APIs         []string
@@ -254,7 +256,11 @@ func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochain
 	r.registerPublic("getEnvelopeList", r.getEnvelopeList)
 	r.registerPublic("getBlockHeight", r.getBlockHeight)
 	r.registerPublic("getProcessKeys", r.getProcessKeys)
+	r.registerPublic("getProcess", r.getProcess)
+	r.registerPublic("getProcesses", r.getProcesses)
 	r.registerPublic("getBlockStatus", r.getBlockStatus)
+	r.registerPublic("getBlock", r.getBlock)
+	r.registerPublic("getBlockTxs", r.getBlockTxs)
 	r.registerPublic("getProcessCount", r.getProcessCount)
 	if r.Scrutinizer != nil {
 		r.APIs = append(r.APIs, "results")
@@ -263,6 +269,12 @@ func (r *Router) EnableVoteAPI(vocapp *vochain.BaseApplication, vocInfo *vochain
 		r.registerPublic("getProcListLiveResults", r.getProcListLiveResults)
 		r.registerPublic("getScrutinizerEntities", r.getScrutinizerEntities)
 		r.registerPublic("getScrutinizerEntityCount", r.getScrutinizerEntityCount)
+		r.registerPublic("getProcessSummary", r.getProcessSummary)
+	}
+	if r.TxIndexer != nil {
+		r.APIs = append(r.APIs, "indexer")
+		r.registerPublic("getTx", r.getTx)
+		r.registerPublic("getTxListForBlock", r.getTxListForBlock)
 	}
 }
 
@@ -311,6 +323,16 @@ func (r *Router) Route() {
 }
 
 func (r *Router) sendError(request routerRequest, errMsg string) {
+	r.sendErrorResponse(request, errMsg, nil)
+}
+
+// sendTxError sends the error of a transaction rejected by the vochain,
+// including its result code so clients can branch on the cause
+func (r *Router) sendTxError(request routerRequest, code uint32, errMsg string) {
+	r.sendErrorResponse(request, errMsg, &types.TxError{Code: code, Name: vochain.TxErrorName(code)})
+}
+
+func (r *Router) sendErrorResponse(request routerRequest, errMsg string, txError *types.TxError) {
 	log.Warn(errMsg)
 
 	// Add any last fields to the inner response, and marshal it with sorted
@@ -318,6 +340,7 @@ func (r *Router) sendError(request routerRequest, errMsg string) {
 	response := types.MetaResponse{
 		Request:   request.id,
 		Timestamp: int32(time.Now().Unix()),
+		TxError:   txError,
 	}
 	response.SetError(errMsg)
 	respInner, err := crypto.SortedMarshalJSON(response)
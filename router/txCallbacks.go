@@ -0,0 +1,33 @@
+package router
+
+import (
+	"fmt"
+
+	"go.vocdoni.io/dvote/types"
+)
+
+func (r *Router) getTx(request routerRequest) {
+	if len(request.Hash) != types.TxHashSize {
+		r.sendError(request, "cannot get transaction: (malformed hash)")
+		return
+	}
+	tx, err := r.TxIndexer.Transaction(request.Hash)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get transaction: (%s)", err))
+		return
+	}
+	var response types.MetaResponse
+	response.Tx = tx
+	request.Send(r.buildReply(request, &response))
+}
+
+func (r *Router) getTxListForBlock(request routerRequest) {
+	txs, err := r.TxIndexer.BlockTransactions(request.Height)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get block transactions: (%s)", err))
+		return
+	}
+	var response types.MetaResponse
+	response.TxList = txs
+	request.Send(r.buildReply(request, &response))
+}
@@ -3,6 +3,7 @@ package router
 import (
 	"encoding/base64"
 	"fmt"
+	"math/big"
 
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
@@ -25,7 +26,7 @@ func (r *Router) submitRawTx(request routerRequest) {
 		return
 	}
 	if res.Code != 0 {
-		r.sendError(request, string(res.Data))
+		r.sendTxError(request, res.Code, string(res.Data))
 		return
 	}
 	log.Infof("broadcasting tx hash:%s", res.Hash)
@@ -68,7 +69,7 @@ func (r *Router) submitEnvelope(request routerRequest) {
 
 	// Get mempool checkTx reply
 	if res.Code != 0 {
-		r.sendError(request, string(res.Data))
+		r.sendTxError(request, res.Code, string(res.Data))
 		return
 	}
 	log.Infof("broadcasting vochain tx hash:%s code:%d", res.Hash, res.Code)
@@ -77,6 +78,9 @@ func (r *Router) submitEnvelope(request routerRequest) {
 	request.Send(r.buildReply(request, &response))
 }
 
+// The envelope and process keys methods read the state at the block
+// request.Height if provided, or at the last block otherwise
+
 func (r *Router) getEnvelopeStatus(request routerRequest) {
 	// check pid
 	if len(request.ProcessID) != types.ProcessIDsize {
@@ -93,7 +97,7 @@ func (r *Router) getEnvelopeStatus(request routerRequest) {
 	var response types.MetaResponse
 	response.Registered = types.False
 
-	e, err := r.vocapp.State.Envelope(request.ProcessID, request.Nullifier, true)
+	e, err := r.vocapp.State.EnvelopeAtHeight(request.ProcessID, request.Nullifier, request.Height)
 	// Warning, error is ignored. We should find a better way to check the envelope status
 	if err == nil && e != nil {
 		response.Registered = types.True
@@ -120,7 +124,7 @@ func (r *Router) getEnvelope(request routerRequest) {
 		r.sendError(request, "cannot get envelope status: (malformed nullifier)")
 		return
 	}
-	envelope, err := r.vocapp.State.Envelope(request.ProcessID, request.Nullifier, true)
+	envelope, err := r.vocapp.State.EnvelopeAtHeight(request.ProcessID, request.Nullifier, request.Height)
 	if err != nil {
 		r.sendError(request, fmt.Sprintf("cannot get envelope: (%s)", err))
 		return
@@ -137,10 +141,26 @@ func (r *Router) getEnvelopeHeight(request routerRequest) {
 		r.sendError(request, "cannot get envelope status: (malformed processId)")
 		return
 	}
-	votes := r.vocapp.State.CountVotes(request.ProcessID, true)
+	votes, err := r.vocapp.State.CountVotesAtHeight(request.ProcessID, request.Height)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get vote count: (%s)", err))
+		return
+	}
+	limits, err := r.vocapp.State.ProcessLimits(request.ProcessID, true)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get process limits: (%s)", err))
+		return
+	}
 	var response types.MetaResponse
 	response.Height = new(uint32)
 	*response.Height = votes
+	// the process vote limits, so clients know how many votes are left
+	if limits.MaxCensusSize > 0 {
+		response.MaxCensusSize = &limits.MaxCensusSize
+	}
+	if limits.PetitionThreshold > 0 {
+		response.PetitionThreshold = &limits.PetitionThreshold
+	}
 	request.Send(r.buildReply(request, &response))
 }
 
@@ -210,7 +230,7 @@ func (r *Router) getProcessKeys(request routerRequest) {
 		r.sendError(request, "cannot get envelope status: (malformed processId)")
 		return
 	}
-	process, err := r.vocapp.State.Process(request.ProcessID, true)
+	process, err := r.vocapp.State.ProcessAtHeight(request.ProcessID, request.Height)
 	if err != nil {
 		r.sendError(request, fmt.Sprintf("cannot get process encryption public keys: (%s)", err))
 		return
@@ -241,9 +261,116 @@ func (r *Router) getProcessKeys(request routerRequest) {
 	response.EncryptionPrivKeys = privs
 	response.CommitmentKeys = coms
 	response.RevealKeys = revs
+	// the evidence list only grows, so the one recorded up to the height is kept
+	evidence, err := r.vocapp.State.ProcessKeyKeeperEvidence(request.ProcessID, true)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get keykeeper evidence: (%s)", err))
+		return
+	}
+	for _, e := range evidence {
+		if request.Height > 0 && e.Height > int64(request.Height) {
+			continue
+		}
+		response.KeyKeeperEvidence = append(response.KeyKeeperEvidence, types.KeyEvidence{
+			Height:    e.Height,
+			KeyIndex:  e.KeyIndex,
+			KeyKeeper: e.KeyKeeper.Hex(),
+			Reason:    e.Reason,
+		})
+	}
 	request.Send(r.buildReply(request, &response))
 }
 
+// getProcess returns the on-chain parameters of a process
+func (r *Router) getProcess(request routerRequest) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		r.sendError(request, "cannot get process: (malformed processId)")
+		return
+	}
+	process, err := r.vocapp.State.ProcessAtHeight(request.ProcessID, request.Height)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get process: (%s)", err))
+		return
+	}
+	var response types.MetaResponse
+	response.Process = processInfo(process)
+	request.Send(r.buildReply(request, &response))
+}
+
+// getProcesses returns the on-chain parameters of a list of processes, in
+// the same order as requested
+func (r *Router) getProcesses(request routerRequest) {
+	if len(request.ProcessIDs) == 0 || len(request.ProcessIDs) > MaxListSize {
+		r.sendError(request, fmt.Sprintf("cannot get processes: (between 1 and %d processIds required)", MaxListSize))
+		return
+	}
+	var response types.MetaResponse
+	for _, pid := range request.ProcessIDs {
+		if len(pid) != types.ProcessIDsize {
+			r.sendError(request, fmt.Sprintf("cannot get processes: (malformed processId %x)", pid))
+			return
+		}
+		process, err := r.vocapp.State.ProcessAtHeight(pid, request.Height)
+		if err != nil {
+			r.sendError(request, fmt.Sprintf("cannot get process %x: (%s)", pid, err))
+			return
+		}
+		response.Processes = append(response.Processes, processInfo(process))
+	}
+	request.Send(r.buildReply(request, &response))
+}
+
+// processInfo returns the API representation of a process
+func processInfo(p *models.Process) *types.ProcessInfo {
+	info := &types.ProcessInfo{
+		ProcessID:     p.ProcessId,
+		EntityID:      p.EntityId,
+		Namespace:     p.Namespace,
+		Status:        p.Status.String(),
+		CensusRoot:    p.CensusRoot,
+		CensusURI:     p.GetCensusURI(),
+		CensusOrigin:  p.CensusOrigin.String(),
+		StartBlock:    p.StartBlock,
+		BlockCount:    p.BlockCount,
+		KeyIndex:      p.GetKeyIndex(),
+		QuestionIndex: p.GetQuestionIndex(),
+		QuestionCount: p.GetQuestionCount(),
+	}
+	if et := p.GetEnvelopeType(); et != nil {
+		info.EnvelopeType = types.ProcessEnvelope{
+			Serial:         et.Serial,
+			Anonymous:      et.Anonymous,
+			EncryptedVotes: et.EncryptedVotes,
+			UniqueValues:   et.UniqueValues,
+		}
+	}
+	if mode := p.GetMode(); mode != nil {
+		info.Mode = types.ProcessMode{
+			AutoStart:         mode.AutoStart,
+			Interruptible:     mode.Interruptible,
+			DynamicCensus:     mode.DynamicCensus,
+			EncryptedMetaData: mode.EncryptedMetaData,
+		}
+	}
+	if vo := p.GetVoteOptions(); vo != nil {
+		info.VoteOptions = types.ProcessVoteOptions{
+			MaxCount:          vo.MaxCount,
+			MaxValue:          vo.MaxValue,
+			MaxVoteOverwrites: vo.MaxVoteOverwrites,
+			MaxTotalCost:      vo.MaxTotalCost,
+			CostExponent:      vo.CostExponent,
+		}
+	}
+	for _, q := range p.GetResults().GetVotes() {
+		question := []string{}
+		for _, v := range q.Question {
+			question = append(question, new(big.Int).SetBytes(v).String())
+		}
+		info.Results = append(info.Results, question)
+	}
+	return info
+}
+
 func (r *Router) getEnvelopeList(request routerRequest) {
 	// check pid
 	if len(request.ProcessID) != types.ProcessIDsize {
@@ -257,7 +384,11 @@ func (r *Router) getEnvelopeList(request routerRequest) {
 	if request.ListSize == 0 {
 		request.ListSize = 64
 	}
-	nullifiers := r.vocapp.State.EnvelopeList(request.ProcessID, request.From, request.ListSize, true)
+	nullifiers, err := r.vocapp.State.EnvelopeListAtHeight(request.ProcessID, request.From, request.ListSize, request.Height)
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get envelope list: (%s)", err))
+		return
+	}
 	var response types.MetaResponse
 	strnull := []string{}
 	for _, n := range nullifiers {
@@ -355,6 +486,28 @@ func (r *Router) getScrutinizerEntities(request routerRequest) {
 	request.Send(r.buildReply(request, &response))
 }
 
+// vote statistics of a process, the votes per block start at the height request.From
+func (r *Router) getProcessSummary(request routerRequest) {
+	if len(request.ProcessID) != types.ProcessIDsize {
+		r.sendError(request, "cannot get process summary: (malformed processId)")
+		return
+	}
+	if request.ListSize > MaxListSize || request.ListSize <= 0 {
+		request.ListSize = MaxListSize
+	}
+	if request.From < 0 {
+		request.From = 0
+	}
+	summary, err := r.Scrutinizer.ProcessSummary(request.ProcessID, uint32(request.From), int(request.ListSize))
+	if err != nil {
+		r.sendError(request, fmt.Sprintf("cannot get process summary: (%s)", err))
+		return
+	}
+	var response types.MetaResponse
+	response.ProcessSummary = summary
+	request.Send(r.buildReply(request, &response))
+}
+
 func (r *Router) getBlockStatus(request routerRequest) {
 	var response types.MetaResponse
 	h := uint32(r.vocapp.State.Header(true).Height)
@@ -15,12 +15,13 @@ import (
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/txindexer"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
 // TBD: user the net.Transport interface
 func API(apiconfig *config.API, pxy *net.Proxy, storage data.Storage, cm *census.Manager, vapp *vochain.BaseApplication,
-	sc *scrutinizer.Scrutinizer, vi *vochaininfo.VochainInfo, vochainRPCaddr string, signer *ethereum.SignKeys, ma *metrics.Agent,
+	sc *scrutinizer.Scrutinizer, ti *txindexer.TxIndexer, vi *vochaininfo.VochainInfo, vochainRPCaddr string, signer *ethereum.SignKeys, ma *metrics.Agent,
 ) error {
 	log.Infof("creating API service")
 	// API Endpoint initialization
@@ -63,6 +64,7 @@ func API(apiconfig *config.API, pxy *net.Proxy, storage data.Storage, cm *census
 		// todo: client params as cli flags
 		log.Info("enabling vote API")
 		routerAPI.Scrutinizer = sc
+		routerAPI.TxIndexer = ti
 		routerAPI.EnableVoteAPI(vapp, vi)
 	}
 
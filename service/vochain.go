@@ -16,10 +16,11 @@ import (
 	"go.vocdoni.io/dvote/vochain"
 	"go.vocdoni.io/dvote/vochain/censusdownloader"
 	"go.vocdoni.io/dvote/vochain/scrutinizer"
+	"go.vocdoni.io/dvote/vochain/txindexer"
 	"go.vocdoni.io/dvote/vochain/vochaininfo"
 )
 
-func Vochain(vconfig *config.VochainCfg, results, waitForSync bool, ma *metrics.Agent, cm *census.Manager) (vnode *vochain.BaseApplication, sc *scrutinizer.Scrutinizer, vi *vochaininfo.VochainInfo, err error) {
+func Vochain(vconfig *config.VochainCfg, results, indexer, waitForSync bool, ma *metrics.Agent, cm *census.Manager) (vnode *vochain.BaseApplication, sc *scrutinizer.Scrutinizer, ti *txindexer.TxIndexer, vi *vochaininfo.VochainInfo, err error) {
 	log.Infof("creating vochain service for network %s", vconfig.Chain)
 	var host, port string
 	var ip net.IP
@@ -111,6 +112,14 @@ func Vochain(vconfig *config.VochainCfg, results, waitForSync bool, ma *metrics.
 			return
 		}
 	}
+	// Transaction indexer
+	if indexer {
+		log.Info("creating vochain transaction indexer service")
+		ti, err = txindexer.NewTxIndexer(vconfig.DataDir+"/txindexer", vnode.State)
+		if err != nil {
+			return
+		}
+	}
 	if cm != nil {
 		log.Infof("starting census downloader service")
 		censusdownloader.NewCensusDownloader(vnode, cm, !vconfig.ImportPreviousCensus)
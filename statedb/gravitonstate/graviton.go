@@ -1,8 +1,11 @@
 package gravitonstate
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"sync"
@@ -248,6 +251,35 @@ func (g *GravitonState) TreeWithRoot(root []byte) statedb.StateTree {
 	return &GravitonTree{tree: safeTree{gtree: gt}, version: g.Version()}
 }
 
+// TreeAtVersion returns the tree as it was commited on the state version,
+// nil if the version does not exist
+func (g *GravitonState) TreeAtVersion(name string, version uint64) statedb.StateTree {
+	if version == 0 || version > g.lastCommitVersion {
+		return nil
+	}
+	sn, err := g.store.LoadSnapshot(0)
+	if err != nil {
+		return nil
+	}
+	vt, err := sn.GetTreeWithVersion(g.vTree.Name, version)
+	if err != nil {
+		return nil
+	}
+	tvb, err := vt.Get([]byte(name))
+	if err != nil {
+		return nil
+	}
+	tv, err := strconv.ParseUint(string(tvb), 10, 64)
+	if err != nil {
+		return nil
+	}
+	t, err := sn.GetTreeWithVersion(name, tv)
+	if err != nil {
+		return nil
+	}
+	return &GravitonTree{tree: safeTree{gtree: t}, version: version}
+}
+
 // KeyDiff returns the list of inserted keys on rootBig not present in rootSmall
 func (g *GravitonState) KeyDiff(rootSmall, rootBig []byte) ([][]byte, error) {
 	t1 := g.TreeWithRoot(rootSmall)
@@ -356,6 +388,93 @@ func (g *GravitonState) Hash() []byte {
 	return g.getHash()
 }
 
+// Export writes the key/values of the last commited version of the tree to w.
+// Graviton hashes only depend on the tree content, so there is no need to export
+// the internal tree structure.
+func (g *GravitonState) Export(name string, w io.Writer) error {
+	t, ok := g.imTrees[name]
+	if !ok {
+		return fmt.Errorf("tree %s does not exist", name)
+	}
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	var err error
+	t.Iterate(nil, func(k, v []byte) bool {
+		for _, b := range [][]byte{k, v} {
+			if _, err = bw.Write(buf[:binary.PutUvarint(buf[:], uint64(len(b)))]); err != nil {
+				return true
+			}
+			if _, err = bw.Write(b); err != nil {
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import restores a tree previously dumped with Export and commits it.
+func (g *GravitonState) Import(name string, r io.Reader) error {
+	t, ok := g.trees[name]
+	if !ok {
+		return fmt.Errorf("tree %s does not exist", name)
+	}
+	br := bufio.NewReader(r)
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(br, b)
+		return b, err
+	}
+	for {
+		k, err := readBytes()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		v, err := readBytes()
+		if err != nil {
+			return err
+		}
+		if err := t.Add(k, v); err != nil {
+			return err
+		}
+	}
+	if err := t.tree.Commit(); err != nil {
+		return err
+	}
+	if err := g.vTree.Add(name, t.tree.GetVersion()); err != nil {
+		return err
+	}
+	if err := g.vTree.Commit(); err != nil {
+		return err
+	}
+	g.lastCommitVersion = g.vTree.Version()
+	return g.updateImmutable()
+}
+
+// Versions returns all the commited versions, graviton never removes them
+func (g *GravitonState) Versions() []uint64 {
+	versions := make([]uint64, g.lastCommitVersion)
+	for i := range versions {
+		versions[i] = uint64(i + 1)
+	}
+	return versions
+}
+
+// DeleteVersion is not supported, graviton is an append only store
+func (g *GravitonState) DeleteVersion(version uint64) (uint64, error) {
+	return 0, fmt.Errorf("graviton does not support deleting versions")
+}
+
 func (g *GravitonState) Close() error {
 	g.store.Close()
 	return nil
@@ -379,6 +498,17 @@ func (t *GravitonTree) Add(key, value []byte) error {
 	return err
 }
 
+func (t *GravitonTree) Delete(key []byte) error {
+	if _, err := t.tree.Get(key); err != nil {
+		return nil
+	}
+	err := t.tree.Delete(key)
+	if err == nil && atomic.LoadUint64(&t.size) > 0 {
+		atomic.AddUint64(&t.size, ^uint64(0))
+	}
+	return err
+}
+
 func (t *GravitonTree) Version() uint64 {
 	return t.version
 }
@@ -1,6 +1,7 @@
 package gravitonstate
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"testing"
@@ -180,3 +181,57 @@ func TestOrderAndProof(t *testing.T) {
 	}
 
 }
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+
+	s := &GravitonState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if i%10 == 0 {
+			if _, err := s.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	hash, err := s.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.Export("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := &GravitonState{}
+	if err := s2.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Import("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if h := s2.Hash(); string(h) != string(hash) {
+		t.Errorf("imported tree hash is different: %x != %x", h, hash)
+	}
+	if c := s2.ImmutableTree("t1").Count(); c != 100 {
+		t.Errorf("imported tree size must be 100, but it is %d", c)
+	}
+	if v := s2.ImmutableTree("t1").Get([]byte("42")); string(v) != "number 42" {
+		t.Errorf("imported value for key 42 is wrong: %s", v)
+	}
+}
@@ -0,0 +1,30 @@
+package iavlstate
+
+import (
+	"sync/atomic"
+
+	tmdb "github.com/tendermint/tm-db"
+)
+
+// deleteCounterDB wraps a tendermint database to count the bytes (keys and
+// values) removed by batch deletes, which is how IAVL prunes its versions
+type deleteCounterDB struct {
+	tmdb.DB
+	deleted *uint64
+}
+
+func (d *deleteCounterDB) NewBatch() tmdb.Batch {
+	return &deleteCounterBatch{Batch: d.DB.NewBatch(), db: d}
+}
+
+type deleteCounterBatch struct {
+	tmdb.Batch
+	db *deleteCounterDB
+}
+
+func (b *deleteCounterBatch) Delete(key []byte) error {
+	if value, err := b.db.Get(key); err == nil && value != nil {
+		atomic.AddUint64(b.db.deleted, uint64(len(key)+len(value)))
+	}
+	return b.Batch.Delete(key)
+}
@@ -1,11 +1,17 @@
 package iavlstate
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io"
 	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 
+	ics23 "github.com/confio/ics23/go"
 	"github.com/cosmos/iavl"
 	tmdb "github.com/tendermint/tm-db"
 	"go.vocdoni.io/dvote/crypto/ethereum"
@@ -22,6 +28,7 @@ type IavlState struct {
 	versionTree *iavl.MutableTree // For each tree, saves its last commited version
 	storageType string            // mem or disk
 	db          tmdb.DB
+	deleted     uint64 // bytes removed from the databases, updated by deleteCounterDB
 }
 
 type IavlTree struct {
@@ -51,6 +58,7 @@ func (i *IavlState) Init(storagePath, storageType string) error {
 	} else {
 		return fmt.Errorf("storageType %s not supported", storageType)
 	}
+	i.db = &deleteCounterDB{DB: i.db, deleted: &i.deleted}
 
 	if i.versionTree, err = iavl.NewMutableTree(i.db, PrefixDBCacheSize); err != nil {
 		return err
@@ -143,6 +151,7 @@ func (i *IavlState) AddTree(name string) error {
 	} else {
 		st = tmdb.NewMemDB()
 	}
+	st = &deleteCounterDB{DB: st, deleted: &i.deleted}
 
 	var t *iavl.MutableTree
 	if t, err = iavl.NewMutableTree(st, PrefixDBCacheSize); err != nil {
@@ -163,8 +172,26 @@ func (i *IavlState) Tree(name string) statedb.StateTree {
 	return &IavlTree{tree: i.trees[name].tree, isImmutable: false}
 }
 
-func (g *IavlState) TreeWithRoot(root []byte) statedb.StateTree {
-	// TO-DO
+// TreeWithRoot returns the commited tree version with the given root hash,
+// looking in all the versions still available of every tree
+func (i *IavlState) TreeWithRoot(root []byte) statedb.StateTree {
+	if len(root) == 0 {
+		return nil
+	}
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	for _, t := range i.trees {
+		versions := t.tree.AvailableVersions()
+		for j := len(versions) - 1; j >= 0; j-- {
+			it, err := t.tree.GetImmutable(int64(versions[j]))
+			if err != nil {
+				continue
+			}
+			if bytes.Equal(it.Hash(), root) {
+				return &IavlTree{itree: it, isImmutable: true}
+			}
+		}
+	}
 	return nil
 }
 
@@ -174,6 +201,62 @@ func (i *IavlState) ImmutableTree(name string) statedb.StateTree { // a tree ver
 	return &IavlTree{itree: i.trees[name].itree, isImmutable: true}
 }
 
+// TreeAtVersion returns the tree as it was commited on the state version.
+// Returns nil if the version does not exist or has been pruned.
+func (i *IavlState) TreeAtVersion(name string, version uint64) statedb.StateTree {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	t, ok := i.trees[name]
+	if !ok {
+		return nil
+	}
+	tv, ok := i.treeVersion(name, int64(version))
+	if !ok {
+		return nil
+	}
+	it, err := t.tree.GetImmutable(tv)
+	if err != nil {
+		return nil
+	}
+	return &IavlTree{itree: it, isImmutable: true}
+}
+
+// treeVersionKey is the version tree key storing the version of the tree
+// saved on each state version. The tree name key stores the version of the
+// tree before the commit, which LoadVersion uses to overwrite the state.
+func treeVersionKey(name string) []byte {
+	return []byte("version/" + name)
+}
+
+// treeVersion returns the version of the tree saved on the state version. The
+// tree versions do not follow the state versions, an imported tree is saved on
+// two state versions and a tree added later starts on its own version one.
+func (i *IavlState) treeVersion(name string, version int64) (int64, bool) {
+	if !i.versionTree.VersionExists(version) {
+		return 0, false
+	}
+	vt, err := i.versionTree.GetImmutable(version)
+	if err != nil {
+		return 0, false
+	}
+	_, vb := vt.Get(treeVersionKey(name))
+	if vb == nil {
+		// commited without the tree version key, the name key of the next
+		// state version stores the tree version saved on this one
+		if vt, err = i.versionTree.GetImmutable(version + 1); err != nil {
+			return 0, false
+		}
+		if _, vb = vt.Get([]byte(name)); vb == nil {
+			return 0, false
+		}
+	}
+	tv, err := strconv.ParseInt(string(vb), 10, 64)
+	if err != nil || tv <= 0 {
+		return 0, false
+	}
+	return tv, true
+}
+
 func (i *IavlState) KeyDiff(root1, root2 []byte) ([][]byte, error) {
 	// TO-DO
 	return nil, nil
@@ -189,6 +272,7 @@ func (i *IavlState) Commit() ([]byte, error) { // Returns New Hash
 		} else {
 			t.lastCommitedVersion = uint64(v)
 		}
+		i.versionTree.Set(treeVersionKey(name), []byte(strconv.FormatUint(t.lastCommitedVersion, 10)))
 	}
 	if _, _, err := i.versionTree.SaveVersion(); err != nil {
 		return nil, fmt.Errorf("cannot save version state tree: (%s)", err)
@@ -228,6 +312,194 @@ func (i *IavlState) Hash() []byte {
 	return i.getHash()
 }
 
+// Export writes the nodes of the last commited version of the tree to w.
+// The nodes are exported with their IAVL version and height, so the tree
+// obtained by Import has exactly the same hash.
+func (i *IavlState) Export(name string, w io.Writer) error {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	t, ok := i.trees[name]
+	if !ok {
+		return fmt.Errorf("tree %s does not exist", name)
+	}
+	bw := bufio.NewWriter(w)
+	var buf [binary.MaxVarintLen64]byte
+	writeBytes := func(b []byte) error {
+		// length is stored as len+1, so zero means nil (inner nodes have no value)
+		n := 0
+		if b != nil {
+			n = len(b) + 1
+		}
+		if _, err := bw.Write(buf[:binary.PutUvarint(buf[:], uint64(n))]); err != nil {
+			return err
+		}
+		_, err := bw.Write(b)
+		return err
+	}
+	if _, err := bw.Write(buf[:binary.PutVarint(buf[:], t.itree.Version())]); err != nil {
+		return err
+	}
+	exporter := t.itree.Export()
+	defer exporter.Close()
+	for {
+		node, err := exporter.Next()
+		if err == iavl.ExportDone {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(buf[:binary.PutVarint(buf[:], node.Version)]); err != nil {
+			return err
+		}
+		if err := bw.WriteByte(byte(node.Height)); err != nil {
+			return err
+		}
+		if err := writeBytes(node.Key); err != nil {
+			return err
+		}
+		if err := writeBytes(node.Value); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Import restores a tree previously dumped with Export. The tree must be empty.
+// Once imported, the tree is commited at the exported version.
+func (i *IavlState) Import(name string, r io.Reader) error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	t, ok := i.trees[name]
+	if !ok {
+		return fmt.Errorf("tree %s does not exist", name)
+	}
+	br := bufio.NewReader(r)
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(br)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		b := make([]byte, n-1)
+		_, err = io.ReadFull(br, b)
+		return b, err
+	}
+	version, err := binary.ReadVarint(br)
+	if err != nil {
+		return fmt.Errorf("cannot read tree version: %w", err)
+	}
+	importer, err := t.tree.Import(version)
+	if err != nil {
+		return err
+	}
+	defer importer.Close()
+	for {
+		node := &iavl.ExportNode{}
+		if node.Version, err = binary.ReadVarint(br); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		height, err := br.ReadByte()
+		if err != nil {
+			return err
+		}
+		node.Height = int8(height)
+		if node.Key, err = readBytes(); err != nil {
+			return err
+		}
+		if node.Value, err = readBytes(); err != nil {
+			return err
+		}
+		if err := importer.Add(node); err != nil {
+			return err
+		}
+	}
+	if err := importer.Commit(); err != nil {
+		return err
+	}
+	t.lastCommitedVersion = uint64(version)
+
+	// The version tree is saved twice, so LoadVersion(-1) still finds the
+	// imported version if the node is restarted before the next commit.
+	i.versionTree.Set([]byte(name), []byte(strconv.FormatInt(version, 10)))
+	i.versionTree.Set(treeVersionKey(name), []byte(strconv.FormatInt(version, 10)))
+	for j := 0; j < 2; j++ {
+		if _, _, err := i.versionTree.SaveVersion(); err != nil {
+			return fmt.Errorf("cannot save version state tree: (%s)", err)
+		}
+	}
+	return i.updateImmutables()
+}
+
+// Versions returns the commited versions of the state still available, sorted
+func (i *IavlState) Versions() []uint64 {
+	i.lock.RLock()
+	defer i.lock.RUnlock()
+	available := i.versionTree.AvailableVersions()
+	versions := make([]uint64, len(available))
+	for j, v := range available {
+		versions[j] = uint64(v)
+	}
+	return versions
+}
+
+// DeleteVersion removes a commited version of the state and returns the number
+// of bytes deleted from the databases. The version of each tree is the one
+// recorded on the version tree, and it is kept if the previous or the next
+// available state version still uses it. The last commited version cannot be
+// removed.
+func (i *IavlState) DeleteVersion(version uint64) (uint64, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	last := i.versionTree.Version()
+	if int64(version) >= last {
+		return 0, fmt.Errorf("cannot delete version %d, the last commited version is %d", version, last)
+	}
+	if !i.versionTree.VersionExists(int64(version)) {
+		return 0, nil
+	}
+	// the tree versions grow with the state versions, so only the neighbour
+	// state versions can share a tree version with the deleted one
+	var neighbours []int64
+	available := i.versionTree.AvailableVersions()
+	for j, v := range available {
+		if int64(v) != int64(version) {
+			continue
+		}
+		if j > 0 {
+			neighbours = append(neighbours, int64(available[j-1]))
+		}
+		if j < len(available)-1 {
+			neighbours = append(neighbours, int64(available[j+1]))
+		}
+		break
+	}
+	deleted := atomic.LoadUint64(&i.deleted)
+	for name, t := range i.trees {
+		tv, ok := i.treeVersion(name, int64(version))
+		if !ok || !t.tree.VersionExists(tv) || tv == t.tree.Version() {
+			continue
+		}
+		shared := false
+		for _, n := range neighbours {
+			if ntv, ok := i.treeVersion(name, n); ok && ntv == tv {
+				shared = true
+			}
+		}
+		if shared {
+			continue
+		}
+		if err := t.tree.DeleteVersion(tv); err != nil {
+			return 0, fmt.Errorf("cannot delete version %d of tree %s: (%s)", tv, name, err)
+		}
+	}
+	if err := i.versionTree.DeleteVersion(int64(version)); err != nil {
+		return 0, fmt.Errorf("cannot delete version %d of version tree: (%s)", version, err)
+	}
+	return atomic.LoadUint64(&i.deleted) - deleted, nil
+}
+
 func (t *IavlState) Close() error {
 	return t.db.Close()
 }
@@ -252,17 +524,23 @@ func (t *IavlTree) Add(key, value []byte) error {
 	return nil
 }
 
+func (t *IavlTree) Delete(key []byte) error {
+	if t.isImmutable {
+		return fmt.Errorf("cannot delete values from a immutable tree")
+	}
+	t.tree.Remove(key)
+	return nil
+}
+
 func (t *IavlTree) Iterate(prefix []byte, callback func(key, value []byte) bool) {
-	until := make([]byte, len(prefix))
-	copy(until, prefix)
-	// Set until to the next prefix: 0xABCDEF => 0xABCDFF
-	for i := len(until) - 1; i >= 0; i-- {
-		if until[i] != byte(0xFF) {
-			until[i] += byte(0x01)
-			break
-		}
-		if i == -1 {
-			until = nil
+	// Set until to the next prefix: 0xABCDEF => 0xABCDF0, 0xABFF => 0xAC.
+	// A nil until (empty or 0xFF... prefix) iterates up to the last key.
+	var until []byte
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != byte(0xFF) {
+			until = make([]byte, i+1)
+			copy(until, prefix)
+			until[i]++
 			break
 		}
 	}
@@ -298,19 +576,39 @@ func (t *IavlTree) Version() uint64 {
 	}
 }
 
+// Proof returns the ICS23 membership proof of key, protobuf encoded
 func (t *IavlTree) Proof(key []byte) ([]byte, error) {
-	var p *iavl.RangeProof
+	var p *ics23.CommitmentProof
 	var err error
 	if t.isImmutable {
-		_, p, err = t.itree.GetWithProof(key)
+		p, err = t.itree.GetMembershipProof(key)
 	} else {
-		_, p, err = t.tree.GetWithProof(key)
+		p, err = t.tree.GetMembershipProof(key)
 	}
-
-	return []byte(p.String()), err
+	if err != nil {
+		return nil, err
+	}
+	return p.Marshal()
 }
 
+// Verify checks a membership proof of key against root.
+// If root is nil, the current tree hash is used.
 func (t *IavlTree) Verify(key, proof, root []byte) bool {
-	// TO-DO
-	return false
+	if root == nil {
+		root = t.Hash()
+	}
+	return Verify(key, proof, root)
+}
+
+// Verify checks an ICS23 membership proof of key against root
+func Verify(key, proof, root []byte) bool {
+	p := &ics23.CommitmentProof{}
+	if err := p.Unmarshal(proof); err != nil {
+		return false
+	}
+	exist := p.GetExist()
+	if exist == nil {
+		return false
+	}
+	return ics23.VerifyMembership(ics23.IavlSpec, root, p, key, exist.Value)
 }
@@ -1,6 +1,7 @@
 package iavlstate
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"testing"
@@ -180,3 +181,129 @@ func TestOrder(t *testing.T) {
 		}
 	*/
 }
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+
+	s := &IavlState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if i%10 == 0 {
+			if _, err := s.Commit(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	hash, err := s.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := s.Export("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	s2 := &IavlState{}
+	if err := s2.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Import("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if h := s2.Hash(); string(h) != string(hash) {
+		t.Errorf("imported tree hash is different: %x != %x", h, hash)
+	}
+	if c := s2.ImmutableTree("t1").Count(); c != 100 {
+		t.Errorf("imported tree size must be 100, but it is %d", c)
+	}
+	if v := s2.ImmutableTree("t1").Get([]byte("42")); string(v) != "number 42" {
+		t.Errorf("imported value for key 42 is wrong: %s", v)
+	}
+}
+
+func TestVersionsAfterImport(t *testing.T) {
+	t.Parallel()
+
+	s := &IavlState{}
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		s.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if _, err := s.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := s.Export("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// the import saves the version tree twice with the same tree version
+	s2 := &IavlState{}
+	if err := s2.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Import("t1", &buf); err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 12; i++ {
+		s2.Tree("t1").Add([]byte(fmt.Sprintf("%d", i)), []byte(fmt.Sprintf("number %d", i)))
+		if _, err := s2.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for v, count := range map[uint64]uint64{1: 10, 2: 10, 3: 11, 4: 12} {
+		tr := s2.TreeAtVersion("t1", v)
+		if tr == nil {
+			t.Fatalf("tree at version %d not found", v)
+		}
+		if c := tr.Count(); c != count {
+			t.Errorf("tree at version %d must have %d keys, but it has %d", v, count, c)
+		}
+	}
+
+	// the tree version shared by the state versions 1 and 2 must be kept
+	if _, err := s2.DeleteVersion(1); err != nil {
+		t.Fatal(err)
+	}
+	if tr := s2.TreeAtVersion("t1", 1); tr != nil {
+		t.Errorf("tree at deleted version 1 must not be found")
+	}
+	if tr := s2.TreeAtVersion("t1", 2); tr == nil || tr.Count() != 10 {
+		t.Errorf("tree at version 2 must keep 10 keys after deleting version 1")
+	}
+	if _, err := s2.DeleteVersion(3); err != nil {
+		t.Fatal(err)
+	}
+	if tr := s2.TreeAtVersion("t1", 4); tr == nil || tr.Count() != 12 {
+		t.Errorf("tree at version 4 must keep 12 keys after deleting version 3")
+	}
+}
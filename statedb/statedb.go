@@ -1,5 +1,7 @@
 package statedb
 
+import "io"
+
 type StateDB interface {
 	Init(storagePath, sorageType string) error
 	Version() uint64
@@ -7,17 +9,23 @@ type StateDB interface {
 	AddTree(name string) error
 	Tree(name string) StateTree
 	TreeWithRoot(root []byte) StateTree
-	ImmutableTree(name string) StateTree // a tree version that won't change
-	Commit() ([]byte, error)             // Returns New Hash
+	ImmutableTree(name string) StateTree                 // a tree version that won't change
+	TreeAtVersion(name string, version uint64) StateTree // a tree as commited on a state version, nil if not available
+	Commit() ([]byte, error)                             // Returns New Hash
 	Rollback() error
 	KeyDiff(root1, root2 []byte) ([][]byte, error) // list of inserted keys on root2 that are not present in root1
 	Hash() []byte
+	Export(name string, w io.Writer) error // dumps the last commited version of a tree
+	Import(name string, r io.Reader) error // restores an exported tree, the tree must be empty
+	Versions() []uint64                    // list of the commited versions available, sorted
+	DeleteVersion(uint64) (uint64, error)  // removes a commited version (not the last one), returns the bytes reclaimed
 	Close() error
 }
 
 type StateTree interface {
 	Get(key []byte) []byte
 	Add(key, value []byte) error
+	Delete(key []byte) error
 	Iterate(prefix []byte, callback func(key, value []byte) bool)
 	Hash() []byte
 	Count() uint64
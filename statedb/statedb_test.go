@@ -0,0 +1,96 @@
+package statedb_test
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"go.vocdoni.io/dvote/statedb"
+	"go.vocdoni.io/dvote/statedb/gravitonstate"
+	"go.vocdoni.io/dvote/statedb/iavlstate"
+)
+
+func TestIterate(t *testing.T) {
+	for name, s := range map[string]statedb.StateDB{
+		"iavl":     &iavlstate.IavlState{},
+		"graviton": &gravitonstate.GravitonState{},
+	} {
+		s := s
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testIterate(t, s)
+		})
+	}
+}
+
+func testIterate(t *testing.T, s statedb.StateDB) {
+	if err := s.Init(t.TempDir(), "disk"); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := s.AddTree("t1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LoadVersion(0); err != nil {
+		t.Fatal(err)
+	}
+	// the 0xff prefixes have no next prefix, so they are iterated up to the last key
+	keys := []string{"a", "ab", "ab\xff", "ab\xff\xff", "ac", "b", "\xff", "\xff\xff"}
+	for _, k := range keys {
+		if err := s.Tree("t1").Add([]byte(k), []byte("value "+k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	iterate := func(tree statedb.StateTree, prefix string) []string {
+		var found []string
+		tree.Iterate([]byte(prefix), func(k, v []byte) bool {
+			if string(v) != "value "+string(k) {
+				t.Errorf("wrong value %q for key %q", v, k)
+			}
+			found = append(found, string(k))
+			return false
+		})
+		sort.Strings(found)
+		return found
+	}
+	for prefix, expected := range map[string][]string{
+		"":       keys,
+		"a":      {"a", "ab", "ab\xff", "ab\xff\xff", "ac"},
+		"ab":     {"ab", "ab\xff", "ab\xff\xff"},
+		"ab\xff": {"ab\xff", "ab\xff\xff"},
+		"\xff":   {"\xff", "\xff\xff"},
+		"c":      nil,
+	} {
+		sort.Strings(expected)
+		if found := iterate(s.Tree("t1"), prefix); strings.Join(found, ",") != strings.Join(expected, ",") {
+			t.Errorf("prefix %q: got keys %q, expected %q", prefix, found, expected)
+		}
+	}
+
+	// the immutable tree iterates the commited keys only
+	if _, err := s.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Tree("t1").Add([]byte("ad"), []byte("value ad")); err != nil {
+		t.Fatal(err)
+	}
+	if found := iterate(s.ImmutableTree("t1"), "a"); len(found) != 5 {
+		t.Errorf("got %d commited keys with prefix a, expected 5", len(found))
+	}
+	if found := iterate(s.Tree("t1"), "a"); len(found) != 6 {
+		t.Errorf("got %d keys with prefix a, expected 6", len(found))
+	}
+
+	// returning true stops the iteration
+	for _, tree := range []statedb.StateTree{s.Tree("t1"), s.ImmutableTree("t1")} {
+		count := 0
+		tree.Iterate([]byte("a"), func(k, v []byte) bool {
+			count++
+			return count == 2
+		})
+		if count != 2 {
+			t.Errorf("iteration did not stop, %d keys iterated", count)
+		}
+	}
+}
@@ -30,12 +30,15 @@ type MetaRequest struct {
 	EntityId     HexBytes   `json:"entityId,omitempty"`
 	From         int64      `json:"from,omitempty"`
 	FromID       HexBytes   `json:"fromId,omitempty"`
+	Hash         HexBytes   `json:"hash,omitempty"`
+	Height       uint32     `json:"height,omitempty"`
 	ListSize     int64      `json:"listSize,omitempty"`
 	Method       string     `json:"method"`
 	Name         string     `json:"name,omitempty"`
 	Nullifier    HexBytes   `json:"nullifier,omitempty"`
 	Payload      []byte     `json:"payload,omitempty"`
 	ProcessID    HexBytes   `json:"processId,omitempty"`
+	ProcessIDs   []HexBytes `json:"processIds,omitempty"`
 	ProofData    HexBytes   `json:"proofData,omitempty"`
 	PubKeys      []string   `json:"pubKeys,omitempty"`
 	RootHash     HexBytes   `json:"rootHash,omitempty"`
@@ -92,45 +95,56 @@ type ResponseMessage struct {
 // Fields must be in alphabetical order
 // Those fields with valid zero-values (such as bool) must be pointers
 type MetaResponse struct {
-	APIList              []string   `json:"apiList,omitempty"`
-	BlockTime            *[5]int32  `json:"blockTime,omitempty"`
-	BlockTimestamp       int32      `json:"blockTimestamp,omitempty"`
-	CensusID             string     `json:"censusId,omitempty"`
-	CensusList           []string   `json:"censusList,omitempty"`
-	CensusKeys           [][]byte   `json:"censusKeys,omitempty"`
-	CensusValues         []HexBytes `json:"censusValues,omitempty"`
-	CensusDump           []byte     `json:"censusDump,omitempty"`
-	CommitmentKeys       []Key      `json:"commitmentKeys,omitempty"`
-	Content              []byte     `json:"content,omitempty"`
-	EncryptionPrivKeys   []Key      `json:"encryptionPrivKeys,omitempty"`
-	EncryptionPublicKeys []Key      `json:"encryptionPubKeys,omitempty"`
-	EntityID             string     `json:"entityId,omitempty"`
-	EntityIDs            []string   `json:"entityIds,omitempty"`
-	Files                []byte     `json:"files,omitempty"`
-	Finished             *bool      `json:"finished,omitempty"`
-	Health               int32      `json:"health,omitempty"`
-	Height               *uint32    `json:"height,omitempty"`
-	InvalidClaims        []int      `json:"invalidClaims,omitempty"`
-	Message              string     `json:"message,omitempty"`
-	Nullifier            string     `json:"nullifier,omitempty"`
-	Nullifiers           *[]string  `json:"nullifiers,omitempty"`
-	Ok                   bool       `json:"ok"`
-	Paused               *bool      `json:"paused,omitempty"`
-	Payload              string     `json:"payload,omitempty"` // TODO: sometimes hex, sometimes base64 - consolidate with protobuf
-	ProcessIDs           []string   `json:"processIds,omitempty"`
-	ProcessList          []string   `json:"processList,omitempty"`
-	Registered           *bool      `json:"registered,omitempty"`
-	Request              string     `json:"request"`
-	Results              [][]string `json:"results,omitempty"`
-	RevealKeys           []Key      `json:"revealKeys,omitempty"`
-	Root                 HexBytes   `json:"root,omitempty"`
-	Siblings             HexBytes   `json:"siblings,omitempty"`
-	Size                 *int64     `json:"size,omitempty"`
-	State                string     `json:"state,omitempty"`
-	Timestamp            int32      `json:"timestamp"`
-	Type                 string     `json:"type,omitempty"`
-	URI                  string     `json:"uri,omitempty"`
-	ValidProof           *bool      `json:"validProof,omitempty"`
+	APIList              []string          `json:"apiList,omitempty"`
+	Block                *BlockInfo        `json:"block,omitempty"`
+	BlockTime            *[5]int32         `json:"blockTime,omitempty"`
+	BlockTimestamp       int32             `json:"blockTimestamp,omitempty"`
+	CensusID             string            `json:"censusId,omitempty"`
+	CensusList           []string          `json:"censusList,omitempty"`
+	CensusKeys           [][]byte          `json:"censusKeys,omitempty"`
+	CensusValues         []HexBytes        `json:"censusValues,omitempty"`
+	CensusDump           []byte            `json:"censusDump,omitempty"`
+	CommitmentKeys       []Key             `json:"commitmentKeys,omitempty"`
+	Content              []byte            `json:"content,omitempty"`
+	EncryptionPrivKeys   []Key             `json:"encryptionPrivKeys,omitempty"`
+	EncryptionPublicKeys []Key             `json:"encryptionPubKeys,omitempty"`
+	EntityID             string            `json:"entityId,omitempty"`
+	EntityIDs            []string          `json:"entityIds,omitempty"`
+	Files                []byte            `json:"files,omitempty"`
+	Finished             *bool             `json:"finished,omitempty"`
+	Health               int32             `json:"health,omitempty"`
+	Height               *uint32           `json:"height,omitempty"`
+	InvalidClaims        []int             `json:"invalidClaims,omitempty"`
+	KeyKeeperEvidence    []KeyEvidence     `json:"keyKeeperEvidence,omitempty"`
+	MaxCensusSize        *uint64           `json:"maxCensusSize,omitempty"`
+	Message              string            `json:"message,omitempty"`
+	Nullifier            string            `json:"nullifier,omitempty"`
+	Nullifiers           *[]string         `json:"nullifiers,omitempty"`
+	Ok                   bool              `json:"ok"`
+	Paused               *bool             `json:"paused,omitempty"`
+	Payload              string            `json:"payload,omitempty"` // TODO: sometimes hex, sometimes base64 - consolidate with protobuf
+	PetitionThreshold    *uint64           `json:"petitionThreshold,omitempty"`
+	Process              *ProcessInfo      `json:"process,omitempty"`
+	ProcessIDs           []string          `json:"processIds,omitempty"`
+	ProcessList          []string          `json:"processList,omitempty"`
+	Processes            []*ProcessInfo    `json:"processes,omitempty"`
+	ProcessSummary       *ProcessSummary   `json:"processSummary,omitempty"`
+	Registered           *bool             `json:"registered,omitempty"`
+	Request              string            `json:"request"`
+	Results              [][]string        `json:"results,omitempty"`
+	RevealKeys           []Key             `json:"revealKeys,omitempty"`
+	Root                 HexBytes          `json:"root,omitempty"`
+	Siblings             HexBytes          `json:"siblings,omitempty"`
+	Size                 *int64            `json:"size,omitempty"`
+	State                string            `json:"state,omitempty"`
+	Timestamp            int32             `json:"timestamp"`
+	Tx                   *TxReference      `json:"tx,omitempty"`
+	TxError              *TxError          `json:"txError,omitempty"`
+	TxList               []*TxReference    `json:"txList,omitempty"`
+	Txs                  []json.RawMessage `json:"txs,omitempty"`
+	Type                 string            `json:"type,omitempty"`
+	URI                  string            `json:"uri,omitempty"`
+	ValidProof           *bool             `json:"validProof,omitempty"`
 }
 
 func (r MetaResponse) String() string {
@@ -169,15 +183,34 @@ func (r *MetaResponse) SetError(v interface{}) {
 	r.Message = fmt.Sprintf("%s", v)
 }
 
+// TxError is the machine-readable cause of a rejected vochain transaction,
+// the code is the ABCI result code and the name its stable identifier
+type TxError struct {
+	Code uint32 `json:"code"`
+	Name string `json:"name"`
+}
+
+// KeyEvidence is a keykeeper misbehavior recorded on a process, a revealed
+// key (or key share) which does not match the published one
+type KeyEvidence struct {
+	Height    int64  `json:"height"`
+	KeyIndex  uint32 `json:"keyIndex"`
+	KeyKeeper string `json:"keyKeeper"`
+	Reason    string `json:"reason"`
+}
+
 type CensusDump struct {
 	RootHash []byte `json:"rootHash"`
 	Data     []byte `json:"data"`
 }
 
-// VotePackage represents the payload of a vote (usually base64 encoded)
+// VotePackage represents the payload of a vote (usually base64 encoded).
+// On serial processes QuestionIndex is the question voted, and Votes holds
+// only the selected option of that question.
 type VotePackage struct {
-	Nonce string `json:"nonce,omitempty"`
-	Votes []int  `json:"votes"`
+	Nonce         string  `json:"nonce,omitempty"`
+	QuestionIndex *uint32 `json:"questionIndex,omitempty"`
+	Votes         []int   `json:"votes"`
 }
 
 type Key struct {
@@ -35,6 +35,12 @@ const (
 	EntityIDsizeV2 = 32
 	// VoteNullifierSize is the size of a vote nullifier
 	VoteNullifierSize = 32
+	// TxHashSize is the size of a Vochain transaction hash
+	TxHashSize = 32
+	// BlockHashSize is the size of a Vochain block hash
+	BlockHashSize = 32
+	// CommitmentKeySize is the size of a keykeeper commitment key (a Poseidon hash)
+	CommitmentKeySize = 32
 	// KeyIndexSeparator is the default char used to split keys
 	KeyIndexSeparator = ":"
 	// EthereumConfirmationsThreshold is the minimum amout of blocks
@@ -64,6 +70,17 @@ const (
 	ScrutinizerResultsPrefix = byte(0x24)
 	// ScrutinizerProcessEndingPrefix is the prefix for keep track of the processes ending on a specific block
 	ScrutinizerProcessEndingPrefix = byte(0x25)
+	// ScrutinizerStatsPrefix is the prefix of the process vote statistics keys
+	ScrutinizerStatsPrefix = byte(0x26)
+	// ScrutinizerBlockVotesPrefix is the prefix of the process votes per block keys
+	ScrutinizerBlockVotesPrefix = byte(0x27)
+
+	// Transaction indexer
+
+	// TxIndexerHashPrefix is the prefix of the transaction references indexed by hash
+	TxIndexerHashPrefix = byte(0x30)
+	// TxIndexerBlockPrefix is the prefix of the transaction hashes indexed by height and block position
+	TxIndexerBlockPrefix = byte(0x31)
 
 	// Vochain
 
@@ -19,6 +19,8 @@ type VotePackageStruct struct {
 	Type string `json:"type"`
 	// Nonce vote nonce
 	Nonce string `json:"nonce"`
+	// QuestionIndex on serial processes is the question voted
+	QuestionIndex *uint32 `json:"questionIndex,omitempty"`
 	// Votes directly mapped to the `questions` field of the process metadata
 	Votes []int `json:"votes"`
 }
@@ -69,6 +71,13 @@ type QueryData struct {
 type GenesisAppState struct {
 	Validators []GenesisValidator `json:"validators"`
 	Oracles    []string           `json:"oracles"`
+	// VerificationKeys are the zk-SNARK verification keys for anonymous voting (snarkjs format)
+	VerificationKeys []json.RawMessage `json:"verification_keys,omitempty"`
+	// ResultsQuorum is the number of oracles that must submit identical process results
+	ResultsQuorum uint32 `json:"results_quorum,omitempty"`
+	// KeyKeeperThreshold is the number of keykeeper key shares required to rebuild
+	// the key of a keykeeper (zero disables the threshold keys)
+	KeyKeeperThreshold uint32 `json:"keykeeper_threshold,omitempty"`
 }
 
 // The rest of these genesis app state types are copied from
@@ -129,6 +138,92 @@ type ScrutinizerOnProcessData struct {
 	ProcessID []byte
 }
 
+// TxReference holds the indexed information of a delivered Vochain transaction
+type TxReference struct {
+	Hash      HexBytes `json:"hash"`
+	Height    uint32   `json:"height"`
+	Index     int32    `json:"index"`
+	Type      string   `json:"type"`
+	Signer    HexBytes `json:"signer,omitempty"`
+	Code      uint32   `json:"code"`
+	ProcessID HexBytes `json:"processId,omitempty"`
+	EntityID  HexBytes `json:"entityId,omitempty"`
+}
+
+// ProcessSummary holds the vote statistics of a process computed by the scrutinizer
+type ProcessSummary struct {
+	EnvelopeCount uint64 `json:"envelopeCount"`
+	// TotalWeight is the sum of the weights of the current envelopes (decimal encoded)
+	TotalWeight     string       `json:"totalWeight"`
+	FirstVoteHeight uint32       `json:"firstVoteHeight,omitempty"`
+	LastVoteHeight  uint32       `json:"lastVoteHeight,omitempty"`
+	VotesPerBlock   []BlockVotes `json:"votesPerBlock,omitempty"`
+}
+
+// BlockVotes is the number of new envelopes of a process added on a block
+type BlockVotes struct {
+	Height uint32 `json:"height"`
+	Votes  uint32 `json:"votes"`
+}
+
+// BlockInfo holds the header and the transaction hashes of a Vochain block
+type BlockInfo struct {
+	Height          uint32     `json:"height"`
+	Hash            HexBytes   `json:"hash"`
+	LastBlockHash   HexBytes   `json:"lastBlockHash"`
+	ProposerAddress HexBytes   `json:"proposerAddress"`
+	AppHash         HexBytes   `json:"appHash"`
+	Timestamp       int64      `json:"timestamp"`
+	TxHashes        []HexBytes `json:"txHashes"`
+}
+
+// ProcessInfo holds the on-chain parameters of a process. The field names and
+// types are part of the API, new fields must be optional.
+type ProcessInfo struct {
+	ProcessID     HexBytes           `json:"processId"`
+	EntityID      HexBytes           `json:"entityId"`
+	Namespace     uint32             `json:"namespace"`
+	Status        string             `json:"status"`
+	CensusRoot    HexBytes           `json:"censusRoot"`
+	CensusURI     string             `json:"censusURI"`
+	CensusOrigin  string             `json:"censusOrigin"`
+	StartBlock    uint32             `json:"startBlock"`
+	BlockCount    uint32             `json:"blockCount"`
+	EnvelopeType  ProcessEnvelope    `json:"envelopeType"`
+	Mode          ProcessMode        `json:"mode"`
+	VoteOptions   ProcessVoteOptions `json:"voteOptions"`
+	KeyIndex      uint32             `json:"keyIndex"`
+	QuestionIndex uint32             `json:"questionIndex"`
+	QuestionCount uint32             `json:"questionCount"`
+	// Results are the results set by the oracles (decimal encoded), if any
+	Results [][]string `json:"results,omitempty"`
+}
+
+// ProcessEnvelope is the envelope type of a process
+type ProcessEnvelope struct {
+	Serial         bool `json:"serial"`
+	Anonymous      bool `json:"anonymous"`
+	EncryptedVotes bool `json:"encryptedVotes"`
+	UniqueValues   bool `json:"uniqueValues"`
+}
+
+// ProcessMode is the mode of a process
+type ProcessMode struct {
+	AutoStart         bool `json:"autoStart"`
+	Interruptible     bool `json:"interruptible"`
+	DynamicCensus     bool `json:"dynamicCensus"`
+	EncryptedMetaData bool `json:"encryptedMetaData"`
+}
+
+// ProcessVoteOptions are the vote options of a process
+type ProcessVoteOptions struct {
+	MaxCount          uint32 `json:"maxCount"`
+	MaxValue          uint32 `json:"maxValue"`
+	MaxVoteOverwrites uint32 `json:"maxVoteOverwrites"`
+	MaxTotalCost      uint32 `json:"maxTotalCost"`
+	CostExponent      uint32 `json:"costExponent"`
+}
+
 // _________________________ CENSUS ORIGINS __________________________
 
 type CensusProperties struct {
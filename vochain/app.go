@@ -14,6 +14,7 @@ import (
 	tmtypes "github.com/tendermint/tendermint/types"
 	"google.golang.org/protobuf/proto"
 
+	"go.vocdoni.io/dvote/crypto/snarks"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -21,15 +22,23 @@ import (
 
 // BaseApplication reflects the ABCI application implementation.
 type BaseApplication struct {
-	State *State
-	Node  *nm.Node
+	State     *State
+	Node      *nm.Node
+	snapshots *Snapshots
+	// txIndex is the position of the next delivered transaction in the block
+	txIndex int32
 }
 
 var _ abcitypes.Application = (*BaseApplication)(nil)
 
 // NewBaseApplication creates a new BaseApplication given a name an a DB backend
 func NewBaseApplication(dbpath string) (*BaseApplication, error) {
-	state, err := NewState(dbpath)
+	return NewBaseApplicationWithBackend(dbpath, StateBackendIAVL)
+}
+
+// NewBaseApplicationWithBackend creates a new BaseApplication using the given state backend
+func NewBaseApplicationWithBackend(dbpath, backend string) (*BaseApplication, error) {
+	state, err := NewStateWithBackend(dbpath, backend)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create vochain state: (%s)", err)
 	}
@@ -74,7 +83,8 @@ func (app *BaseApplication) Info(req abcitypes.RequestInfo) abcitypes.ResponseIn
 		height = header.Height
 	}
 	app.State.Rollback()
-	hash := app.State.AppHash(false)
+	// the hash of the last commited trees, this is what Commit returned for height
+	hash := app.State.WorkingHash()
 	log.Infof("replaying blocks. Current height %d, current APP hash %x", height, hash)
 	return abcitypes.ResponseInfo{
 		LastBlockHeight:  height,
@@ -115,6 +125,28 @@ func (app *BaseApplication) InitChain(req abcitypes.RequestInitChain) abcitypes.
 			log.Fatal(err)
 		}
 	}
+	// get zk-SNARK verification keys
+	for i, vkBytes := range genesisAppState.VerificationKeys {
+		vk := new(snarks.VerificationKey)
+		if err := json.Unmarshal(vkBytes, vk); err != nil {
+			log.Fatalf("cannot decode verification key %d: %s", i, err)
+		}
+		if _, err := app.State.AddVerificationKey(vk); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// set the number of oracles required to agree on process results
+	if genesisAppState.ResultsQuorum > 0 {
+		if err := app.State.SetResultsQuorum(genesisAppState.ResultsQuorum); err != nil {
+			log.Fatal(err)
+		}
+	}
+	// set the number of key shares required to rebuild a keykeeper key
+	if genesisAppState.KeyKeeperThreshold > 0 {
+		if err := app.State.SetKeyKeeperThreshold(genesisAppState.KeyKeeperThreshold); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	var header models.TendermintHeader
 	header.Height = 0
@@ -160,6 +192,7 @@ func (app *BaseApplication) BeginBlock(req abcitypes.RequestBeginBlock) abcitype
 	}
 	app.State.Unlock()
 	app.State.CachePurge(app.State.Header(true).Height)
+	app.txIndex = 0
 	return abcitypes.ResponseBeginBlock{}
 }
 
@@ -177,53 +210,103 @@ func (app *BaseApplication) CheckTx(req abcitypes.RequestCheckTx) abcitypes.Resp
 	if tx, err = UnmarshalTx(req.Tx); err == nil {
 		if data, err = AddTx(tx, app.State, TxKey(req.Tx), false); err != nil {
 			log.Debugf("checkTx error: %s", err)
-			return abcitypes.ResponseCheckTx{Code: 1, Data: []byte("addTx " + err.Error())}
+			return abcitypes.ResponseCheckTx{Code: TxErrorCode(err), Data: []byte("addTx " + err.Error())}
 		}
 	} else {
-		return abcitypes.ResponseCheckTx{Code: 1, Data: []byte("unmarshalTx " + err.Error())}
+		return abcitypes.ResponseCheckTx{Code: TxErrorCode(err), Data: []byte("unmarshalTx " + err.Error())}
 	}
 	return abcitypes.ResponseCheckTx{Code: 0, Data: data}
 }
 
 func (app *BaseApplication) DeliverTx(req abcitypes.RequestDeliverTx) abcitypes.ResponseDeliverTx {
-	var data []byte
-	var err error
-	var tx *models.Tx
-
-	if tx, err = UnmarshalTx(req.Tx); err == nil {
-		if data, err = AddTx(tx, app.State, TxKey(req.Tx), true); err != nil {
-			return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
+	var resp abcitypes.ResponseDeliverTx
+	txHash := TxKey(req.Tx)
+	tx, err := UnmarshalTx(req.Tx)
+	if err == nil {
+		if resp.Data, err = AddTx(tx, app.State, txHash, true); err != nil {
+			resp = abcitypes.ResponseDeliverTx{Code: TxErrorCode(err), Data: []byte(err.Error())}
 		}
 	} else {
-		return abcitypes.ResponseDeliverTx{Code: 1, Data: []byte(err.Error())}
+		tx = nil
+		resp = abcitypes.ResponseDeliverTx{Code: TxErrorCode(err), Data: []byte(err.Error())}
 	}
-	return abcitypes.ResponseDeliverTx{Code: 0, Data: data}
+	for _, l := range app.State.eventListeners {
+		l.OnTransaction(tx, txHash[:], app.txIndex, resp.Code)
+	}
+	app.txIndex++
+	return resp
 }
 
 func (app *BaseApplication) Commit() abcitypes.ResponseCommit {
+	hash := app.State.Save()
+	if app.snapshots != nil {
+		if h := app.State.Header(false); h != nil {
+			if err := app.snapshots.Take(h.Height); err != nil {
+				log.Errorf("cannot take state snapshot at height %d: %v", h.Height, err)
+			}
+		}
+	}
 	return abcitypes.ResponseCommit{
-		Data: app.State.Save(),
+		Data: hash,
 	}
 }
 
-func (app *BaseApplication) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
-	return abcitypes.ResponseQuery{}
-}
-
+// EndBlock signals the end of a block. The processes which reached their end
+// block are ended, so their results do not depend on an oracle transaction.
+// The validator set changes of the block are sent to Tendermint.
 func (app *BaseApplication) EndBlock(req abcitypes.RequestEndBlock) abcitypes.ResponseEndBlock {
-	return abcitypes.ResponseEndBlock{}
+	if err := app.State.EndProcesses(req.Height); err != nil {
+		log.Fatalf("cannot end the processes scheduled for block %d: %s", req.Height, err)
+	}
+	updates, err := app.State.ValidatorUpdates()
+	if err != nil {
+		log.Fatalf("cannot get the validator updates of block %d: %s", req.Height, err)
+	}
+	for _, u := range updates {
+		log.Infof("validator %x power updated to %d", u.PubKey.GetEd25519(), u.Power)
+	}
+	return abcitypes.ResponseEndBlock{ValidatorUpdates: updates}
 }
+
+// ApplySnapshotChunk applies a chunk of the snapshot being restored, the state
+// trees are imported once the last chunk is received.
 func (app *BaseApplication) ApplySnapshotChunk(req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
-	return abcitypes.ResponseApplySnapshotChunk{}
+	if app.snapshots == nil {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	return app.snapshots.ApplyChunk(req)
 }
+
+// ListSnapshots returns the state snapshots available for other peers
 func (app *BaseApplication) ListSnapshots(req abcitypes.RequestListSnapshots) abcitypes.ResponseListSnapshots {
-	return abcitypes.ResponseListSnapshots{}
+	if app.snapshots == nil {
+		return abcitypes.ResponseListSnapshots{}
+	}
+	snapshots, err := app.snapshots.List()
+	if err != nil {
+		log.Errorf("cannot list state snapshots: %v", err)
+	}
+	return abcitypes.ResponseListSnapshots{Snapshots: snapshots}
 }
+
+// LoadSnapshotChunk returns a chunk of a local snapshot
 func (app *BaseApplication) LoadSnapshotChunk(req abcitypes.RequestLoadSnapshotChunk) abcitypes.ResponseLoadSnapshotChunk {
-	return abcitypes.ResponseLoadSnapshotChunk{}
+	if app.snapshots == nil || req.Format != snapshotFormat {
+		return abcitypes.ResponseLoadSnapshotChunk{}
+	}
+	chunk, err := app.snapshots.LoadChunk(req.Height, req.Chunk)
+	if err != nil {
+		log.Warnf("cannot load snapshot chunk: %v", err)
+	}
+	return abcitypes.ResponseLoadSnapshotChunk{Chunk: chunk}
 }
+
+// OfferSnapshot is called by Tendermint when a peer offers a snapshot for state sync
 func (app *BaseApplication) OfferSnapshot(req abcitypes.RequestOfferSnapshot) abcitypes.ResponseOfferSnapshot {
-	return abcitypes.ResponseOfferSnapshot{}
+	if app.snapshots == nil {
+		return abcitypes.ResponseOfferSnapshot{Result: abcitypes.ResponseOfferSnapshot_REJECT}
+	}
+	return abcitypes.ResponseOfferSnapshot{Result: app.snapshots.Offer(req.Snapshot, req.AppHash)}
 }
 
 func TxKey(tx tmtypes.Tx) [32]byte {
@@ -2,6 +2,7 @@ package vochain
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -9,6 +10,7 @@ import (
 	"strconv"
 
 	"go.vocdoni.io/dvote/censustree/gravitontree"
+	"go.vocdoni.io/dvote/censustree/iden3tree"
 	"go.vocdoni.io/dvote/config"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/log"
@@ -43,8 +45,27 @@ func checkProof(proof *models.Proof, censusOrigin models.CensusOrigin, censusRoo
 			valid, err := gravitontree.CheckProof(key, []byte{}, censusRoot, p.Siblings)
 			return valid, big.NewInt(1), err
 		case *models.Proof_Iden3:
-			// NOT IMPLEMENTED
-			return false, nil, fmt.Errorf("iden3 proof not implemented")
+			p := proof.GetIden3()
+			if p == nil {
+				return false, nil, fmt.Errorf("iden3 proof is empty")
+			}
+			// on weighted census the leaf value (the weight) is packed with the proof
+			mproof, value, err := iden3tree.UnpackProof(p.Siblings)
+			if err != nil {
+				return false, nil, fmt.Errorf("cannot decode iden3 proof: %w", err)
+			}
+			valid, err := iden3tree.CheckProof(censusRoot, mproof, key, value)
+			if err != nil || !valid {
+				return false, nil, err
+			}
+			if len(value) == 0 {
+				return true, big.NewInt(1), nil
+			}
+			weight := new(big.Int).SetBytes(value)
+			if weight.Sign() == 0 {
+				return false, nil, fmt.Errorf("census weight is zero")
+			}
+			return true, weight, nil
 		}
 	case models.CensusOrigin_OFF_CHAIN_CA:
 		p := proof.GetCa()
@@ -112,6 +133,14 @@ func GenerateNullifier(address ethcommon.Address, processID []byte) []byte {
 	return ethereum.HashRaw([]byte(fmt.Sprintf("%s%s", address.Bytes(), processID)))
 }
 
+// GenerateQuestionNullifier returns the nullifier of a vote on a serial
+// process, each question is voted separately so it depends on the question
+func GenerateQuestionNullifier(address ethcommon.Address, processID []byte, questionIndex uint32) []byte {
+	index := make([]byte, 4)
+	binary.BigEndian.PutUint32(index, questionIndex)
+	return ethereum.HashRaw([]byte(fmt.Sprintf("%s%s%s", address.Bytes(), processID, index)))
+}
+
 // NewPrivateValidator returns a tendermint file private validator (key and state)
 // if tmPrivKey not specified, uses the existing one or generates a new one
 func NewPrivateValidator(tmPrivKey string, tconfig *cfg.Config) (*privval.FilePV, error) {
@@ -1,56 +1,200 @@
 package vochain
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync/atomic"
 	"time"
 
+	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
 )
 
-// CacheAdd adds a new vote proof to the local cache
+// cacheRecord is the vote cache entry stored on the vote cache database. The
+// proof is protobuf encoded, since its payload cannot be decoded from JSON.
+type cacheRecord struct {
+	Type         *models.TxType `json:"type,omitempty"`
+	Proof        []byte         `json:"proof,omitempty"`
+	PubKey       []byte         `json:"pubKey,omitempty"`
+	PubKeyDigest []byte         `json:"pubKeyDigest,omitempty"`
+	Nullifier    []byte         `json:"nullifier,omitempty"`
+	Weight       []byte         `json:"weight,omitempty"`
+	Created      time.Time      `json:"timestamp"`
+}
+
+func marshalCacheTx(vc *types.CacheTx) ([]byte, error) {
+	r := cacheRecord{
+		Type:         vc.Type,
+		PubKey:       vc.PubKey,
+		PubKeyDigest: vc.PubKeyDigest,
+		Nullifier:    vc.Nullifier,
+		Created:      vc.Created,
+	}
+	if vc.Proof != nil {
+		var err error
+		if r.Proof, err = proto.Marshal(vc.Proof); err != nil {
+			return nil, err
+		}
+	}
+	if vc.Weight != nil {
+		r.Weight = vc.Weight.Bytes()
+	}
+	return json.Marshal(r)
+}
+
+func unmarshalCacheTx(data []byte) (*types.CacheTx, error) {
+	var r cacheRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	vc := &types.CacheTx{
+		Type:         r.Type,
+		PubKey:       r.PubKey,
+		PubKeyDigest: r.PubKeyDigest,
+		Nullifier:    r.Nullifier,
+		Created:      r.Created,
+	}
+	if r.Proof != nil {
+		vc.Proof = new(models.Proof)
+		if err := proto.Unmarshal(r.Proof, vc.Proof); err != nil {
+			return nil, err
+		}
+	}
+	if r.Weight != nil {
+		vc.Weight = new(big.Int).SetBytes(r.Weight)
+	}
+	return vc, nil
+}
+
+// SetVoteCacheDB makes the vote cache persistent, storing its entries on the
+// database. The entries already stored (not yet expired) are loaded, so the
+// votes checked before a restart do not need to be verified again.
+func (v *State) SetVoteCacheDB(database db.Database) error {
+	v.voteCacheLock.Lock()
+	defer v.voteCacheLock.Unlock()
+	iter := database.NewIterator()
+	var expired [][]byte
+	loaded := 0
+	for iter.Next() {
+		if len(iter.Key()) != 32 {
+			continue
+		}
+		var id [32]byte
+		copy(id[:], iter.Key())
+		vc, err := unmarshalCacheTx(iter.Value())
+		if err != nil {
+			log.Warnf("[txcache] cannot unmarshal cached vote %x: %v", id, err)
+			expired = append(expired, id[:])
+			continue
+		}
+		if time.Since(vc.Created) > voteCachePurgeThreshold {
+			expired = append(expired, id[:])
+			continue
+		}
+		v.voteCache[id] = vc
+		loaded++
+	}
+	iter.Release()
+	for _, id := range expired {
+		if err := database.Del(id); err != nil {
+			return fmt.Errorf("cannot delete expired cached vote: %w", err)
+		}
+	}
+	v.voteCacheDB = database
+	log.Infof("[txcache] loaded %d transactions, %d expired", loaded, len(expired))
+	return nil
+}
+
+// CacheAdd adds a new vote proof to the local cache. The vote is stored on the
+// database after releasing the cache lock, so the lookups do not wait for it.
 func (v *State) CacheAdd(id [32]byte, vc *types.CacheTx) {
 	if len(id) == 0 {
 		return
 	}
 	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
 	v.voteCache[id] = vc
+	database := v.voteCacheDB
+	v.voteCacheLock.Unlock()
+	if database == nil {
+		return
+	}
+	data, err := marshalCacheTx(vc)
+	if err == nil {
+		err = database.Put(id[:], data)
+	}
+	if err != nil {
+		log.Warnf("[txcache] cannot store vote %x: %v", id, err)
+	}
 }
 
 // CacheDel deletes an existing vote proof from the local cache
 func (v *State) CacheDel(id [32]byte) {
 	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
 	delete(v.voteCache, id)
+	database := v.voteCacheDB
+	v.voteCacheLock.Unlock()
+	if database == nil {
+		return
+	}
+	if err := database.Del(id[:]); err != nil {
+		log.Warnf("[txcache] cannot delete vote %x: %v", id, err)
+	}
 }
 
 // CacheGet fetch an existing vote proof from the local cache
 func (v *State) CacheGet(id [32]byte) *types.CacheTx {
 	v.voteCacheLock.RLock()
 	defer v.voteCacheLock.RUnlock()
-	return v.voteCache[id]
+	vc := v.voteCache[id]
+	if vc != nil {
+		atomic.AddUint64(&v.voteCacheHits, 1)
+	} else {
+		atomic.AddUint64(&v.voteCacheMisses, 1)
+	}
+	return vc
 }
 
-// CachePurge removes the old cache saved votes
+// CachePurge removes the old cache saved votes. The purged votes are deleted
+// from the database in a single batch, after releasing the cache lock.
 func (v *State) CachePurge(height int64) {
 	if height%6 != 0 {
 		return
 	}
 	v.voteCacheLock.Lock()
-	defer v.voteCacheLock.Unlock()
+	var expired [][32]byte
 	purged := 0
 	for id, vp := range v.voteCache {
 		if time.Since(vp.Created) > voteCachePurgeThreshold {
 			delete(v.voteCache, id)
+			expired = append(expired, id)
 			if v.MemPoolRemoveTxKey != nil {
 				v.MemPoolRemoveTxKey(id, true)
 				purged++
 			}
 		}
 	}
+	database := v.voteCacheDB
+	v.voteCacheLock.Unlock()
 	if purged > 0 {
 		log.Infof("[txcache] purged %d transactions", purged)
 	}
+	if database == nil || len(expired) == 0 {
+		return
+	}
+	batch := database.NewBatch()
+	for j := range expired {
+		// the batch keeps the key until it is written, so do not reuse it
+		if err := batch.Del(expired[j][:]); err != nil {
+			log.Warnf("[txcache] cannot delete vote %x: %v", expired[j], err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Warnf("[txcache] cannot delete %d purged votes: %v", len(expired), err)
+	}
 }
 
 // CacheSize returns the current size of the vote cache
@@ -59,3 +203,9 @@ func (v *State) CacheSize() int {
 	defer v.voteCacheLock.RUnlock()
 	return len(v.voteCache)
 }
+
+// CacheStats returns the number of vote cache lookups which found the vote
+// (hits) and which did not (misses)
+func (v *State) CacheStats() (hits, misses uint64) {
+	return atomic.LoadUint64(&v.voteCacheHits), atomic.LoadUint64(&v.voteCacheMisses)
+}
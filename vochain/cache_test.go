@@ -0,0 +1,91 @@
+package vochain
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+	"time"
+
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestVoteCachePersistence(t *testing.T) {
+	dbDir := t.TempDir()
+	database, err := db.NewBadgerDB(dbDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetVoteCacheDB(database); err != nil {
+		t.Fatal(err)
+	}
+	var fresh, old, deleted [32]byte
+	copy(fresh[:], util.RandomBytes(32))
+	copy(old[:], util.RandomBytes(32))
+	copy(deleted[:], util.RandomBytes(32))
+	vc := &types.CacheTx{
+		Proof:        &models.Proof{Payload: &models.Proof_Graviton{Graviton: &models.ProofGraviton{Siblings: util.RandomBytes(64)}}},
+		PubKey:       util.RandomBytes(33),
+		PubKeyDigest: util.RandomBytes(32),
+		Nullifier:    util.RandomBytes(32),
+		Weight:       big.NewInt(42),
+		Created:      time.Now(),
+	}
+	s.CacheAdd(fresh, vc)
+	s.CacheAdd(old, &types.CacheTx{Nullifier: util.RandomBytes(32), Created: time.Now().Add(-2 * voteCachePurgeThreshold)})
+	s.CacheAdd(deleted, &types.CacheTx{Nullifier: util.RandomBytes(32), Created: time.Now()})
+	s.CacheDel(deleted)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the entries are loaded after a restart, except the expired ones
+	if database, err = db.NewBadgerDB(dbDir); err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	if s, err = NewState(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetVoteCacheDB(database); err != nil {
+		t.Fatal(err)
+	}
+	if size := s.CacheSize(); size != 1 {
+		t.Fatalf("got %d cached votes, expected 1", size)
+	}
+	loaded := s.CacheGet(fresh)
+	if loaded == nil {
+		t.Fatal("cached vote not loaded")
+	}
+	if !bytes.Equal(loaded.Nullifier, vc.Nullifier) || !bytes.Equal(loaded.PubKey, vc.PubKey) ||
+		!bytes.Equal(loaded.PubKeyDigest, vc.PubKeyDigest) || loaded.Weight.Cmp(vc.Weight) != 0 ||
+		!proto.Equal(loaded.Proof, vc.Proof) || !loaded.Created.Equal(vc.Created) {
+		t.Errorf("loaded cached vote %+v, expected %+v", loaded, vc)
+	}
+	if s.CacheGet(old) != nil || s.CacheGet(deleted) != nil {
+		t.Error("expired or deleted vote loaded")
+	}
+	if has, err := database.Has(old[:]); err != nil || has {
+		t.Errorf("expired vote not deleted from the database (%v)", err)
+	}
+	if hits, misses := s.CacheStats(); hits != 1 || misses != 2 {
+		t.Errorf("got %d hits and %d misses, expected 1 and 2", hits, misses)
+	}
+
+	// the purged votes are deleted from the database
+	s.CacheAdd(old, &types.CacheTx{Nullifier: util.RandomBytes(32), Created: time.Now().Add(-2 * voteCachePurgeThreshold)})
+	s.CachePurge(6)
+	if has, err := database.Has(old[:]); err != nil || has {
+		t.Errorf("purged vote not deleted from the database (%v)", err)
+	}
+	if has, err := database.Has(fresh[:]); err != nil || !has {
+		t.Errorf("fresh vote deleted from the database (%v)", err)
+	}
+}
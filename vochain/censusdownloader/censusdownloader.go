@@ -76,8 +76,10 @@ func (c *CensusDownloader) OnProcess(pid, eid []byte, censusRoot, censusURI stri
 }
 
 // NOT USED but required for implementing the interface
-func (c *CensusDownloader) OnCancel(pid []byte)                                           {}
-func (c *CensusDownloader) OnVote(v *models.Vote)                                         {}
-func (c *CensusDownloader) OnProcessKeys(pid []byte, pub, com string)                     {}
-func (c *CensusDownloader) OnRevealKeys(pid []byte, priv, rev string)                     {}
-func (c *CensusDownloader) OnProcessStatusChange(pid []byte, status models.ProcessStatus) {}
+func (c *CensusDownloader) OnCancel(pid []byte)                                                  {}
+func (c *CensusDownloader) OnVote(v *models.Vote)                                                {}
+func (c *CensusDownloader) OnVoteOverwrite(v, previous *models.Vote)                             {}
+func (c *CensusDownloader) OnProcessKeys(pid []byte, pub, com string)                            {}
+func (c *CensusDownloader) OnRevealKeys(pid []byte, priv, rev string)                            {}
+func (c *CensusDownloader) OnProcessStatusChange(pid []byte, status models.ProcessStatus)        {}
+func (c *CensusDownloader) OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32) {}
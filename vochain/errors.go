@@ -0,0 +1,82 @@
+package vochain
+
+import "errors"
+
+// ABCI result codes of the rejected transactions. The codes are part of the
+// API (clients branch on them), so existing values must never change.
+const (
+	CodeTxInvalid          = 1
+	CodeTxRateLimited      = 2
+	CodeTxMalformed        = 3
+	CodeProcessNotFound    = 4
+	CodeProcessNotActive   = 5
+	CodeInvalidBlockFrame  = 6
+	CodeInvalidProof       = 7
+	CodeDuplicateNullifier = 8
+	CodeDuplicateTx        = 9
+	CodeUnauthorized       = 10
+	CodeInvalidSignature   = 11
+	CodeProcessExists      = 12
+	CodeInvalidQuestion    = 13
+	CodeMaxCensusSize      = 14
+	CodeKeyMismatch        = 15
+)
+
+// TxError is a transaction rejection cause with a stable ABCI result code and
+// a machine-readable name. The errors returned by the transaction checks wrap
+// one of them (with fmt.Errorf and %w) to add the details.
+type TxError struct {
+	Code    uint32
+	Name    string
+	Message string
+}
+
+func (e *TxError) Error() string {
+	return e.Message
+}
+
+// The transaction errors catalogue
+var (
+	ErrTxRateLimited      = &TxError{CodeTxRateLimited, "rateLimited", "transaction rate limit exceeded"}
+	ErrTxMalformed        = &TxError{CodeTxMalformed, "malformedTx", "cannot decode transaction"}
+	ErrProcessNotFound    = &TxError{CodeProcessNotFound, "processNotFound", "process not found"}
+	ErrProcessNotActive   = &TxError{CodeProcessNotActive, "processNotActive", "process is not accepting votes"}
+	ErrInvalidBlockFrame  = &TxError{CodeInvalidBlockFrame, "invalidBlockFrame", "invalid block frame"}
+	ErrInvalidProof       = &TxError{CodeInvalidProof, "invalidProof", "proof not valid"}
+	ErrDuplicateNullifier = &TxError{CodeDuplicateNullifier, "duplicateNullifier", "vote already exists"}
+	ErrDuplicateTx        = &TxError{CodeDuplicateTx, "duplicateTx", "transaction already in the mempool"}
+	ErrUnauthorized       = &TxError{CodeUnauthorized, "unauthorized", "signer is not authorized"}
+	ErrInvalidSignature   = &TxError{CodeInvalidSignature, "invalidSignature", "invalid or missing signature"}
+	ErrProcessExists      = &TxError{CodeProcessExists, "processExists", "process already exists"}
+	ErrInvalidQuestion    = &TxError{CodeInvalidQuestion, "invalidQuestionIndex", "vote does not target the current question"}
+	ErrMaxCensusSize      = &TxError{CodeMaxCensusSize, "maxCensusSizeReached", "process reached its max census size"}
+	ErrKeyMismatch        = &TxError{CodeKeyMismatch, "keyMismatch", "revealed key does not match the published key"}
+)
+
+var txErrors = []*TxError{
+	ErrTxRateLimited, ErrTxMalformed, ErrProcessNotFound, ErrProcessNotActive,
+	ErrInvalidBlockFrame, ErrInvalidProof, ErrDuplicateNullifier, ErrDuplicateTx,
+	ErrUnauthorized, ErrInvalidSignature, ErrProcessExists, ErrInvalidQuestion,
+	ErrMaxCensusSize, ErrKeyMismatch,
+}
+
+// TxErrorCode returns the ABCI result code of a transaction error, which is
+// CodeTxInvalid if the error does not wrap one of the catalogue
+func TxErrorCode(err error) uint32 {
+	var txErr *TxError
+	if errors.As(err, &txErr) {
+		return txErr.Code
+	}
+	return CodeTxInvalid
+}
+
+// TxErrorName returns the name of an ABCI result code, or "invalidTx" for the
+// generic and unknown codes
+func TxErrorName(code uint32) string {
+	for _, e := range txErrors {
+		if e.Code == code {
+			return e.Name
+		}
+	}
+	return "invalidTx"
+}
@@ -0,0 +1,88 @@
+package vochain
+
+import (
+	"fmt"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTxErrorCodes(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddOracle(oracle.Address()); err != nil {
+		t.Fatal(err)
+	}
+	pausedProcess := testNewProcess(0, 100)
+	pausedProcess.Status = models.ProcessStatus_PAUSED
+	paused := testAddProcess(t, app, pausedProcess)
+	future := testAddProcess(t, app, testNewProcess(10, 100))
+
+	marshal := func(vtx *models.Tx) []byte {
+		txBytes, err := proto.Marshal(vtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return txBytes
+	}
+	vote := func(pid []byte) []byte {
+		return marshal(&models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+			ProcessId: pid,
+			Nonce:     util.RandomBytes(32),
+		}}})
+	}
+	signer := ethereum.NewSignKeys()
+	if err := signer.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	admin := &models.AdminTx{Txtype: models.TxType_ADD_ORACLE, Address: util.RandomBytes(20), Nonce: util.RandomBytes(32)}
+	adminBytes, err := proto.Marshal(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.Sign(adminBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		tx   []byte
+		code uint32
+	}{
+		{"malformedTx", []byte{0x0a, 0xff}, CodeTxMalformed},
+		{"processNotFound", vote(util.RandomBytes(types.ProcessIDsize)), CodeProcessNotFound},
+		{"processNotActive", vote(paused), CodeProcessNotActive},
+		{"invalidBlockFrame", vote(future), CodeInvalidBlockFrame},
+		{"unauthorized", marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: admin}, Signature: signature}), CodeUnauthorized},
+		{"invalidSignature", marshal(&models.Tx{Payload: &models.Tx_Admin{Admin: admin}}), CodeInvalidSignature},
+	} {
+		res := app.CheckTx(abcitypes.RequestCheckTx{Tx: tc.tx})
+		if res.Code != tc.code {
+			t.Errorf("%s: got code %d (%s), expected %d", tc.name, res.Code, res.Data, tc.code)
+		}
+		if name := TxErrorName(res.Code); name != tc.name {
+			t.Errorf("%s: got error name %s", tc.name, name)
+		}
+	}
+
+	if code := TxErrorCode(fmt.Errorf("unknown error")); code != CodeTxInvalid {
+		t.Errorf("got code %d for an error out of the catalogue", code)
+	}
+	if name := TxErrorName(CodeTxInvalid); name != "invalidTx" {
+		t.Errorf("got name %s for the generic code", name)
+	}
+}
@@ -0,0 +1,169 @@
+package vochain
+
+import (
+	"fmt"
+	"sort"
+
+	"go.vocdoni.io/dvote/statedb"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// The AtHeight accessors read the state trees as they were commited at the end
+// of a block. Height zero means the last commited block, as isQuery does on the
+// regular accessors. Heights pruned from the state database are not available.
+
+// treesAtHeight returns the state trees commited at the block height.
+// It must be called with the state read locked.
+func (v *State) treesAtHeight(height uint32) (map[string]statedb.StateTree, error) {
+	trees := make(map[string]statedb.StateTree, len(stateTrees))
+	if height == 0 {
+		for _, name := range stateTrees {
+			trees[name] = v.Store.ImmutableTree(name)
+		}
+		return trees, nil
+	}
+	version, err := v.versionAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range stateTrees {
+		if trees[name] = v.Store.TreeAtVersion(name, version); trees[name] == nil {
+			return nil, fmt.Errorf("tree %s at height %d is not available", name, height)
+		}
+	}
+	return trees, nil
+}
+
+// versionAtHeight returns the state version commited at the block height. The
+// header of each version holds its height, so the version is first guessed from
+// the last one and otherwise searched among the available versions.
+// It must be called with the state read locked.
+func (v *State) versionAtHeight(height uint32) (uint64, error) {
+	last := v.Store.Version()
+	lastHeight := v.heightAtVersion(last)
+	if int64(height) > lastHeight {
+		return 0, fmt.Errorf("height %d is not commited yet, last height is %d", height, lastHeight)
+	}
+	if diff := uint64(lastHeight) - uint64(height); diff < last {
+		if v.heightAtVersion(last-diff) == int64(height) {
+			return last - diff, nil
+		}
+	}
+	versions := v.Store.Versions()
+	i := sort.Search(len(versions), func(i int) bool {
+		return v.heightAtVersion(versions[i]) >= int64(height)
+	})
+	if i < len(versions) && v.heightAtVersion(versions[i]) == int64(height) {
+		return versions[i], nil
+	}
+	return 0, fmt.Errorf("state at height %d is not available", height)
+}
+
+// heightAtVersion returns the block height of a state version, or -1 if the
+// version is not available
+func (v *State) heightAtVersion(version uint64) int64 {
+	tree := v.Store.TreeAtVersion(AppTree, version)
+	if tree == nil {
+		return -1
+	}
+	var header models.TendermintHeader
+	if err := proto.Unmarshal(tree.Get(headerKey), &header); err != nil {
+		return -1
+	}
+	return header.Height
+}
+
+// ProcessAtHeight returns the process as it was at the block height
+func (v *State) ProcessAtHeight(pid []byte, height uint32) (*models.Process, error) {
+	v.RLock()
+	defer v.RUnlock()
+	trees, err := v.treesAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	processBytes := trees[ProcessTree].Get(pid)
+	if processBytes == nil {
+		return nil, ErrProcessNotFound
+	}
+	process := new(models.Process)
+	if err := proto.Unmarshal(processBytes, process); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal process (%x): %w", pid, err)
+	}
+	return process, nil
+}
+
+// EnvelopeAtHeight returns a vote as it was at the block height
+func (v *State) EnvelopeAtHeight(processID, nullifier []byte, height uint32) (_ *models.Vote, err error) {
+	// TODO(mvdan): remove the recover once
+	// https://github.com/tendermint/iavl/issues/212 is fixed
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered panic: %v", r)
+		}
+	}()
+	vid, err := v.voteID(processID, nullifier)
+	if err != nil {
+		return nil, err
+	}
+	v.RLock()
+	defer v.RUnlock()
+	trees, err := v.treesAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	voteBytes := trees[VoteTree].Get(vid)
+	if voteBytes == nil {
+		return nil, fmt.Errorf("vote with id (%x) does not exist", vid)
+	}
+	vote := new(models.Vote)
+	if err := proto.Unmarshal(voteBytes, vote); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal vote with id (%x)", vid)
+	}
+	return vote, nil
+}
+
+// CountVotesAtHeight returns the number of votes a process had at the block height
+func (v *State) CountVotesAtHeight(processID []byte, height uint32) (uint32, error) {
+	v.RLock()
+	defer v.RUnlock()
+	trees, err := v.treesAtHeight(height)
+	if err != nil {
+		return 0, err
+	}
+	var count uint32
+	trees[VoteTree].Iterate(processID, func(key []byte, value []byte) bool {
+		count++
+		return false
+	})
+	return count, nil
+}
+
+// EnvelopeListAtHeight returns the list of envelope nullifiers a process had at the block height
+func (v *State) EnvelopeListAtHeight(processID []byte, from, listSize int64, height uint32) (nullifiers [][]byte, err error) {
+	// TODO(mvdan): remove the recover once
+	// https://github.com/tendermint/iavl/issues/212 is fixed
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("recovered panic: %v", r)
+		}
+	}()
+	v.RLock()
+	defer v.RUnlock()
+	trees, err := v.treesAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	idx := int64(0)
+	trees[VoteTree].Iterate(processID, func(key []byte, value []byte) bool {
+		if idx >= from+listSize {
+			return true
+		}
+		if idx >= from {
+			nullifiers = append(nullifiers, key[32:])
+		}
+		idx++
+		return false
+	})
+	return nullifiers, nil
+}
@@ -0,0 +1,138 @@
+package vochain
+
+import (
+	"bytes"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestStateAtHeight(t *testing.T) {
+	for _, backend := range []string{StateBackendIAVL, StateBackendGraviton} {
+		t.Run(backend, func(t *testing.T) {
+			testStateAtHeight(t, backend)
+		})
+	}
+}
+
+func testStateAtHeight(t *testing.T, backend string) {
+	app, err := NewBaseApplicationWithBackend(t.TempDir(), backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	nullifier := util.RandomBytes(types.VoteNullifierSize)
+
+	// block 1 creates the process, block 2 adds a vote and block 3 ends the process
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId: pid,
+		EntityId:  util.RandomBytes(types.EntityIDsize),
+		Status:    models.ProcessStatus_READY,
+		Mode:      &models.ProcessMode{Interruptible: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 2}})
+	if err := app.State.AddVote(&models.Vote{ProcessId: pid, Nullifier: nullifier, VotePackage: []byte("1")}); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 3}})
+	if err := app.State.SetProcessStatus(pid, models.ProcessStatus_ENDED, true); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	for height, status := range map[uint32]models.ProcessStatus{
+		1: models.ProcessStatus_READY,
+		2: models.ProcessStatus_READY,
+		3: models.ProcessStatus_ENDED,
+		0: models.ProcessStatus_ENDED,
+	} {
+		p, err := app.State.ProcessAtHeight(pid, height)
+		if err != nil {
+			t.Fatalf("height %d: %v", height, err)
+		}
+		if p.Status != status {
+			t.Errorf("height %d: got process status %s, expected %s", height, p.Status, status)
+		}
+	}
+	if _, err := app.State.ProcessAtHeight(pid, 4); err == nil {
+		t.Errorf("got a process at a height not commited yet")
+	}
+
+	if _, err := app.State.EnvelopeAtHeight(pid, nullifier, 1); err == nil {
+		t.Errorf("got a vote before it was added")
+	}
+	vote, err := app.State.EnvelopeAtHeight(pid, nullifier, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(vote.VotePackage, []byte("1")) {
+		t.Errorf("got vote package %q", vote.VotePackage)
+	}
+	for height, expected := range map[uint32]uint32{1: 0, 2: 1, 3: 1} {
+		if count, err := app.State.CountVotesAtHeight(pid, height); err != nil || count != expected {
+			t.Errorf("height %d: got %d votes (%v), expected %d", height, count, err, expected)
+		}
+		nullifiers, err := app.State.EnvelopeListAtHeight(pid, 0, 64, height)
+		if err != nil || len(nullifiers) != int(expected) {
+			t.Errorf("height %d: got %d nullifiers (%v), expected %d", height, len(nullifiers), err, expected)
+		}
+	}
+
+	// the ABCI query at a past height proves the value as it was at that height,
+	// the query proofs are only supported by the iavl backend
+	res := app.Query(abcitypes.RequestQuery{Path: QueryPathProcess, Data: pid, Height: 1})
+	if backend != StateBackendIAVL {
+		if res.Code == 0 {
+			t.Errorf("query proof on a %s state must fail", backend)
+		}
+		return
+	}
+	if res.Code != 0 || res.Height != 1 || len(res.ProofOps.GetOps()) != 1+len(stateTrees) {
+		t.Fatalf("query at height 1 failed: %+v", res)
+	}
+	// the process tree root is the second root operation (sorted by tree name)
+	if bytes.Equal(res.ProofOps.Ops[2].Data, app.State.Store.ImmutableTree(ProcessTree).Hash()) {
+		t.Errorf("query at height 1 returned the last process tree root")
+	}
+	if !app.State.Store.ImmutableTree(ProcessTree).Verify(pid, res.ProofOps.Ops[0].Data, res.ProofOps.Ops[2].Data) {
+		t.Errorf("cannot verify the proof of a past height")
+	}
+}
+
+func TestStateAtPrunedHeight(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.SetPruningPolicy(PruningPolicy{Strategy: PruningKeepRecent, KeepRecent: 3}); err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	for h := int64(1); h <= 10; h++ {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: h}})
+		if h == 1 {
+			if err := app.State.AddProcess(&models.Process{
+				ProcessId: pid,
+				EntityId:  util.RandomBytes(types.EntityIDsize),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		app.Commit()
+	}
+	if _, err := app.State.ProcessAtHeight(pid, 2); err == nil {
+		t.Errorf("got a process at a pruned height")
+	}
+	if _, err := app.State.ProcessAtHeight(pid, 9); err != nil {
+		t.Errorf("cannot get a process at a retained height: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package keykeeper
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/types"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	backupVersion = 1
+	// scrypt parameters of the backup key derivation
+	backupScryptN = 1 << 15
+	backupScryptR = 8
+	backupScryptP = 1
+)
+
+// backup is the encrypted backup of a keykeeper database. Data is the JSON
+// encoded list of database entries, sealed with NaCl secretbox using a key
+// derived from the password with scrypt.
+type backup struct {
+	Version int            `json:"version"`
+	Salt    types.HexBytes `json:"salt"`
+	Nonce   types.HexBytes `json:"nonce"`
+	Data    []byte         `json:"data"`
+}
+
+type backupEntry struct {
+	Key   types.HexBytes `json:"key"`
+	Value types.HexBytes `json:"value"`
+}
+
+func backupKey(password string, salt []byte) (*[32]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("empty backup password")
+	}
+	k, err := scrypt.Key([]byte(password), salt, backupScryptN, backupScryptR, backupScryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	var key [32]byte
+	copy(key[:], k)
+	return &key, nil
+}
+
+// ExportBackup writes an encrypted backup of all the entries of the keykeeper
+// database and returns the number of entries exported
+func ExportBackup(storage db.Database, w io.Writer, password string) (int, error) {
+	var entries []backupEntry
+	iter := storage.NewIterator()
+	for iter.Next() {
+		entries = append(entries, backupEntry{
+			Key:   append([]byte{}, iter.Key()...),
+			Value: append([]byte{}, iter.Value()...),
+		})
+	}
+	iter.Release()
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return 0, err
+	}
+	b := backup{Version: backupVersion, Salt: make([]byte, 32), Nonce: make([]byte, 24)}
+	if _, err := io.ReadFull(rand.Reader, b.Salt); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(rand.Reader, b.Nonce); err != nil {
+		return 0, err
+	}
+	key, err := backupKey(password, b.Salt)
+	if err != nil {
+		return 0, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], b.Nonce)
+	b.Data = secretbox.Seal(nil, data, &nonce, key)
+	if err := json.NewEncoder(w).Encode(b); err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
+
+// ImportBackup decrypts a backup written by ExportBackup and stores its
+// entries on the keykeeper database, overwriting the existing ones. It
+// returns the number of entries imported.
+func ImportBackup(storage db.Database, r io.Reader, password string) (int, error) {
+	var b backup
+	if err := json.NewDecoder(r).Decode(&b); err != nil {
+		return 0, fmt.Errorf("cannot decode backup: (%s)", err)
+	}
+	if b.Version != backupVersion {
+		return 0, fmt.Errorf("unsupported backup version %d", b.Version)
+	}
+	if len(b.Nonce) != 24 {
+		return 0, fmt.Errorf("invalid backup nonce size %d", len(b.Nonce))
+	}
+	key, err := backupKey(password, b.Salt)
+	if err != nil {
+		return 0, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], b.Nonce)
+	data, ok := secretbox.Open(nil, b.Data, &nonce, key)
+	if !ok {
+		return 0, fmt.Errorf("cannot decrypt backup, wrong password or corrupted data")
+	}
+	var entries []backupEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return 0, fmt.Errorf("cannot decode backup entries: (%s)", err)
+	}
+	for _, e := range entries {
+		if err := storage.Put(e.Key, e.Value); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
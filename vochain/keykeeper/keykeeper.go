@@ -1,17 +1,25 @@
 package keykeeper
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
 	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/crypto/vss"
 	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	"go.vocdoni.io/dvote/vochain"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -20,15 +28,18 @@ import (
 
 /*
  KV database shceme:
-   p_{processId} = {[]processKeys} // index and stores the process keys by process ID
+   i_{processId} = {} // index of the processes with published keys, pending to reveal
    b_{#block} = {[]processId} // index by block in order to reveal keys of the finished processes
+   p_{processId} = {[]processKeys} // LEGACY, process keys which cannot be derived (see migrate)
 */
 
-// TBD: Remove the ProcessKeys storage, we do not need it since the keys are deterministic and can be re-created at any time.
+// The process keys are not stored, since they are derived from the signer key
+// and the process ID (see DeriveKey) and can be re-created at any time.
 
 const (
 	commitmentKeySize = nacl.KeyLength
 	encryptionKeySize = nacl.KeyLength
+	dbPrefixIndex     = "i_"
 	dbPrefixProcess   = "p_"
 	dbPrefixBlock     = "b_"
 )
@@ -110,12 +121,69 @@ func NewKeyKeeper(dbPath string, v *vochain.BaseApplication, signer *ethereum.Si
 		return nil, err
 	}
 	k.myIndex = index
+	if err := k.migrate(); err != nil {
+		return nil, fmt.Errorf("cannot migrate keykeeper storage: (%s)", err)
+	}
 	// k.vochain.Codec.RegisterConcrete(&processKeys{}, "vocdoni/keykeeper.processKeys", nil)
 	// k.vochain.Codec.RegisterConcrete(processKeys{}, "processKeys", nil)
 	k.vochain.State.AddEventListener(k)
 	return k, nil
 }
 
+// migrate removes the process keys stored by previous versions under the p_
+// prefix. The keys which match the derived ones are replaced by an index
+// entry, the others are kept (and used) until they are revealed.
+func (k *KeyKeeper) migrate() error {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	iter := k.storage.NewIterator()
+	var derived [][]byte
+	legacy := 0
+	for iter.Next() {
+		if !strings.HasPrefix(string(iter.Key()), dbPrefixProcess) {
+			continue
+		}
+		pid := append([]byte{}, iter.Key()[len(dbPrefixProcess):]...)
+		var stored processKeys
+		if err := stored.Decode(iter.Value()); err != nil {
+			log.Warnf("cannot decode stored keys for process %x: (%s)", pid, err)
+			legacy++
+			continue
+		}
+		match := false
+		for _, shared := range []bool{false, true} {
+			pk, err := k.generateKeys(pid, shared)
+			if err != nil {
+				iter.Release()
+				return err
+			}
+			if bytes.Equal(pk.privKey, stored.privKey) && stored.index == k.myIndex {
+				match = true
+				break
+			}
+		}
+		if !match {
+			log.Warnf("stored keys for process %x cannot be derived, keeping them", pid)
+			legacy++
+			continue
+		}
+		derived = append(derived, pid)
+	}
+	iter.Release()
+	for _, pid := range derived {
+		if err := k.storage.Put([]byte(dbPrefixIndex+string(pid)), []byte{}); err != nil {
+			return err
+		}
+		if err := k.storage.Del([]byte(dbPrefixProcess + string(pid))); err != nil {
+			return err
+		}
+	}
+	if len(derived)+legacy > 0 {
+		log.Infof("migrated keykeeper storage, removed %d derivable keys and kept %d legacy keys", len(derived), legacy)
+	}
+	return nil
+}
+
 // PrintInfo print some log information every wait duration
 func (k *KeyKeeper) PrintInfo(wait time.Duration) {
 	for {
@@ -182,10 +250,13 @@ func (k *KeyKeeper) RevealUnpublished() {
 	var process *models.Process
 	// Second take all existing processes and check if keys should be revealed (if canceled)
 	for iter.Next() {
-		if !strings.HasPrefix(string(iter.Key()), dbPrefixProcess) {
+		if strings.HasPrefix(string(iter.Key()), dbPrefixIndex) {
+			pid = iter.Key()[len(dbPrefixIndex):]
+		} else if strings.HasPrefix(string(iter.Key()), dbPrefixProcess) {
+			pid = iter.Key()[len(dbPrefixProcess):]
+		} else {
 			continue
 		}
-		pid = iter.Key()[len(dbPrefixProcess):]
 		process, err = k.vochain.State.Process(pid, true)
 		if err != nil {
 			log.Error(err)
@@ -230,10 +301,12 @@ func (k *KeyKeeper) OnProcess(pid, eid []byte, censusRoot, censusURI string) {
 	}
 
 	// Generate keys
-	if k.keyPool[string(pid)], err = k.generateKeys(pid); err != nil {
+	pk, err := k.newProcessKeys(pid)
+	if err != nil {
 		log.Errorf("cannot generate process keys: (%s)", err)
 		return
 	}
+	k.keyPool[string(pid)] = pk
 
 	// Add keys to the pool queue
 	k.blockPool[string(pid)] = int64(p.StartBlock + p.BlockCount)
@@ -268,6 +341,16 @@ func (k *KeyKeeper) OnVote(v *models.Vote) {
 	// do nothing
 }
 
+// OnVoteOverwrite is not used by the KeyKeeper
+func (k *KeyKeeper) OnVoteOverwrite(v, previous *models.Vote) {
+	// do nothing
+}
+
+// OnTransaction is not used by the KeyKeeper
+func (k *KeyKeeper) OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32) {
+	// do nothing
+}
+
 // OnProcessStatusChange will publish the private and reveal keys of the ended process, if required
 func (k *KeyKeeper) OnProcessStatusChange(pid []byte, status models.ProcessStatus) {
 	p, err := k.vochain.State.Process(pid, false)
@@ -294,16 +377,29 @@ func (k *KeyKeeper) OnRevealKeys(pid []byte, priv, rev string) {
 	// do nothing
 }
 
+// DeriveKey derives the encryption private key of a process as
+// keccak256(signerKey || processId || keyIndex), where signerKey is the
+// secp256k1 private key of the keykeeper signer as 32 big-endian bytes and
+// keyIndex is a single byte. If the key is shared among the keykeepers, it is
+// reduced modulo the order of the shares field (see crypto/vss) and encoded as
+// 32 big-endian bytes.
+func DeriveKey(signer *ethereum.SignKeys, pid []byte, index int8, shared bool) []byte {
+	data := append(ethcrypto.FromECDSA(&signer.Private), pid...)
+	// Add the index in order to win some extra entropy
+	key := ethereum.HashRaw(append(data, byte(index)))
+	if shared {
+		key = vss.ScalarBytes(new(big.Int).Mod(new(big.Int).SetBytes(key), vss.Order))
+	}
+	return key
+}
+
 // Generate Keys generates a set of encryption/commitment keys for a process.
-// Encryption private key = hash(signer.privKey + processId + keyIndex).
+// Encryption private key is derived with DeriveKey.
 // Reveal key is hashPoseidon(key).
 // Commitment key is hashPoseidon(revealKey)
-func (k *KeyKeeper) generateKeys(pid []byte) (*processKeys, error) {
-	// Generate keys
-	// Add the index in order to win some extra entropy
-	pb := append(pid, byte(k.myIndex))
+func (k *KeyKeeper) generateKeys(pid []byte, shared bool) (*processKeys, error) {
 	// Private ed25519 key
-	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", ethereum.HashRaw(append(k.signer.Private.D.Bytes(), pb...))))
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", DeriveKey(k.signer, pid, k.myIndex, shared)))
 	if err != nil {
 		return nil, fmt.Errorf("cannot generate encryption key: (%s)", err)
 	}
@@ -322,6 +418,44 @@ func (k *KeyKeeper) generateKeys(pid []byte) (*processKeys, error) {
 	return pk, nil
 }
 
+// newProcessKeys generates the keys of a new process. The encryption key is
+// not shared among the keykeepers (see dealKey), since ADD_PROCESS_KEYS cannot
+// carry the key dealing yet.
+func (k *KeyKeeper) newProcessKeys(pid []byte) (*processKeys, error) {
+	return k.generateKeys(pid, false)
+}
+
+// dealKey splits the encryption private key among the holders (the public
+// keys of the other registered keykeepers, by index) and returns the dealing,
+// with each share encrypted to the public key of its holder. It is ready for
+// the threshold keys, which wait for a transaction field to publish it.
+func dealKey(privKey []byte, threshold int, holders map[uint32][]byte) (*vochain.KeyDealing, error) {
+	xs := make([]uint32, 0, len(holders))
+	for x := range holders {
+		xs = append(xs, x)
+	}
+	sort.Slice(xs, func(i, j int) bool { return xs[i] < xs[j] })
+	shares, commitments, err := vss.Split(new(big.Int).SetBytes(privKey), threshold, xs, nil)
+	if err != nil {
+		return nil, err
+	}
+	dealing := &vochain.KeyDealing{Shares: make(map[uint32]types.HexBytes)}
+	for _, c := range vss.EncodeCommitments(commitments) {
+		dealing.Commitments = append(dealing.Commitments, c)
+	}
+	for i, x := range xs {
+		pub, err := ethcrypto.DecompressPubkey(holders[x])
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key of keykeeper %d: (%s)", x, err)
+		}
+		dealing.Shares[x], err = ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(pub), vss.ScalarBytes(shares[i]), nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encrypt share of keykeeper %d: (%s)", x, err)
+		}
+	}
+	return dealing, nil
+}
+
 // scheduleRevealKeys takes the pids from the blockPool and add them to the schedule storage
 func (k *KeyKeeper) scheduleRevealKeys() {
 	k.lock.Lock()
@@ -421,39 +555,60 @@ func (k *KeyKeeper) publishKeys(pk *processKeys, pid string) error {
 		EncryptionPublicKey: pk.pubKey,
 		CommitmentKey:       pk.commitmentKey,
 	}
-	if err := k.signAndSendTx(tx); err != nil {
-		return err
-	}
+	// only the process is indexed, the keys are derived again for the reveal.
+	// It is indexed before sending the transaction, so the keys can be
+	// published again (see RepublishKeys) if the transaction is lost.
 	k.lock.Lock()
-	defer k.lock.Unlock()
-	dbKey := []byte(dbPrefixProcess + pid)
-	// TODO(mvdan): replace Has with just Get
-	if exists, err := k.storage.Has(dbKey); exists || err != nil {
-		return fmt.Errorf("keys for process %x already exist or error fetching storage: (%s)", pid, err)
+	err := k.storage.Put([]byte(dbPrefixIndex+pid), []byte{})
+	k.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("cannot index process: (%s)", err)
 	}
-	data := pk.Encode()
-	return k.storage.Put(dbKey, data)
+	return k.signAndSendTx(tx)
+}
+
+// legacyProcessKeys returns the process keys stored by previous versions under
+// the p_ prefix, or nil if the process has none
+func legacyProcessKeys(storage db.Database, pid []byte) (*processKeys, error) {
+	data, err := storage.Get([]byte(dbPrefixProcess + string(pid)))
+	if err != nil || len(data) == 0 {
+		return nil, nil
+	}
+	pk := &processKeys{}
+	if err := pk.Decode(data); err != nil {
+		return nil, fmt.Errorf("cannot decode stored process keys: (%s)", err)
+	}
+	return pk, nil
+}
+
+// LegacyProcessKey returns the encryption private key of a process stored by
+// previous versions in the keykeeper database and its key index. These keys
+// cannot be derived with DeriveKey. It returns a nil key if the process has no
+// stored keys.
+func LegacyProcessKey(storage db.Database, pid []byte) ([]byte, int8, error) {
+	pk, err := legacyProcessKeys(storage, pid)
+	if err != nil || pk == nil {
+		return nil, 0, err
+	}
+	return pk.privKey, pk.index, nil
+}
+
+// processKeys returns the keys of a process, the legacy stored ones if they
+// exist or the derived ones otherwise
+func (k *KeyKeeper) processKeys(pid []byte) (*processKeys, error) {
+	pk, err := legacyProcessKeys(k.storage, pid)
+	if err != nil || pk != nil {
+		return pk, err
+	}
+	dealing, err := k.vochain.State.KeyDealing(pid, uint32(k.myIndex), true)
+	if err != nil {
+		return nil, err
+	}
+	return k.generateKeys(pid, dealing != nil)
 }
 
-// Insecure
 func (k *KeyKeeper) revealKeys(pid string) error {
-	/*	dbKey := []byte(dbPrefixProcess + pid)
-		data, err := k.storage.Get(dbKey)
-		if err != nil {
-			return fmt.Errorf("cannot fetch reveal keys from storage: (%s)", err)
-		}
-		if len(data) == 0 {
-			return fmt.Errorf("no keys data found on storage")
-		}
-		var pk processKeys
-		if err := pk.Decode(data); err != nil {
-			return fmt.Errorf("cannot unmarshal process keys: (%s)", err)
-		}
-		if len(pk.privKey) < 32 && len(pk.revealKey) < commitmentKeySize {
-			return fmt.Errorf("empty process keys")
-		}
-	*/
-	pk, err := k.generateKeys([]byte(pid))
+	pk, err := k.processKeys([]byte(pid))
 	if err != nil {
 		return err
 	}
@@ -476,12 +631,58 @@ func (k *KeyKeeper) revealKeys(pid string) error {
 	if len(pk.revealKey) > 0 {
 		log.Infof("revealing commitment key for process %x", pid)
 	}
-	if err = k.storage.Del([]byte(dbPrefixProcess + pid)); err != nil {
+	if err = k.storage.Del([]byte(dbPrefixIndex + pid)); err != nil {
 		log.Warnf("cannot delete pid %x, for some reason it does not exist", pid)
 	}
+	if err = k.storage.Del([]byte(dbPrefixProcess + pid)); err != nil {
+		log.Warnf("cannot delete legacy keys of pid %x: (%s)", pid, err)
+	}
+	k.revealShares([]byte(pid))
 	return nil
 }
 
+// revealShares reveals the key shares held for the other keykeepers of a
+// process, so their keys can be rebuilt if they do not reveal them
+func (k *KeyKeeper) revealShares(pid []byte) {
+	process, err := k.vochain.State.Process(pid, true)
+	if err != nil {
+		log.Errorf("cannot get process from state: (%s)", err)
+		return
+	}
+	for i := range process.EncryptionPublicKeys {
+		if i == int(k.myIndex) || process.EncryptionPublicKeys[i] == "" || process.EncryptionPrivateKeys[i] != "" {
+			continue
+		}
+		dealing, err := k.vochain.State.KeyDealing(pid, uint32(i), true)
+		if err != nil {
+			log.Errorf("cannot get key dealing %d for process %x: (%s)", i, pid, err)
+			continue
+		}
+		if dealing == nil || dealing.Shares[uint32(k.myIndex)] == nil {
+			continue
+		}
+		share, err := ecies.ImportECDSA(&k.signer.Private).Decrypt(dealing.Shares[uint32(k.myIndex)], nil, nil)
+		if err != nil {
+			log.Errorf("cannot decrypt key share %d for process %x: (%s)", i, pid, err)
+			continue
+		}
+		kindex := new(uint32)
+		*kindex = uint32(i)
+		tx := &models.AdminTx{
+			Txtype:               models.TxType_REVEAL_PROCESS_KEYS,
+			KeyIndex:             kindex,
+			Nonce:                util.RandomBytes(32),
+			ProcessId:            pid,
+			EncryptionPrivateKey: share,
+		}
+		if err := k.signAndSendTx(tx); err != nil {
+			log.Errorf("cannot reveal key share %d for process %x: (%s)", i, pid, err)
+			continue
+		}
+		log.Infof("revealing key share %d for process %x", i, pid)
+	}
+}
+
 func (k *KeyKeeper) signAndSendTx(tx *models.AdminTx) error {
 	// sign the transaction
 	txBytes, err := proto.Marshal(tx)
@@ -1,13 +1,23 @@
 package keykeeper
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/hex"
+	"math/big"
 	"testing"
 
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 
+	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/vss"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
 )
 
 func TestEncodeDecode(t *testing.T) {
@@ -38,3 +48,192 @@ func TestEncodeDecode(t *testing.T) {
 		t.Fatalf("processKeys mismatch: %s", diff)
 	}
 }
+
+func TestDealKey(t *testing.T) {
+	signers := make(map[uint32]*ethereum.SignKeys)
+	holders := make(map[uint32][]byte)
+	for _, i := range []uint32{2, 3, 5} {
+		signers[i] = ethereum.NewSignKeys()
+		if err := signers[i].Generate(); err != nil {
+			t.Fatal(err)
+		}
+		holders[i] = ethcrypto.CompressPubkey(&signers[i].Public)
+	}
+	privKey := vss.ScalarBytes(big.NewInt(123456789))
+	dealing, err := dealKey(privKey, 2, holders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dealing.Commitments) != 2 || len(dealing.Shares) != 3 {
+		t.Fatalf("got %d commitments and %d shares, expected 2 and 3", len(dealing.Commitments), len(dealing.Shares))
+	}
+	commitments := make([][]byte, len(dealing.Commitments))
+	for i, c := range dealing.Commitments {
+		commitments[i] = c
+	}
+	points, err := vss.DecodeCommitments(commitments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var xs []uint32
+	var shares []*big.Int
+	for _, i := range []uint32{5, 2} {
+		shareBytes, err := ecies.ImportECDSA(&signers[i].Private).Decrypt(dealing.Shares[i], nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		share, err := vss.ScalarFromBytes(shareBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !vss.VerifyShare(i, share, points) {
+			t.Fatalf("share of holder %d does not match the commitments", i)
+		}
+		xs = append(xs, i)
+		shares = append(shares, share)
+	}
+	secret, err := vss.Combine(xs, shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(vss.ScalarBytes(secret), privKey) {
+		t.Fatalf("got key %x, expected %x", vss.ScalarBytes(secret), privKey)
+	}
+}
+
+func TestMigrateStorage(t *testing.T) {
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := ethereum.NewSignKeys()
+	if err := signer.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	derivedPid, legacyPid := util.RandomBytes(32), util.RandomBytes(32)
+	derived, err := (&KeyKeeper{signer: signer, myIndex: 1}).generateKeys(derivedPid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := nacl.Generate(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := &processKeys{pubKey: priv.Public().Bytes(), privKey: priv.Bytes(), index: 1}
+
+	// keys stored by a previous version
+	dbPath := t.TempDir()
+	storage, err := db.NewBadgerDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put([]byte(dbPrefixProcess+string(derivedPid)), derived.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put([]byte(dbPrefixProcess+string(legacyPid)), legacy.Encode()); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := NewKeyKeeper(dbPath, app, signer, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer k.storage.Close()
+	for key, expected := range map[string]bool{
+		dbPrefixProcess + string(derivedPid): false,
+		dbPrefixIndex + string(derivedPid):   true,
+		dbPrefixProcess + string(legacyPid):  true,
+	} {
+		if has, err := k.storage.Has([]byte(key)); err != nil || has != expected {
+			t.Errorf("storage has key %x: %v, expected %v (%v)", key, has, expected, err)
+		}
+	}
+	processes, err := k.Processes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(processes) != 2 {
+		t.Fatalf("got %d held processes, expected 2", len(processes))
+	}
+	for _, hp := range processes {
+		if expected := bytes.Equal(hp.ProcessID, legacyPid); hp.Legacy != expected {
+			t.Errorf("process %x legacy is %v, expected %v", []byte(hp.ProcessID), hp.Legacy, expected)
+		}
+	}
+	// the derivable keys are re-created, the legacy ones are read from the storage
+	pk, err := k.processKeys(derivedPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pk.privKey, derived.privKey) {
+		t.Errorf("got derived key %x, expected %x", pk.privKey, derived.privKey)
+	}
+	if pk, err = k.processKeys(legacyPid); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(pk.privKey, legacy.privKey) {
+		t.Errorf("got legacy key %x, expected %x", pk.privKey, legacy.privKey)
+	}
+	// the legacy keys are also read without a keykeeper, as keykeepercli does
+	key, index, err := LegacyProcessKey(k.storage, legacyPid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, legacy.privKey) || index != 1 {
+		t.Errorf("got legacy key %x with index %d, expected %x with index 1", key, index, legacy.privKey)
+	}
+	if key, _, err = LegacyProcessKey(k.storage, derivedPid); err != nil || key != nil {
+		t.Errorf("got legacy key %x for a derived process (%v)", key, err)
+	}
+}
+
+func TestBackup(t *testing.T) {
+	storage, err := db.NewBadgerDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+	entries := map[string][]byte{
+		dbPrefixIndex + string(util.RandomBytes(32)):   {},
+		dbPrefixProcess + string(util.RandomBytes(32)): util.RandomBytes(129),
+		dbPrefixBlock + "1234":                         util.RandomBytes(40),
+	}
+	for k, v := range entries {
+		if err := storage.Put([]byte(k), v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b bytes.Buffer
+	if n, err := ExportBackup(storage, &b, "secret"); err != nil || n != len(entries) {
+		t.Fatalf("exported %d entries, expected %d (%v)", n, len(entries), err)
+	}
+	for k := range entries {
+		if bytes.Contains(b.Bytes(), []byte(hex.EncodeToString([]byte(k)))) {
+			t.Fatal("backup not encrypted")
+		}
+	}
+
+	restored, err := db.NewBadgerDB(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if _, err := ImportBackup(restored, bytes.NewReader(b.Bytes()), "wrong"); err == nil {
+		t.Fatal("backup imported with a wrong password")
+	}
+	if n, err := ImportBackup(restored, bytes.NewReader(b.Bytes()), "secret"); err != nil || n != len(entries) {
+		t.Fatalf("imported %d entries, expected %d (%v)", n, len(entries), err)
+	}
+	for k, v := range entries {
+		got, err := restored.Get([]byte(k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, v) {
+			t.Errorf("restored entry %x is %x, expected %x", k, got, v)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package keykeeper
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// HeldProcess is a process the keykeeper holds keys for, pending to reveal
+type HeldProcess struct {
+	ProcessID types.HexBytes
+	// Legacy is true if the keys are stored instead of derived (see migrate)
+	Legacy bool
+	// RevealHeight is the height the keys are scheduled to be revealed, zero if not scheduled
+	RevealHeight int64
+}
+
+// Processes returns the processes the keykeeper holds keys for
+func (k *KeyKeeper) Processes() ([]*HeldProcess, error) {
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	held := make(map[string]*HeldProcess)
+	var pids []string
+	heights := make(map[string]int64)
+	iter := k.storage.NewIterator()
+	defer iter.Release()
+	for iter.Next() {
+		key := string(iter.Key())
+		switch {
+		case strings.HasPrefix(key, dbPrefixIndex), strings.HasPrefix(key, dbPrefixProcess):
+			legacy := strings.HasPrefix(key, dbPrefixProcess)
+			pid := key[len(dbPrefixIndex):]
+			if legacy {
+				pid = key[len(dbPrefixProcess):]
+			}
+			if _, ok := held[pid]; !ok {
+				held[pid] = &HeldProcess{ProcessID: []byte(pid)}
+				pids = append(pids, pid)
+			}
+			held[pid].Legacy = held[pid].Legacy || legacy
+		case strings.HasPrefix(key, dbPrefixBlock):
+			h, err := strconv.ParseInt(key[len(dbPrefixBlock):], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot fetch block number from keykeeper database: (%s)", err)
+			}
+			var scheduled models.StoredKeys
+			if err := proto.Unmarshal(iter.Value(), &scheduled); err != nil {
+				return nil, fmt.Errorf("cannot unmarshal scheduled processes for block %d: (%s)", h, err)
+			}
+			for _, pid := range scheduled.GetPids() {
+				heights[string(pid)] = h
+			}
+		}
+	}
+	processes := make([]*HeldProcess, len(pids))
+	for i, pid := range pids {
+		held[pid].RevealHeight = heights[pid]
+		processes[i] = held[pid]
+	}
+	return processes, nil
+}
+
+// RepublishKeys publishes again the keys of the held processes which are not
+// on the state yet (the ADD_PROCESS_KEYS transaction failed or was lost), as
+// long as the process did not start. The processes are held since before the
+// transaction is sent. It returns the number of processes published.
+func (k *KeyKeeper) RepublishKeys() (int, error) {
+	processes, err := k.Processes()
+	if err != nil {
+		return 0, err
+	}
+	header := k.vochain.State.Header(true)
+	if header == nil {
+		return 0, fmt.Errorf("cannot get blockchain header")
+	}
+	published := 0
+	for _, hp := range processes {
+		p, err := k.vochain.State.Process(hp.ProcessID, true)
+		if err != nil {
+			log.Warnf("cannot get process %x from state: (%s)", hp.ProcessID, err)
+			continue
+		}
+		if len(p.EncryptionPublicKeys[k.myIndex])+len(p.CommitmentKeys[k.myIndex]) > 0 {
+			continue
+		}
+		if header.Height > int64(p.StartBlock) || p.Status == models.ProcessStatus_CANCELED ||
+			p.Status == models.ProcessStatus_ENDED || p.Status == models.ProcessStatus_RESULTS {
+			log.Warnf("keys of process %x cannot be published anymore", hp.ProcessID)
+			continue
+		}
+		var pk *processKeys
+		if hp.Legacy {
+			pk, err = k.processKeys(hp.ProcessID)
+		} else {
+			pk, err = k.newProcessKeys(hp.ProcessID)
+		}
+		if err != nil {
+			return published, fmt.Errorf("cannot generate keys for process %x: (%s)", hp.ProcessID, err)
+		}
+		if err := k.publishKeys(pk, string(hp.ProcessID)); err != nil {
+			return published, fmt.Errorf("cannot publish keys for process %x: (%s)", hp.ProcessID, err)
+		}
+		published++
+	}
+	return published, nil
+}
@@ -0,0 +1,517 @@
+package vochain
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/crypto/vss"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// keyKeeperThresholdKey stores the number of key shares required to rebuild a keykeeper key
+	keyKeeperThresholdKey = []byte("keyKeeperThreshold")
+	// keyKeeperPrefix+index stores the compressed public key of the keykeeper using the index
+	keyKeeperPrefix = []byte("keyKeeper/")
+	// processKeyDealingPrefix+processId+index stores the key dealing of a keykeeper
+	processKeyDealingPrefix = []byte("processKeyDealing/")
+	// processKeySharePrefix+processId+index+holder stores a key share revealed by a holder
+	processKeySharePrefix = []byte("processKeyShare/")
+	// keyKeeperEvidencePrefix+processId stores the list of keykeeper misbehaviors
+	keyKeeperEvidencePrefix = []byte("keyKeeperEvidence/")
+)
+
+// KeyKeeperEvidence records a keykeeper revealing a key (or a key share) which
+// does not match the one it published
+type KeyKeeperEvidence struct {
+	ProcessID types.HexBytes `json:"processId"`
+	Height    int64          `json:"height"`
+	KeyIndex  uint32         `json:"keyIndex"`
+	KeyKeeper common.Address `json:"keyKeeper"`
+	Reason    string         `json:"reason"`
+}
+
+// KeyDealing is the sharing of a keykeeper encryption private key among the
+// other keykeepers. The commitments are the Feldman commitments of the sharing
+// polynomial and the shares are encrypted (ECIES) to the public key of each
+// holder, by index. Once the process is finished, any threshold of holders can
+// reveal their shares, so the key is revealed even if its keykeeper is offline.
+//
+// The dealing is part of the keykeeper protocol, every keykeeper implementation
+// must produce this JSON encoding: the commitments are the hex encoded
+// compressed BabyJubJub points (see vss.EncodeCommitments), as many as the
+// threshold, and the shares are the hex encoded ECIES ciphertexts of the
+// vss.ScalarSize bytes big-endian shares, keyed by the decimal index of their
+// holder:
+//
+//	{"commitments":["<hex>","<hex>"],"shares":{"2":"<hex>","3":"<hex>"}}
+//
+// The protocol buffers release in use has no AdminTx field for it, so
+// ADD_PROCESS_KEYS cannot carry the dealing yet (the PublicKey field is
+// rejected) and it is only added by the node through AddKeyDealing.
+type KeyDealing struct {
+	Commitments []types.HexBytes          `json:"commitments"`
+	Shares      map[uint32]types.HexBytes `json:"shares"`
+}
+
+func uint32Key(prefix []byte, id []byte, indexes ...uint32) []byte {
+	key := append(append([]byte{}, prefix...), id...)
+	for _, i := range indexes {
+		key = append(key, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(key[len(key)-4:], i)
+	}
+	return key
+}
+
+// SetKeyKeeperThreshold sets the number of key shares required to rebuild a
+// keykeeper key. Zero disables the threshold keys.
+func (v *State) SetKeyKeeperThreshold(threshold uint32) error {
+	thresholdBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(thresholdBytes, threshold)
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(keyKeeperThresholdKey, thresholdBytes)
+}
+
+// KeyKeeperThreshold returns the number of key shares required to rebuild a
+// keykeeper key, or zero if the threshold keys are disabled
+func (v *State) KeyKeeperThreshold(isQuery bool) uint32 {
+	var thresholdBytes []byte
+	v.RLock()
+	if isQuery {
+		thresholdBytes = v.Store.ImmutableTree(AppTree).Get(keyKeeperThresholdKey)
+	} else {
+		thresholdBytes = v.Store.Tree(AppTree).Get(keyKeeperThresholdKey)
+	}
+	v.RUnlock()
+	if len(thresholdBytes) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(thresholdBytes)
+}
+
+// AddKeyKeeper binds a keykeeper index to the (compressed) public key of its
+// signer. The first ADD_PROCESS_KEYS of each keykeeper registers it.
+func (v *State) AddKeyKeeper(index uint32, pubKey []byte) error {
+	if _, err := ethcrypto.DecompressPubkey(pubKey); err != nil {
+		return fmt.Errorf("invalid keykeeper public key: %w", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(uint32Key(keyKeeperPrefix, nil, index), pubKey)
+}
+
+// KeyKeepers returns the compressed public keys of the registered keykeepers by index
+func (v *State) KeyKeepers(isQuery bool) (map[uint32][]byte, error) {
+	keykeepers := make(map[uint32][]byte)
+	fn := func(key, value []byte) bool {
+		if len(key) != len(keyKeeperPrefix)+4 {
+			return false
+		}
+		keykeepers[binary.BigEndian.Uint32(key[len(keyKeeperPrefix):])] = append([]byte{}, value...)
+		return false
+	}
+	v.RLock()
+	defer v.RUnlock()
+	if isQuery {
+		v.Store.ImmutableTree(AppTree).Iterate(keyKeeperPrefix, fn)
+	} else {
+		v.Store.Tree(AppTree).Iterate(keyKeeperPrefix, fn)
+	}
+	return keykeepers, nil
+}
+
+// keyKeeperIndex returns the index registered by the keykeeper with address addr
+func (v *State) keyKeeperIndex(addr common.Address, isQuery bool) (uint32, bool, error) {
+	keykeepers, err := v.KeyKeepers(isQuery)
+	if err != nil {
+		return 0, false, err
+	}
+	for index, pubKey := range keykeepers {
+		pub, err := ethcrypto.DecompressPubkey(pubKey)
+		if err != nil {
+			return 0, false, err
+		}
+		if ethcrypto.PubkeyToAddress(*pub) == addr {
+			return index, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// signerPubKey returns the compressed public key of the signer of an admin transaction
+func signerPubKey(vtx *models.Tx) ([]byte, error) {
+	signedBytes, err := proto.Marshal(vtx.GetAdmin())
+	if err != nil {
+		return nil, err
+	}
+	// PubKeyFromSignature modifies the recovery byte of the signature
+	pubHex, err := ethereum.PubKeyFromSignature(signedBytes, append([]byte{}, vtx.Signature...))
+	if err != nil {
+		return nil, err
+	}
+	if pubHex, err = ethereum.CompressPubKey(pubHex); err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(pubHex)
+}
+
+// KeyDealing returns the key dealing of a keykeeper for a process, or nil if
+// the keykeeper did not share its key
+func (v *State) KeyDealing(pid []byte, index uint32, isQuery bool) (*KeyDealing, error) {
+	key := uint32Key(processKeyDealingPrefix, pid, index)
+	var dealingBytes []byte
+	v.RLock()
+	if isQuery {
+		dealingBytes = v.Store.ImmutableTree(AppTree).Get(key)
+	} else {
+		dealingBytes = v.Store.Tree(AppTree).Get(key)
+	}
+	v.RUnlock()
+	if len(dealingBytes) == 0 {
+		return nil, nil
+	}
+	dealing := &KeyDealing{}
+	if err := json.Unmarshal(dealingBytes, dealing); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal key dealing: %w", err)
+	}
+	return dealing, nil
+}
+
+// AddKeyDealing adds the key dealing of the keykeeper using the key index,
+// which must have published its encryption key for the process
+func (v *State) AddKeyDealing(pid []byte, index uint32, dealing *KeyDealing) error {
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if int(index) >= len(process.EncryptionPublicKeys) || len(process.EncryptionPublicKeys[index]) == 0 {
+		return fmt.Errorf("key dealing without encryption key")
+	}
+	if err := checkKeyDealing(v, index, dealing); err != nil {
+		return err
+	}
+	dealingBytes, err := json.Marshal(dealing)
+	if err != nil {
+		return fmt.Errorf("cannot marshal key dealing: %w", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(uint32Key(processKeyDealingPrefix, pid, index), dealingBytes)
+}
+
+// processKeyShares returns the shares revealed for the key index of a process, by holder
+func (v *State) processKeyShares(pid []byte, index uint32) map[uint32][]byte {
+	prefix := uint32Key(processKeySharePrefix, pid, index)
+	shares := make(map[uint32][]byte)
+	v.RLock()
+	defer v.RUnlock()
+	v.Store.Tree(AppTree).Iterate(prefix, func(key, value []byte) bool {
+		if len(key) == len(prefix)+4 {
+			shares[binary.BigEndian.Uint32(key[len(prefix):])] = append([]byte{}, value...)
+		}
+		return false
+	})
+	return shares
+}
+
+// checkKeyKeeperIndex checks the keykeeper index is not registered by another
+// signer, and the signer did not register another index
+func checkKeyKeeperIndex(state *State, index uint32, addr common.Address) error {
+	keykeepers, err := state.KeyKeepers(false)
+	if err != nil {
+		return err
+	}
+	for i, pubKey := range keykeepers {
+		pub, err := ethcrypto.DecompressPubkey(pubKey)
+		if err != nil {
+			return err
+		}
+		owner := ethcrypto.PubkeyToAddress(*pub)
+		if i == index && owner != addr {
+			return fmt.Errorf("%w: key index %d belongs to %s", ErrUnauthorized, index, owner.Hex())
+		}
+		if i != index && owner == addr {
+			return fmt.Errorf("%w: %s already uses the key index %d", ErrUnauthorized, addr.Hex(), i)
+		}
+	}
+	return nil
+}
+
+// checkKeyDealing checks the key dealing of the keykeeper using the key index.
+// The encrypted shares cannot be verified, the holders verify them against
+// the commitments once they are revealed.
+func checkKeyDealing(state *State, index uint32, dealing *KeyDealing) error {
+	threshold := state.KeyKeeperThreshold(false)
+	if threshold == 0 {
+		return fmt.Errorf("threshold keys are disabled")
+	}
+	if len(dealing.Commitments) != int(threshold) {
+		return fmt.Errorf("key dealing has %d commitments, expected %d", len(dealing.Commitments), threshold)
+	}
+	commitments := make([][]byte, len(dealing.Commitments))
+	for i, c := range dealing.Commitments {
+		commitments[i] = c
+	}
+	if _, err := vss.DecodeCommitments(commitments); err != nil {
+		return fmt.Errorf("invalid key dealing: %w", err)
+	}
+	if len(dealing.Shares) < int(threshold) {
+		return fmt.Errorf("key dealing has %d shares, expected at least %d", len(dealing.Shares), threshold)
+	}
+	keykeepers, err := state.KeyKeepers(false)
+	if err != nil {
+		return err
+	}
+	for holder, share := range dealing.Shares {
+		if holder == index {
+			return fmt.Errorf("key dealing shares the key with its own keykeeper")
+		}
+		if keykeepers[holder] == nil {
+			return fmt.Errorf("key dealing holder %d is not a registered keykeeper", holder)
+		}
+		if len(share) == 0 {
+			return fmt.Errorf("key dealing share of holder %d is empty", holder)
+		}
+	}
+	return nil
+}
+
+// keyShareHolder returns the holder index and the dealing if the
+// REVEAL_PROCESS_KEYS transaction signed by addr reveals a key share (the
+// signer is a registered keykeeper other than the dealer), zero otherwise
+func keyShareHolder(state *State, tx *models.AdminTx, addr common.Address) (uint32, *KeyDealing, error) {
+	dealing, err := state.KeyDealing(tx.ProcessId, *tx.KeyIndex, false)
+	if err != nil || dealing == nil {
+		return 0, nil, err
+	}
+	holder, ok, err := state.keyKeeperIndex(addr, false)
+	if err != nil || !ok || holder == *tx.KeyIndex {
+		return 0, nil, err
+	}
+	return holder, dealing, nil
+}
+
+// checkKeyShare checks a key share revealed by holder against the dealing commitments
+func checkKeyShare(state *State, tx *models.AdminTx, holder uint32, dealing *KeyDealing) error {
+	if dealing.Shares[holder] == nil {
+		return fmt.Errorf("%w: keykeeper %d is not a holder of key %d", ErrUnauthorized, holder, *tx.KeyIndex)
+	}
+	if tx.RevealKey != nil {
+		return fmt.Errorf("key share with reveal key")
+	}
+	if state.processKeyShares(tx.ProcessId, *tx.KeyIndex)[holder] != nil {
+		return fmt.Errorf("share of keykeeper %d for key %d already revealed", holder, *tx.KeyIndex)
+	}
+	share, err := vss.ScalarFromBytes(tx.EncryptionPrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid key share: %w", err)
+	}
+	commitments := make([][]byte, len(dealing.Commitments))
+	for i, c := range dealing.Commitments {
+		commitments[i] = c
+	}
+	points, err := vss.DecodeCommitments(commitments)
+	if err != nil {
+		return err
+	}
+	if !vss.VerifyShare(holder, share, points) {
+		return fmt.Errorf("%w: key share of keykeeper %d does not match the commitments of key %d",
+			ErrKeyMismatch, holder, *tx.KeyIndex)
+	}
+	return nil
+}
+
+// RevealProcessKeyShare adds a key share revealed by the keykeeper holder.
+// Once the threshold of shares is reached, the key is rebuilt and revealed.
+func (v *State) RevealProcessKeyShare(tx *models.AdminTx, holder uint32) error {
+	if tx.ProcessId == nil || tx.KeyIndex == nil {
+		return fmt.Errorf("no processId or keyIndex provided on RevealProcessKeyShare")
+	}
+	index := *tx.KeyIndex
+	v.Lock()
+	err := v.Store.Tree(AppTree).Add(uint32Key(processKeySharePrefix, tx.ProcessId, index, holder), tx.EncryptionPrivateKey)
+	v.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Debugf("revealed share %d of key %d for process %x", holder, index, tx.ProcessId)
+	// the shares are verified against the commitments, so any threshold of them
+	// rebuild the same key and the key is only rebuilt once
+	shares := v.processKeyShares(tx.ProcessId, index)
+	if len(shares) != int(v.KeyKeeperThreshold(false)) {
+		return nil
+	}
+	process, err := v.Process(tx.ProcessId, false)
+	if err != nil {
+		return err
+	}
+	if len(process.EncryptionPrivateKeys[index]) > 0 {
+		return nil
+	}
+	holders := make([]uint32, 0, len(shares))
+	for h := range shares {
+		holders = append(holders, h)
+	}
+	sort.Slice(holders, func(i, j int) bool { return holders[i] < holders[j] })
+	values := make([]*big.Int, len(holders))
+	for i, h := range holders {
+		values[i] = new(big.Int).SetBytes(shares[h])
+	}
+	secret, err := vss.Combine(holders, values)
+	if err != nil {
+		return err
+	}
+	priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", vss.ScalarBytes(secret)))
+	if err != nil {
+		return err
+	}
+	if fmt.Sprintf("%x", priv.Public().Bytes()) != process.EncryptionPublicKeys[index] {
+		// the dealer shared a different key, the shares cannot reveal it
+		keykeepers, err := v.KeyKeepers(false)
+		if err != nil {
+			return err
+		}
+		var dealer common.Address
+		if pub, err := ethcrypto.DecompressPubkey(keykeepers[index]); err == nil {
+			dealer = ethcrypto.PubkeyToAddress(*pub)
+		}
+		return v.addKeyKeeperEvidence(tx.ProcessId, index, dealer, "key rebuilt from the shares does not match the public key")
+	}
+	ekey := fmt.Sprintf("%x", priv.Bytes())
+	process.EncryptionPrivateKeys[index] = ekey
+	rkey := ""
+	if len(process.CommitmentKeys[index]) > 0 {
+		reveal := snarks.Poseidon.Hash(priv.Bytes())[:types.CommitmentKeySize]
+		if fmt.Sprintf("%x", snarks.Poseidon.Hash(reveal)) == process.CommitmentKeys[index] {
+			rkey = fmt.Sprintf("%x", reveal)
+			process.RevealKeys[index] = rkey
+		}
+	}
+	if process.KeyIndex != nil && *process.KeyIndex > 0 {
+		*process.KeyIndex--
+	}
+	if err := v.setProcess(process, tx.ProcessId); err != nil {
+		return err
+	}
+	log.Infof("revealed key %d of process %x from %d shares", index, tx.ProcessId, len(shares))
+	for _, l := range v.eventListeners {
+		l.OnRevealKeys(tx.ProcessId, ekey, rkey)
+	}
+	return nil
+}
+
+// ProcessKeyKeeperEvidence returns the keykeeper misbehaviors recorded for a process
+func (v *State) ProcessKeyKeeperEvidence(pid []byte, isQuery bool) ([]*KeyKeeperEvidence, error) {
+	key := append(append([]byte{}, keyKeeperEvidencePrefix...), pid...)
+	var evidenceBytes []byte
+	v.RLock()
+	if isQuery {
+		evidenceBytes = v.Store.ImmutableTree(AppTree).Get(key)
+	} else {
+		evidenceBytes = v.Store.Tree(AppTree).Get(key)
+	}
+	v.RUnlock()
+	if len(evidenceBytes) == 0 {
+		return nil, nil
+	}
+	var evidence []*KeyKeeperEvidence
+	if err := json.Unmarshal(evidenceBytes, &evidence); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal keykeeper evidence: %w", err)
+	}
+	return evidence, nil
+}
+
+// addKeyKeeperEvidence records the keykeeper using the key index misbehaved on a process
+func (v *State) addKeyKeeperEvidence(pid []byte, index uint32, keykeeper common.Address, reason string) error {
+	header := v.Header(false)
+	if header == nil {
+		return fmt.Errorf("cannot get state header")
+	}
+	evidence, err := v.ProcessKeyKeeperEvidence(pid, false)
+	if err != nil {
+		return err
+	}
+	evidence = append(evidence, &KeyKeeperEvidence{
+		ProcessID: pid,
+		Height:    header.Height,
+		KeyIndex:  index,
+		KeyKeeper: keykeeper,
+		Reason:    reason,
+	})
+	evidenceBytes, err := json.Marshal(evidence)
+	if err != nil {
+		return fmt.Errorf("cannot marshal keykeeper evidence: %w", err)
+	}
+	log.Warnf("keykeeper %d (%s) misbehaved on process %x: %s", index, keykeeper.Hex(), pid, reason)
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(append(append([]byte{}, keyKeeperEvidencePrefix...), pid...), evidenceBytes)
+}
+
+// keyMismatchIndex returns the index of the keykeeper which signed a
+// REVEAL_PROCESS_KEYS transaction, the holder index if it reveals a key share.
+// Otherwise it is the transaction key index, which adminTxPayloadCheck only
+// accepts from the keykeeper registered with it.
+func keyMismatchIndex(state *State, tx *models.AdminTx, addr common.Address) (uint32, error) {
+	holder, _, err := keyShareHolder(state, tx, addr)
+	if err != nil {
+		return 0, err
+	}
+	if holder != 0 {
+		return holder, nil
+	}
+	return *tx.KeyIndex, nil
+}
+
+// keyMismatchRecorded returns true if the misbehavior of the keykeeper which
+// signed a REVEAL_PROCESS_KEYS transaction was already recorded on the process
+func keyMismatchRecorded(state *State, tx *models.AdminTx, addr common.Address) (bool, error) {
+	if tx.ProcessId == nil || tx.KeyIndex == nil {
+		return false, nil
+	}
+	index, err := keyMismatchIndex(state, tx, addr)
+	if err != nil {
+		return false, err
+	}
+	evidence, err := state.ProcessKeyKeeperEvidence(tx.ProcessId, false)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range evidence {
+		if e.KeyIndex == index && e.KeyKeeper == addr {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// recordKeyMismatch records the misbehavior of the keykeeper which signed a
+// REVEAL_PROCESS_KEYS transaction rejected with ErrKeyMismatch. A misbehavior
+// is only recorded once for each keykeeper and key.
+func recordKeyMismatch(state *State, tx *models.AdminTx, addr common.Address, reason error) error {
+	if tx.ProcessId == nil || tx.KeyIndex == nil {
+		return nil
+	}
+	recorded, err := keyMismatchRecorded(state, tx, addr)
+	if err != nil || recorded {
+		return err
+	}
+	index, err := keyMismatchIndex(state, tx, addr)
+	if err != nil {
+		return err
+	}
+	return state.addKeyKeeperEvidence(tx.ProcessId, index, addr, reason.Error())
+}
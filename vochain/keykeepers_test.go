@@ -0,0 +1,323 @@
+package vochain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/crypto"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/nacl"
+	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/crypto/vss"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestThresholdProcessKeys(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keykeepers := make(map[uint32]*ethereum.SignKeys)
+	for i := uint32(1); i <= 3; i++ {
+		keykeepers[i] = ethereum.NewSignKeys()
+		if err := keykeepers[i].Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// block runs the transactions in a block, they must succeed only if valid is true
+	block := func(height int64, valid bool, txs ...[]byte) {
+		t.Helper()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		for i, tx := range txs {
+			if res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}); (res.Code == 0) != valid {
+				t.Fatalf("block %d, transaction %d: got code %d (%s)", height, i, res.Code, res.Data)
+			}
+		}
+		app.Commit()
+	}
+	index := func(i uint32) *uint32 { return &i }
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	for _, k := range keykeepers {
+		if err := app.State.AddOracle(k.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.State.SetKeyKeeperThreshold(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:             pid,
+		StartBlock:            10,
+		BlockCount:            5,
+		EnvelopeType:          &models.EnvelopeType{EncryptedVotes: true},
+		Mode:                  &models.ProcessMode{},
+		Status:                models.ProcessStatus_READY,
+		EntityId:              util.RandomBytes(types.EntityIDsize),
+		CensusOrigin:          models.CensusOrigin_OFF_CHAIN_TREE,
+		EncryptionPublicKeys:  make([]string, types.KeyKeeperMaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.KeyKeeperMaxKeyIndex),
+		CommitmentKeys:        make([]string, types.KeyKeeperMaxKeyIndex),
+		RevealKeys:            make([]string, types.KeyKeeperMaxKeyIndex),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	// the first keys of each keykeeper register its index
+	keys := make(map[uint32]*big.Int)
+	for i := uint32(1); i <= 3; i++ {
+		keys[i] = new(big.Int).Mod(new(big.Int).SetBytes(util.RandomBytes(32)), vss.Order)
+	}
+	encryptionKey := func(i uint32) crypto.Cipher {
+		priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", vss.ScalarBytes(keys[i])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return priv
+	}
+	dealing := func(commitments int, holders ...uint32) (*KeyDealing, []*big.Int) {
+		shares, points, err := vss.Split(keys[1], 2, holders, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := KeyDealing{Shares: make(map[uint32]types.HexBytes)}
+		for _, c := range vss.EncodeCommitments(points)[:commitments] {
+			d.Commitments = append(d.Commitments, c)
+		}
+		for _, h := range holders {
+			// the state does not decrypt the shares
+			d.Shares[h] = util.RandomBytes(64)
+		}
+		return &d, shares
+	}
+	block(2, true,
+		testAdminTx(t, keykeepers[2], &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid, KeyIndex: index(2),
+			EncryptionPublicKey: encryptionKey(2).Public().Bytes()}),
+		testAdminTx(t, keykeepers[3], &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid, KeyIndex: index(3),
+			EncryptionPublicKey: encryptionKey(3).Public().Bytes()}),
+	)
+	registered, err := app.State.KeyKeepers(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registered) != 2 {
+		t.Fatalf("got %d registered keykeepers, expected 2", len(registered))
+	}
+
+	// a keykeeper cannot use another index, and the process keys do not carry a dealing
+	validDealing, shares := dealing(2, 2, 3)
+	validDealingBytes, err := json.Marshal(validDealing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block(3, false,
+		testAdminTx(t, keykeepers[3], &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid, KeyIndex: index(4),
+			EncryptionPublicKey: encryptionKey(3).Public().Bytes()}),
+		testAdminTx(t, keykeepers[1], &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid, KeyIndex: index(1),
+			EncryptionPublicKey: encryptionKey(1).Public().Bytes(), PublicKey: validDealingBytes}),
+	)
+
+	// keykeeper 1 shares its key with the keykeepers 2 and 3, the dealing must
+	// follow its encryption key and match the threshold and holders
+	reveal := snarks.Poseidon.Hash(encryptionKey(1).Bytes())[:nacl.KeyLength]
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 4}})
+	if err := app.State.AddKeyDealing(pid, 1, validDealing); err == nil {
+		t.Fatal("key dealing added without encryption key")
+	}
+	if res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: testAdminTx(t, keykeepers[1], &models.AdminTx{
+		Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid, KeyIndex: index(1),
+		EncryptionPublicKey: encryptionKey(1).Public().Bytes(), CommitmentKey: snarks.Poseidon.Hash(reveal),
+	})}); res.Code != 0 {
+		t.Fatalf("add keys: got code %d (%s)", res.Code, res.Data)
+	}
+	invalidDealing, _ := dealing(1, 2, 3)
+	unknownHolder, _ := dealing(2, 2, 4)
+	for name, d := range map[string]*KeyDealing{"commitments": invalidDealing, "holder": unknownHolder} {
+		if err := app.State.AddKeyDealing(pid, 1, d); err == nil {
+			t.Fatalf("key dealing with a wrong %s added", name)
+		}
+	}
+	if err := app.State.AddKeyDealing(pid, 1, validDealing); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	// the shares are not revealed before the process finishes, and must match the commitments
+	block(5, false, testAdminTx(t, keykeepers[2], &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: index(1), EncryptionPrivateKey: vss.ScalarBytes(shares[0])}))
+	block(20, false, testAdminTx(t, keykeepers[2], &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: index(1), EncryptionPrivateKey: vss.ScalarBytes(shares[1])}))
+	evidence, err := app.State.ProcessKeyKeeperEvidence(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evidence) != 1 || evidence[0].KeyIndex != 2 || evidence[0].KeyKeeper != keykeepers[2].Address() {
+		t.Fatalf("got keykeeper evidence %+v, expected the share of keykeeper 2", evidence)
+	}
+
+	// keykeeper 1 is offline, the shares of 2 and 3 reveal its key
+	block(21, true, testAdminTx(t, keykeepers[2], &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: index(1), EncryptionPrivateKey: vss.ScalarBytes(shares[0])}))
+	block(22, false, testAdminTx(t, keykeepers[2], &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: index(1), EncryptionPrivateKey: vss.ScalarBytes(shares[0])}))
+	p, err := app.State.Process(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.EncryptionPrivateKeys[1] != "" {
+		t.Fatal("key revealed with less shares than the threshold")
+	}
+	block(23, true, testAdminTx(t, keykeepers[3], &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: index(1), EncryptionPrivateKey: vss.ScalarBytes(shares[1])}))
+	if p, err = app.State.Process(pid, true); err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("%x", encryptionKey(1).Bytes()); p.EncryptionPrivateKeys[1] != expected {
+		t.Fatalf("got encryption key %q, expected %s", p.EncryptionPrivateKeys[1], expected)
+	}
+	if expected := fmt.Sprintf("%x", reveal); p.RevealKeys[1] != expected {
+		t.Errorf("got reveal key %q, expected %s", p.RevealKeys[1], expected)
+	}
+	if *p.KeyIndex != 2 {
+		t.Errorf("got key index %d, expected 2", *p.KeyIndex)
+	}
+}
+
+func TestRevealProcessKeysMismatch(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keykeeper, impostor := ethereum.NewSignKeys(), ethereum.NewSignKeys()
+	if err := keykeeper.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := impostor.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	deliver := func(height int64, tx []byte) uint32 {
+		t.Helper()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx})
+		app.Commit()
+		return res.Code
+	}
+	// send checks the transaction as the mempool does, and delivers it if accepted
+	send := func(height int64, tx []byte) (uint32, uint32) {
+		t.Helper()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		defer app.Commit()
+		if res := app.CheckTx(abcitypes.RequestCheckTx{Tx: tx}); res.Code != 0 {
+			return res.Code, res.Code
+		}
+		return 0, app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}).Code
+	}
+	index := uint32(1)
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	for _, oracle := range []*ethereum.SignKeys{keykeeper, impostor} {
+		if err := app.State.AddOracle(oracle.Address()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:             pid,
+		StartBlock:            10,
+		BlockCount:            5,
+		EnvelopeType:          &models.EnvelopeType{EncryptedVotes: true, Anonymous: true},
+		Mode:                  &models.ProcessMode{},
+		Status:                models.ProcessStatus_READY,
+		EntityId:              util.RandomBytes(types.EntityIDsize),
+		CensusOrigin:          models.CensusOrigin_OFF_CHAIN_TREE,
+		EncryptionPublicKeys:  make([]string, types.KeyKeeperMaxKeyIndex),
+		EncryptionPrivateKeys: make([]string, types.KeyKeeperMaxKeyIndex),
+		CommitmentKeys:        make([]string, types.KeyKeeperMaxKeyIndex),
+		RevealKeys:            make([]string, types.KeyKeeperMaxKeyIndex),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	priv, err := nacl.Generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := nacl.Generate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reveal := snarks.Poseidon.Hash(priv.Bytes())
+
+	// an anonymous process requires the commitment key
+	if code := deliver(2, testAdminTx(t, keykeeper, &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: &index, EncryptionPublicKey: priv.Public().Bytes()})); code != CodeTxInvalid {
+		t.Fatalf("keys without commitment: got code %d, expected %d", code, CodeTxInvalid)
+	}
+	if code := deliver(3, testAdminTx(t, keykeeper, &models.AdminTx{Txtype: models.TxType_ADD_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: &index, EncryptionPublicKey: priv.Public().Bytes(),
+		CommitmentKey: snarks.Poseidon.Hash(reveal)})); code != 0 {
+		t.Fatalf("add keys: got code %d", code)
+	}
+
+	// another oracle cannot reveal the keys of the keykeeper, nor get a
+	// mismatch recorded against it
+	impostorTx := testAdminTx(t, impostor, &models.AdminTx{Txtype: models.TxType_REVEAL_PROCESS_KEYS, ProcessId: pid,
+		KeyIndex: &index, EncryptionPrivateKey: other.Bytes(), RevealKey: reveal})
+	if checkCode, _ := send(19, impostorTx); checkCode != CodeUnauthorized {
+		t.Fatalf("reveal of another keykeeper: got code %d, expected %d", checkCode, CodeUnauthorized)
+	}
+	if code := deliver(20, impostorTx); code != CodeUnauthorized {
+		t.Fatalf("reveal of another keykeeper delivered: got code %d, expected %d", code, CodeUnauthorized)
+	}
+
+	// all the published keys must be revealed, and match. A mismatched reveal
+	// reaches a block, where it is rejected and recorded only once.
+	for i, tc := range []struct {
+		tx          *models.AdminTx
+		checkCode   uint32
+		deliverCode uint32
+	}{
+		{&models.AdminTx{EncryptionPrivateKey: priv.Bytes()}, CodeTxInvalid, CodeTxInvalid},
+		{&models.AdminTx{EncryptionPrivateKey: priv.Bytes()[1:], RevealKey: reveal}, CodeTxInvalid, CodeTxInvalid},
+		{&models.AdminTx{EncryptionPrivateKey: other.Bytes(), RevealKey: reveal}, 0, CodeKeyMismatch},
+		{&models.AdminTx{EncryptionPrivateKey: priv.Bytes(), RevealKey: util.RandomBytes(32)}, CodeKeyMismatch, CodeKeyMismatch},
+		{&models.AdminTx{EncryptionPrivateKey: priv.Bytes(), RevealKey: reveal}, 0, 0},
+	} {
+		tc.tx.Txtype = models.TxType_REVEAL_PROCESS_KEYS
+		tc.tx.ProcessId = pid
+		tc.tx.KeyIndex = &index
+		checkCode, deliverCode := send(int64(21+i), testAdminTx(t, keykeeper, tc.tx))
+		if checkCode != tc.checkCode || deliverCode != tc.deliverCode {
+			t.Fatalf("reveal %d: got codes %d and %d, expected %d and %d",
+				i, checkCode, deliverCode, tc.checkCode, tc.deliverCode)
+		}
+	}
+	evidence, err := app.State.ProcessKeyKeeperEvidence(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evidence) != 1 {
+		t.Fatalf("got %d keykeeper evidences, expected 1", len(evidence))
+	}
+	for _, e := range evidence {
+		if e.KeyIndex != index || e.KeyKeeper != keykeeper.Address() {
+			t.Errorf("got keykeeper evidence %+v, expected index %d", e, index)
+		}
+	}
+	p, err := app.State.Process(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.EncryptionPrivateKeys[index] != fmt.Sprintf("%x", priv.Bytes()) {
+		t.Errorf("got encryption key %q, expected %x", p.EncryptionPrivateKeys[index], priv.Bytes())
+	}
+}
@@ -0,0 +1,126 @@
+package vochain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.vocdoni.io/dvote/log"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// processLimitsPrefix+processId stores the vote limits of a process
+var processLimitsPrefix = []byte("processLimits/")
+
+// ProcessLimits are the optional vote limits of a process. MaxCensusSize is
+// the maximum number of votes the process accepts (overwrites do not count).
+// PetitionThreshold is the number of votes which ends the process, as soon as
+// the vote reaching it is delivered. A zero value disables the limit.
+//
+// The limits belong to the process definition, but the models.Process of the
+// protocol buffers release in use has no fields for them, so a NEW_PROCESS
+// transaction cannot declare them yet. Until it does, they are only set by
+// the node through SetProcessLimits.
+type ProcessLimits struct {
+	MaxCensusSize     uint64 `json:"maxCensusSize,omitempty"`
+	PetitionThreshold uint64 `json:"petitionThreshold,omitempty"`
+}
+
+// SetProcessLimits sets the vote limits of a process. The limits must be set
+// before the process receives any vote.
+func (v *State) SetProcessLimits(pid []byte, limits *ProcessLimits) error {
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if err := checkProcessLimits(v, process, limits); err != nil {
+		return err
+	}
+	limitsBytes, err := json.Marshal(limits)
+	if err != nil {
+		return fmt.Errorf("cannot marshal process limits: %w", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(append(append([]byte{}, processLimitsPrefix...), pid...), limitsBytes)
+}
+
+// checkProcessLimits checks the limits are valid and can be set on the process
+func checkProcessLimits(state *State, process *models.Process, limits *ProcessLimits) error {
+	if process.Status != models.ProcessStatus_READY && process.Status != models.ProcessStatus_PAUSED {
+		return fmt.Errorf("cannot set limits of a process with status %s", process.Status.String())
+	}
+	if state.CountVotes(process.ProcessId, false) > 0 {
+		return fmt.Errorf("cannot set limits of a process with votes")
+	}
+	if limits.MaxCensusSize > 0 && limits.PetitionThreshold > limits.MaxCensusSize {
+		return fmt.Errorf("petition threshold %d is greater than the max census size %d",
+			limits.PetitionThreshold, limits.MaxCensusSize)
+	}
+	return nil
+}
+
+// ProcessLimits returns the vote limits of a process, which are empty if not set
+func (v *State) ProcessLimits(pid []byte, isQuery bool) (*ProcessLimits, error) {
+	key := append(append([]byte{}, processLimitsPrefix...), pid...)
+	var limitsBytes []byte
+	v.RLock()
+	if isQuery {
+		limitsBytes = v.Store.ImmutableTree(AppTree).Get(key)
+	} else {
+		limitsBytes = v.Store.Tree(AppTree).Get(key)
+	}
+	v.RUnlock()
+	limits := &ProcessLimits{}
+	if len(limitsBytes) == 0 {
+		return limits, nil
+	}
+	if err := json.Unmarshal(limitsBytes, limits); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal process limits: %w", err)
+	}
+	return limits, nil
+}
+
+// checkVoteLimits returns an error if the vote identified by nullifier is a
+// new vote and the process already reached its max census size
+func checkVoteLimits(state *State, process *models.Process, nullifier []byte) error {
+	limits, err := state.ProcessLimits(process.ProcessId, false)
+	if err != nil {
+		return err
+	}
+	if limits.MaxCensusSize == 0 || state.EnvelopeExists(process.ProcessId, nullifier, false) {
+		return nil
+	}
+	if votes := uint64(state.CountVotes(process.ProcessId, false)); votes >= limits.MaxCensusSize {
+		return fmt.Errorf("%w: process %x has %d votes", ErrMaxCensusSize, process.ProcessId, votes)
+	}
+	return nil
+}
+
+// checkPetitionThreshold ends the process if it is ready and the number of
+// votes reached its petition threshold. It must be called after adding a vote.
+func (v *State) checkPetitionThreshold(pid []byte) error {
+	limits, err := v.ProcessLimits(pid, false)
+	if err != nil {
+		return err
+	}
+	if limits.PetitionThreshold == 0 || uint64(v.CountVotes(pid, false)) < limits.PetitionThreshold {
+		return nil
+	}
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if process.Status != models.ProcessStatus_READY {
+		return nil
+	}
+	// the threshold ends the process even if it is not interruptible
+	process.Status = models.ProcessStatus_ENDED
+	if err := v.setProcess(process, pid); err != nil {
+		return err
+	}
+	for _, l := range v.eventListeners {
+		l.OnProcessStatusChange(pid, process.Status)
+	}
+	log.Infof("process %x ended, petition threshold of %d votes reached", pid, limits.PetitionThreshold)
+	return nil
+}
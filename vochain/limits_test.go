@@ -0,0 +1,135 @@
+package vochain
+
+import (
+	"encoding/hex"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tree "go.vocdoni.io/dvote/censustree/gravitontree"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestProcessLimits(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := tree.NewTree("testlimits", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := util.CreateEthRandomKeysBatch(4)
+	claims := [][]byte{}
+	for _, k := range keys {
+		pub, _ := k.HexString()
+		if pub, err = ethereum.DecompressPubKey(pub); err != nil {
+			t.Fatal(err)
+		}
+		pubb, err := hex.DecodeString(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims = append(claims, snarks.Poseidon.Hash(pubb))
+		if err := tr.Add(claims[len(claims)-1], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	proofs := [][]byte{}
+	for _, c := range claims {
+		proof, err := tr.GenProof(c, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proofs = append(proofs, proof)
+	}
+
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddOracle(oracle.Address()); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	// the processes are created and get their limits on a block, they start on the next one
+	height := int64(1)
+	censusURI := "ipfs://123456789"
+	processWithLimits := func(limits *ProcessLimits) []byte {
+		t.Helper()
+		height++
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		process := testNewProcess(uint32(height+1), 1024)
+		process.CensusRoot = tr.Root()
+		process.CensusURI = &censusURI
+		process.CensusOrigin = models.CensusOrigin_OFF_CHAIN_TREE
+		if res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: testNewProcessTx(t, oracle, process)}); res.Code != 0 {
+			t.Fatalf("cannot create process: %s", res.Data)
+		}
+		if limits != nil {
+			if err := app.State.SetProcessLimits(process.ProcessId, limits); err != nil {
+				t.Fatalf("cannot set process limits: %v", err)
+			}
+		}
+		app.Commit()
+		height++
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		return process.ProcessId
+	}
+	vote := func(pid []byte, voter int) uint32 {
+		tx := &models.VoteEnvelope{
+			Nonce:       util.RandomBytes(32),
+			ProcessId:   pid,
+			Proof:       &models.Proof{Payload: &models.Proof_Graviton{Graviton: &models.ProofGraviton{Siblings: proofs[voter]}}},
+			VotePackage: []byte("[1]"),
+		}
+		txBytes := testSignTx(t, keys[voter], &models.Tx{Payload: &models.Tx_Vote{Vote: tx}}, tx)
+		if res := app.CheckTx(abcitypes.RequestCheckTx{Tx: txBytes}); res.Code != 0 {
+			return res.Code
+		}
+		res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txBytes})
+		app.Commit()
+		return res.Code
+	}
+
+	// the votes over the max census size are rejected
+	pid := processWithLimits(&ProcessLimits{MaxCensusSize: 2})
+	for i, expected := range []uint32{0, 0, CodeMaxCensusSize} {
+		if code := vote(pid, i); code != expected {
+			t.Fatalf("vote %d: got code %d, expected %d", i, code, expected)
+		}
+	}
+	if err := app.State.SetProcessLimits(pid, &ProcessLimits{MaxCensusSize: 3}); err == nil {
+		t.Fatal("limits of a process with votes should not be updated")
+	}
+
+	// the petition ends once the threshold is reached
+	pid = processWithLimits(&ProcessLimits{PetitionThreshold: 2})
+	for i, expected := range []uint32{0, 0, CodeProcessNotActive} {
+		if code := vote(pid, i); code != expected {
+			t.Fatalf("petition vote %d: got code %d, expected %d", i, code, expected)
+		}
+	}
+	p, err := app.State.Process(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Status != models.ProcessStatus_ENDED {
+		t.Fatalf("petition status is %s, expected ENDED", p.Status)
+	}
+
+	if err := app.State.SetProcessLimits(util.RandomBytes(types.ProcessIDsize),
+		&ProcessLimits{MaxCensusSize: 1}); err == nil {
+		t.Fatal("limits of an unknown process should not be set")
+	}
+	if err := app.State.SetProcessLimits(processWithLimits(nil),
+		&ProcessLimits{MaxCensusSize: 1, PetitionThreshold: 2}); err == nil {
+		t.Fatal("petition threshold over the max census size should not be valid")
+	}
+}
@@ -0,0 +1,66 @@
+package vochain
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// MigrateState copies the last committed version of the state trees of src
+// into the empty state dst, commits it and checks both states hold the same
+// content. It is used to move a state to a different backend.
+func MigrateState(src, dst *State) error {
+	src.RLock()
+	defer src.RUnlock()
+	dst.Lock()
+	defer dst.Unlock()
+	for _, name := range stateTrees {
+		if dst.Store.Tree(name).Count() > 0 {
+			return fmt.Errorf("destination tree %s is not empty", name)
+		}
+		var err error
+		src.Store.ImmutableTree(name).Iterate(nil, func(key, value []byte) bool {
+			err = dst.Store.Tree(name).Add(key, value)
+			return err != nil
+		})
+		if err != nil {
+			return fmt.Errorf("cannot copy tree %s: %w", name, err)
+		}
+	}
+	if _, err := dst.Store.Commit(); err != nil {
+		return fmt.Errorf("cannot commit migrated state: %w", err)
+	}
+	return compareState(src, dst)
+}
+
+// CompareState returns an error if the last committed state trees of a and b
+// do not hold the same keys and values
+func CompareState(a, b *State) error {
+	a.RLock()
+	defer a.RUnlock()
+	if a != b {
+		b.RLock()
+		defer b.RUnlock()
+	}
+	return compareState(a, b)
+}
+
+// compareState must be called with the states read locked
+func compareState(a, b *State) error {
+	for _, name := range stateTrees {
+		ta, tb := a.Store.ImmutableTree(name), b.Store.ImmutableTree(name)
+		if ta.Count() != tb.Count() {
+			return fmt.Errorf("tree %s has %d and %d keys", name, ta.Count(), tb.Count())
+		}
+		var err error
+		ta.Iterate(nil, func(key, value []byte) bool {
+			if !bytes.Equal(tb.Get(key), value) {
+				err = fmt.Errorf("tree %s differs on key %x", name, key)
+			}
+			return err != nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
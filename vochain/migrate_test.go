@@ -0,0 +1,65 @@
+package vochain
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestMigrateState(t *testing.T) {
+	srcDir := t.TempDir()
+	src, err := NewStateWithBackend(srcDir, StateBackendIAVL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		pid := util.RandomBytes(types.ProcessIDsize)
+		if err := src.AddProcess(&models.Process{ProcessId: pid, EntityId: util.RandomBytes(types.EntityIDsize)}); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 10; j++ {
+			if err := src.AddVote(&models.Vote{
+				ProcessId:   pid,
+				Nullifier:   util.RandomBytes(types.VoteNullifierSize),
+				VotePackage: util.RandomBytes(32),
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	src.Save()
+
+	// the backend of an existing state cannot change
+	if _, err := NewStateWithBackend(srcDir, StateBackendGraviton); err == nil {
+		t.Fatalf("iavl state opened with the graviton backend")
+	}
+
+	dst, err := NewStateWithBackend(t.TempDir(), StateBackendGraviton)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateState(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := CompareState(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if n := dst.CountProcesses(true); n != 10 {
+		t.Errorf("got %d processes on the migrated state, expected 10", n)
+	}
+	if err := MigrateState(src, dst); err == nil {
+		t.Errorf("state migrated to a non empty state")
+	}
+
+	// states with different content
+	if err := src.AddOracle(common.BytesToAddress(util.RandomBytes(20))); err != nil {
+		t.Fatal(err)
+	}
+	src.Save()
+	if err := CompareState(src, dst); err == nil {
+		t.Errorf("different states reported as equal")
+	}
+}
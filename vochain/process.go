@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"fmt"
 
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/statedb"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/proto/build/go/models"
 	"google.golang.org/protobuf/proto"
@@ -17,6 +20,9 @@ func (v *State) AddProcess(p *models.Process) error {
 	}
 	v.Lock()
 	err = v.Store.Tree(ProcessTree).Add(p.ProcessId, newProcessBytes)
+	if err == nil {
+		err = v.scheduleProcessEnd(p)
+	}
 	v.Unlock()
 	if err != nil {
 		return err
@@ -83,11 +89,24 @@ func (v *State) Process(pid []byte, isQuery bool) (*models.Process, error) {
 func (v *State) CountProcesses(isQuery bool) int64 {
 	v.RLock()
 	defer v.RUnlock()
+	tree := v.Store.Tree(ProcessTree)
 	if isQuery {
-		return int64(v.Store.ImmutableTree(ProcessTree).Count())
+		tree = v.Store.ImmutableTree(ProcessTree)
 	}
-	return int64(v.Store.Tree(ProcessTree).Count())
+	return countProcesses(tree)
+}
 
+// countProcesses returns the number of processes of the process tree, which
+// also holds the process end schedule
+func countProcesses(tree statedb.StateTree) int64 {
+	count := int64(tree.Count())
+	tree.Iterate(processEndPrefix, func(key, value []byte) bool {
+		if isProcessEndKey(key) {
+			count--
+		}
+		return false
+	})
+	return count
 }
 
 // set process stores in the database the process
@@ -177,17 +196,28 @@ func (v *State) SetProcessStatus(pid []byte, newstatus models.ProcessStatus, com
 	return nil
 }
 
-// SetProcessResults sets the results for a given process. Only if the process status
-// allows and the format of the results allows to do so
-// TODO: allow result confirm with another Tx (in order to have N/M oracle signatures)
-func (v *State) SetProcessResults(pid []byte, result *models.ProcessResult, commit bool) error {
+// SetProcessResults stores the results of a process submitted by an oracle. The
+// process status changes to RESULTS once a quorum of oracles (see ResultsQuorum)
+// have submitted identical results. Conflicting submissions are recorded as evidence.
+func (v *State) SetProcessResults(pid []byte, result *models.ProcessResult, oracle common.Address, commit bool) error {
 	process, err := v.Process(pid, false)
 	if err != nil {
 		return err
 	}
+	if result == nil {
+		return fmt.Errorf("results are nil")
+	}
+	hash, err := ResultsHash(result)
+	if err != nil {
+		return err
+	}
 	// Check if the state transition is valid
 	if process.Status == models.ProcessStatus_RESULTS {
-		if process.Results != result {
+		storedHash, err := ResultsHash(process.Results)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hash, storedHash) {
 			return fmt.Errorf("results provided differ from already stored results: got: %+v, have: %+v", result, process.Results)
 		}
 		return fmt.Errorf("same results already added")
@@ -201,7 +231,32 @@ func (v *State) SetProcessResults(pid []byte, result *models.ProcessResult, comm
 			return fmt.Errorf("invalid entity id on result provided, expected: %x got: %x", process.EntityId, result.EntityId)
 		}
 
+		v.RLock()
+		submitted := v.Store.Tree(AppTree).Get(resultsSubmissionKey(pid, oracle))
+		v.RUnlock()
+		if submitted != nil {
+			return fmt.Errorf("oracle %s already submitted results", oracle.Hex())
+		}
+
 		if commit {
+			agreements, err := v.addResultsSubmission(pid, oracle, result, hash)
+			if err != nil {
+				return err
+			}
+			// the quorum cannot be higher than the number of oracles
+			quorum := v.ResultsQuorum(false)
+			oracles, err := v.Oracles(false)
+			if err != nil {
+				return err
+			}
+			if int(quorum) > len(oracles) {
+				quorum = uint32(len(oracles))
+			}
+			if agreements < quorum {
+				log.Infof("results for process %x submitted by oracle %s (%d/%d)",
+					pid, oracle.Hex(), agreements, quorum)
+				return nil
+			}
 			process.Results = result
 			process.Status = models.ProcessStatus_RESULTS
 			if err := v.setProcess(process, process.ProcessId); err != nil {
@@ -251,6 +306,37 @@ func (v *State) SetProcessCensus(pid, censusRoot []byte, censusURI string, commi
 	return nil
 }
 
+// SetProcessQuestionIndex moves a serial process to its next question. Only
+// the votes for the current question are accepted, so the index can only be
+// incremented by one and must be lower than the number of questions.
+func (v *State) SetProcessQuestionIndex(pid []byte, questionIndex uint32, commit bool) error {
+	process, err := v.Process(pid, false)
+	if err != nil {
+		return err
+	}
+	if !process.EnvelopeType.GetSerial() {
+		return fmt.Errorf("cannot set question index, process is not serial")
+	}
+	if process.Status != models.ProcessStatus_READY {
+		return fmt.Errorf("cannot set question index, process status must be READY and is: %s", process.Status.String())
+	}
+	if questionIndex != process.GetQuestionIndex()+1 {
+		return fmt.Errorf("cannot set question index to %d, current index is %d", questionIndex, process.GetQuestionIndex())
+	}
+	if questionIndex >= process.GetQuestionCount() {
+		return fmt.Errorf("cannot set question index to %d, process has %d questions", questionIndex, process.GetQuestionCount())
+	}
+
+	if commit {
+		process.QuestionIndex = &questionIndex
+		if err := v.setProcess(process, process.ProcessId); err != nil {
+			return err
+		}
+		log.Infof("process %x moved to question %d", pid, questionIndex)
+	}
+	return nil
+}
+
 // NewProcessTxCheck is an abstraction of ABCI checkTx for creating a new process
 func NewProcessTxCheck(vtx *models.Tx, state *State) (*models.Process, error) {
 	tx := vtx.GetNewProcess()
@@ -259,7 +345,7 @@ func NewProcessTxCheck(vtx *models.Tx, state *State) (*models.Process, error) {
 	}
 	// check signature available
 	if vtx.Signature == nil || tx == nil {
-		return nil, fmt.Errorf("missing signature or new process transaction")
+		return nil, fmt.Errorf("%w: missing signature or new process transaction", ErrInvalidSignature)
 	}
 	// get oracles
 	oracles, err := state.Oracles(false)
@@ -287,20 +373,26 @@ func NewProcessTxCheck(vtx *models.Tx, state *State) (*models.Process, error) {
 		return nil, err
 	}
 	if !authorized {
-		return nil, fmt.Errorf("unauthorized to create a process, recovered addr is %s", addr.Hex())
+		return nil, fmt.Errorf("%w: unauthorized to create a process, recovered addr is %s", ErrUnauthorized, addr.Hex())
 	}
 	// get process
 	_, err = state.Process(tx.Process.ProcessId, false)
 	if err == nil {
-		return nil, fmt.Errorf("process with id (%x) already exists", tx.Process.ProcessId)
+		return nil, fmt.Errorf("%w: process with id (%x)", ErrProcessExists, tx.Process.ProcessId)
 	}
 
 	// check valid/implemented process types
 	switch {
 	case tx.Process.EnvelopeType.Anonymous:
+		// the votes could not carry their zk-SNARK proof, see checkSnarkVote
 		return nil, fmt.Errorf("anonymous process not yet implemented")
-	case tx.Process.EnvelopeType.Serial:
-		return nil, fmt.Errorf("serial process not yet implemented")
+	case tx.Process.EnvelopeType.Serial && tx.Process.EnvelopeType.EncryptedVotes:
+		// the question of an encrypted vote cannot be checked until the end
+		return nil, fmt.Errorf("encrypted serial process not supported")
+	case tx.Process.EnvelopeType.Serial && tx.Process.GetQuestionCount() == 0:
+		return nil, fmt.Errorf("serial process without questions")
+	case tx.Process.EnvelopeType.Serial && tx.Process.GetQuestionIndex() != 0:
+		return nil, fmt.Errorf("serial process must start on the first question")
 	}
 
 	if tx.Process.EnvelopeType.EncryptedVotes || tx.Process.EnvelopeType.Anonymous {
@@ -313,44 +405,50 @@ func NewProcessTxCheck(vtx *models.Tx, state *State) (*models.Process, error) {
 	return tx.Process, nil
 }
 
-// SetProcessTxCheck is an abstraction of ABCI checkTx for canceling an existing process
-func SetProcessTxCheck(vtx *models.Tx, state *State) error {
+// SetProcessTxCheck is an abstraction of ABCI checkTx for canceling an existing process.
+// Returns the address of the oracle which signed the transaction.
+func SetProcessTxCheck(vtx *models.Tx, state *State) (common.Address, error) {
 	tx := vtx.GetSetProcess()
 	// check signature available
 	if vtx.Signature == nil || tx == nil {
-		return fmt.Errorf("missing signature on set process transaction")
+		return common.Address{}, fmt.Errorf("%w: missing signature on set process transaction", ErrInvalidSignature)
 	}
 	// get oracles
 	oracles, err := state.Oracles(false)
 	if err != nil || len(oracles) == 0 {
-		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+		return common.Address{}, fmt.Errorf("cannot check authorization against a nil or empty oracle list")
 	}
 	// check signature
 	signedBytes, err := proto.Marshal(tx)
 	if err != nil {
-		return fmt.Errorf("cannot marshal new process transaction")
+		return common.Address{}, fmt.Errorf("cannot marshal new process transaction")
 	}
 	authorized, addr, err := verifySignatureAgainstOracles(oracles, signedBytes, vtx.Signature)
 	if err != nil {
-		return err
+		return common.Address{}, err
 	}
 	if !authorized {
-		return fmt.Errorf("unauthorized to set process status, recovered addr is %s", addr.Hex())
+		return common.Address{}, fmt.Errorf("%w: unauthorized to set process status, recovered addr is %s", ErrUnauthorized, addr.Hex())
 	}
 	// get process
 	process, err := state.Process(tx.ProcessId, false)
 	if err != nil {
-		return fmt.Errorf("cannot get process %x: %w", tx.ProcessId, err)
+		return common.Address{}, fmt.Errorf("cannot get process %x: %w", tx.ProcessId, err)
 	}
 
 	switch tx.Txtype {
 	case models.TxType_SET_PROCESS_RESULTS:
-		return state.SetProcessResults(process.ProcessId, tx.GetResults(), false)
+		return addr, state.SetProcessResults(process.ProcessId, tx.GetResults(), addr, false)
 	case models.TxType_SET_PROCESS_STATUS:
-		return state.SetProcessStatus(process.ProcessId, tx.GetStatus(), false)
+		return addr, state.SetProcessStatus(process.ProcessId, tx.GetStatus(), false)
 	case models.TxType_SET_PROCESS_CENSUS:
-		return state.SetProcessCensus(process.ProcessId, tx.GetCensusRoot(), tx.GetCensusURI(), false)
+		return addr, state.SetProcessCensus(process.ProcessId, tx.GetCensusRoot(), tx.GetCensusURI(), false)
+	case models.TxType_SET_PROCESS_QUESTION_INDEX:
+		if tx.QuestionIndex == nil {
+			return common.Address{}, fmt.Errorf("question index is nil")
+		}
+		return addr, state.SetProcessQuestionIndex(process.ProcessId, tx.GetQuestionIndex(), false)
 	default:
-		return fmt.Errorf("unknown set process tx type: %s", tx.Txtype)
+		return common.Address{}, fmt.Errorf("unknown set process tx type: %s", tx.Txtype)
 	}
 }
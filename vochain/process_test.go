@@ -1,12 +1,15 @@
 package vochain
 
 import (
+	"encoding/hex"
 	"fmt"
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
 	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tree "go.vocdoni.io/dvote/censustree/gravitontree"
 	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/snarks"
 	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -279,6 +282,112 @@ func TestProcessSetResultsTransition(t *testing.T) {
 	}
 }
 
+func TestProcessSetResultsQuorum(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracles := make([]ethereum.SignKeys, 3)
+	for i := range oracles {
+		if err := oracles[i].Generate(); err != nil {
+			t.Fatal(err)
+		}
+		if err := app.State.AddOracle(common.HexToAddress(oracles[i].AddressString())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := app.State.SetResultsQuorum(2); err != nil {
+		t.Fatal(err)
+	}
+
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := &models.Process{
+		ProcessId:    pid,
+		StartBlock:   0,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		Status:       models.ProcessStatus_ENDED,
+		EntityId:     util.RandomBytes(types.EntityIDsize),
+		CensusRoot:   util.RandomBytes(32),
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}
+	if err := app.State.AddProcess(process); err != nil {
+		t.Fatal(err)
+	}
+	results := &models.ProcessResult{
+		ProcessId: pid,
+		EntityId:  process.EntityId,
+		Votes:     []*models.QuestionResult{{Question: [][]byte{{1}, {2}}}},
+	}
+	fakeResults := &models.ProcessResult{
+		ProcessId: pid,
+		EntityId:  process.EntityId,
+		Votes:     []*models.QuestionResult{{Question: [][]byte{{2}, {1}}}},
+	}
+	status := func() models.ProcessStatus {
+		p, err := app.State.Process(pid, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.Status
+	}
+
+	// a single oracle is not enough
+	if err := testSetProcessResults(t, pid, &oracles[0], app, results); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s != models.ProcessStatus_ENDED {
+		t.Fatalf("process status is %s after a single submission", s)
+	}
+	if err := testSetProcessResults(t, pid, &oracles[0], app, results); err == nil {
+		t.Fatal("the same oracle submitted results twice")
+	}
+
+	// a conflicting submission is recorded as evidence
+	if err := testSetProcessResults(t, pid, &oracles[1], app, fakeResults); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s != models.ProcessStatus_ENDED {
+		t.Fatalf("process status is %s after conflicting submissions", s)
+	}
+	evidence, err := app.State.ProcessResultsEvidence(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evidence) != 1 {
+		t.Fatalf("expected 1 evidence, got %d", len(evidence))
+	}
+	if evidence[0].Oracle != oracles[1].Address() || evidence[0].ConflictingOracle != oracles[0].Address() {
+		t.Errorf("wrong evidence oracles: %+v", evidence[0])
+	}
+
+	// the quorum is reached with a second identical submission
+	if err := testSetProcessResults(t, pid, &oracles[2], app, results); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(); s != models.ProcessStatus_RESULTS {
+		t.Fatalf("process status is %s after reaching the quorum", s)
+	}
+	p, err := app.State.Process(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(p.Results, results) {
+		t.Errorf("wrong results stored: %v", p.Results)
+	}
+	submissions, err := app.State.ProcessResultsSubmissions(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(submissions) != 3 {
+		t.Errorf("expected 3 submissions, got %d", len(submissions))
+	}
+	if evidence, _ := app.State.ProcessResultsEvidence(pid, true); len(evidence) != 2 {
+		t.Errorf("expected 2 evidence, got %d", len(evidence))
+	}
+}
+
 func testSetProcessResults(t *testing.T, pid []byte, oracle *ethereum.SignKeys, app *BaseApplication, results *models.ProcessResult) error {
 	var cktx abcitypes.RequestCheckTx
 	var detx abcitypes.RequestDeliverTx
@@ -445,3 +554,227 @@ func testSetProcessCensus(t *testing.T, pid []byte, oracle *ethereum.SignKeys, a
 	app.Commit()
 	return nil
 }
+
+func TestProcessSetQuestionIndex(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.SignKeys{}
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddOracle(common.HexToAddress(oracle.AddressString())); err != nil {
+		t.Fatal(err)
+	}
+
+	// a census with a single voter
+	tr, err := tree.NewTree("testquestionindex", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	voter := ethereum.SignKeys{}
+	if err := voter.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := voter.HexString()
+	if pub, err = ethereum.DecompressPubKey(pub); err != nil {
+		t.Fatal(err)
+	}
+	pubb, err := hex.DecodeString(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := snarks.Poseidon.Hash(pubb)
+	if err := tr.Add(claim, nil); err != nil {
+		t.Fatal(err)
+	}
+	proof, err := tr.GenProof(claim, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	censusURI := "ipfs://123456789"
+	questionCount := uint32(2)
+	pid := util.RandomBytes(types.ProcessIDsize)
+	app.State.AddProcess(&models.Process{
+		ProcessId:     pid,
+		StartBlock:    0,
+		EnvelopeType:  &models.EnvelopeType{Serial: true},
+		Mode:          &models.ProcessMode{Interruptible: true},
+		Status:        models.ProcessStatus_READY,
+		EntityId:      util.RandomBytes(types.EntityIDsize),
+		CensusRoot:    tr.Root(),
+		CensusURI:     &censusURI,
+		CensusOrigin:  models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:    1024,
+		QuestionCount: &questionCount,
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 3},
+	})
+	notSerialPid := util.RandomBytes(types.ProcessIDsize)
+	app.State.AddProcess(&models.Process{
+		ProcessId:    notSerialPid,
+		StartBlock:   0,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{Interruptible: true},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EntityIDsize),
+		CensusRoot:   tr.Root(),
+		CensusURI:    &censusURI,
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	})
+
+	vote := func(votePackage string) uint32 {
+		tx := &models.VoteEnvelope{
+			Nonce:       util.RandomBytes(32),
+			ProcessId:   pid,
+			Proof:       &models.Proof{Payload: &models.Proof_Graviton{Graviton: &models.ProofGraviton{Siblings: proof}}},
+			VotePackage: []byte(votePackage),
+		}
+		txBytes, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtx := &models.Tx{Payload: &models.Tx_Vote{Vote: tx}}
+		if vtx.Signature, err = voter.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		if txBytes, err = proto.Marshal(vtx); err != nil {
+			t.Fatal(err)
+		}
+		if res := app.CheckTx(abcitypes.RequestCheckTx{Tx: txBytes}); res.Code != 0 {
+			return res.Code
+		}
+		res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txBytes})
+		app.Commit()
+		return res.Code
+	}
+
+	// only the votes for the first question are accepted
+	if code := vote(`{"questionIndex":1,"votes":[2]}`); code != CodeInvalidQuestion {
+		t.Fatalf("vote for the next question: got code %d, expected %d", code, CodeInvalidQuestion)
+	}
+	if code := vote(`{"votes":[2]}`); code != CodeInvalidQuestion {
+		t.Fatalf("vote without question index: got code %d, expected %d", code, CodeInvalidQuestion)
+	}
+	if code := vote(`{"questionIndex":0,"votes":[2]}`); code != 0 {
+		t.Fatalf("vote for the current question failed with code %d", code)
+	}
+
+	// the index can only be incremented by one
+	if err := testSetProcessQuestionIndex(t, pid, &oracle, app, 2); err == nil {
+		t.Fatal("skipping a question should not be valid")
+	}
+	if err := testSetProcessQuestionIndex(t, pid, &oracle, app, 1); err != nil {
+		t.Fatal(err)
+	}
+	p, err := app.State.Process(pid, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GetQuestionIndex() != 1 {
+		t.Fatalf("got question index %d, expected 1", p.GetQuestionIndex())
+	}
+
+	// the same voter can vote the new question, the previous one is closed
+	if code := vote(`{"questionIndex":0,"votes":[1]}`); code != CodeInvalidQuestion {
+		t.Fatalf("vote for the previous question: got code %d, expected %d", code, CodeInvalidQuestion)
+	}
+	if code := vote(`{"questionIndex":1,"votes":[1]}`); code != 0 {
+		t.Fatalf("vote for the current question failed with code %d", code)
+	}
+
+	// there are no more questions
+	if err := testSetProcessQuestionIndex(t, pid, &oracle, app, 2); err == nil {
+		t.Fatal("question index out of the question count should not be valid")
+	}
+	if err := testSetProcessQuestionIndex(t, notSerialPid, &oracle, app, 1); err == nil {
+		t.Fatal("question index of a non serial process should not be valid")
+	}
+}
+
+func testSetProcessQuestionIndex(t *testing.T, pid []byte, oracle *ethereum.SignKeys, app *BaseApplication, questionIndex uint32) error {
+	var vtx models.Tx
+	tx := &models.SetProcessTx{
+		Txtype:        models.TxType_SET_PROCESS_QUESTION_INDEX,
+		Nonce:         util.RandomBytes(32),
+		ProcessId:     pid,
+		QuestionIndex: &questionIndex,
+	}
+	txBytes, err := proto.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vtx.Signature, err = oracle.Sign(txBytes); err != nil {
+		t.Fatal(err)
+	}
+	vtx.Payload = &models.Tx_SetProcess{SetProcess: tx}
+	if txBytes, err = proto.Marshal(&vtx); err != nil {
+		t.Fatal(err)
+	}
+	if res := app.CheckTx(abcitypes.RequestCheckTx{Tx: txBytes}); res.Code != 0 {
+		return fmt.Errorf("checkTx failed: %s", res.Data)
+	}
+	if res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txBytes}); res.Code != 0 {
+		return fmt.Errorf("deliverTx failed: %s", res.Data)
+	}
+	app.Commit()
+	return nil
+}
+
+// testNewProcess returns a ready process with random process and entity ids,
+// which accepts votes from startBlock during blockCount blocks
+func testNewProcess(startBlock, blockCount uint32) *models.Process {
+	return &models.Process{
+		ProcessId:    util.RandomBytes(types.ProcessIDsize),
+		EntityId:     util.RandomBytes(types.EntityIDsize),
+		StartBlock:   startBlock,
+		BlockCount:   blockCount,
+		Status:       models.ProcessStatus_READY,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+	}
+}
+
+// testAddProcess adds the process to the state and returns its id
+func testAddProcess(t *testing.T, app *BaseApplication, process *models.Process) []byte {
+	t.Helper()
+	if err := app.State.AddProcess(process); err != nil {
+		t.Fatal(err)
+	}
+	return process.ProcessId
+}
+
+// testSignTx signs the transaction payload and returns the encoded transaction
+func testSignTx(t *testing.T, signer *ethereum.SignKeys, tx *models.Tx, payload proto.Message) []byte {
+	t.Helper()
+	signedBytes, err := proto.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Signature, err = signer.Sign(signedBytes); err != nil {
+		t.Fatal(err)
+	}
+	txBytes, err := proto.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txBytes
+}
+
+// testNewProcessTx returns the encoded NEW_PROCESS transaction of the process
+// signed by signer
+func testNewProcessTx(t *testing.T, signer *ethereum.SignKeys, process *models.Process) []byte {
+	t.Helper()
+	tx := &models.NewProcessTx{Txtype: models.TxType_NEW_PROCESS, Nonce: util.RandomBytes(32), Process: process}
+	return testSignTx(t, signer, &models.Tx{Payload: &models.Tx_NewProcess{NewProcess: tx}}, tx)
+}
+
+// testAdminTx returns the encoded admin transaction signed by signer, with a
+// random nonce
+func testAdminTx(t *testing.T, signer *ethereum.SignKeys, tx *models.AdminTx) []byte {
+	t.Helper()
+	tx.Nonce = util.RandomBytes(32)
+	return testSignTx(t, signer, &models.Tx{Payload: &models.Tx_Admin{Admin: tx}}, tx)
+}
@@ -4,11 +4,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	abcitypes "github.com/tendermint/tendermint/abci/types"
 	"github.com/vocdoni/eth-storage-proof/ethstorageproof"
 	tree "go.vocdoni.io/dvote/censustree/gravitontree"
+	"go.vocdoni.io/dvote/censustree/iden3tree"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/snarks"
 	"go.vocdoni.io/dvote/test/testcommon/testutil"
@@ -109,6 +111,125 @@ func TestMerkleTreeProof(t *testing.T) {
 	}
 }
 
+func TestIden3Proof(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := iden3tree.NewTree("testiden3", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first half of the voters have weight 1 (empty value), the rest have weight i
+	keys := util.CreateEthRandomKeysBatch(10)
+	var digests, values [][]byte
+	for i, k := range keys {
+		pub, _ := k.HexString()
+		pub, err = ethereum.DecompressPubKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest := snarks.Poseidon.Hash(testutil.Hex2byte(t, pub))
+		var value []byte
+		if i >= len(keys)/2 {
+			value = big.NewInt(int64(i)).Bytes()
+		}
+		if err := tr.Add(digest, value); err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, digest)
+		values = append(values, value)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		StartBlock:   0,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EntityIDsize),
+		CensusRoot:   tr.Root(),
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := func(k *ethereum.SignKeys, proof []byte) (uint32, []byte) {
+		tx := &models.VoteEnvelope{
+			Nonce:       util.RandomBytes(32),
+			ProcessId:   pid,
+			Proof:       &models.Proof{Payload: &models.Proof_Iden3{Iden3: &models.ProofIden3{Siblings: proof}}},
+			VotePackage: []byte("[1,2,3,4]"),
+		}
+		txBytes, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtx := &models.Tx{Payload: &models.Tx_Vote{Vote: tx}}
+		if vtx.Signature, err = k.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		vtxBytes, err := proto.Marshal(vtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := app.CheckTx(abcitypes.RequestCheckTx{Tx: vtxBytes}); r.Code != 0 {
+			return r.Code, r.Data
+		}
+		r := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: vtxBytes})
+		return r.Code, r.Data
+	}
+
+	for i, k := range keys {
+		mproof, err := tr.GenProof(digests[i], values[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// a weighted leaf cannot be proven without its value
+		if values[i] != nil {
+			if code, _ := vote(k, mproof); code == 0 {
+				t.Fatalf("vote %d accepted without the census weight", i)
+			}
+			// neither with a different weight
+			if code, _ := vote(k, iden3tree.PackProof(mproof, []byte{100})); code == 0 {
+				t.Fatalf("vote %d accepted with a wrong census weight", i)
+			}
+		}
+		code, data := vote(k, iden3tree.PackProof(mproof, values[i]))
+		if code != 0 {
+			t.Fatalf("vote %d failed: %s", i, data)
+		}
+		app.Commit()
+		addr := k.Address()
+		v, err := app.State.Envelope(pid, GenerateNullifier(addr, pid), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		weight := big.NewInt(1)
+		if values[i] != nil {
+			weight.SetBytes(values[i])
+		}
+		if new(big.Int).SetBytes(v.Weight).Cmp(weight) != 0 {
+			t.Errorf("vote %d has weight %x, expected %s", i, v.Weight, weight)
+		}
+	}
+
+	// a key out of the census is rejected
+	k := ethereum.NewSignKeys()
+	if err := k.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	mproof, err := tr.GenProof(digests[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _ := vote(k, mproof); code == 0 {
+		t.Errorf("vote accepted for a key out of the census")
+	}
+}
+
 func TestCAProof(t *testing.T) {
 	app, err := NewBaseApplication(t.TempDir())
 	if err != nil {
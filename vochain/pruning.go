@@ -0,0 +1,105 @@
+package vochain
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/statedb/gravitonstate"
+)
+
+const (
+	// PruningArchive keeps all the state versions (no pruning)
+	PruningArchive = "archive"
+	// PruningKeepRecent keeps only the last KeepRecent state versions
+	PruningKeepRecent = "recent"
+	// PruningKeepEvery keeps every KeepEvery state version, plus the last KeepRecent ones
+	PruningKeepEvery = "every"
+
+	// MinPruningKeepRecent is the minimum number of recent versions to keep.
+	// On start the state is loaded at the previous version, which points to the
+	// tree versions of the one before it.
+	MinPruningKeepRecent = 3
+
+	// pruneBatchSize is the maximum number of versions removed on each Save, so
+	// enabling pruning on an archived state does not stall block production
+	pruneBatchSize = 100
+)
+
+// PruningPolicy defines which state versions are removed from the database
+// when a new version is saved. Tree lookups by root (TreeWithRoot) keep
+// working for the retained versions.
+type PruningPolicy struct {
+	Strategy   string
+	KeepRecent uint64
+	KeepEvery  uint64
+}
+
+// keep returns true if version must be retained, being last the last version
+func (p *PruningPolicy) keep(version, last uint64) bool {
+	switch p.Strategy {
+	case PruningKeepRecent:
+		return version+p.KeepRecent > last
+	case PruningKeepEvery:
+		return version+p.KeepRecent > last || version%p.KeepEvery == 0
+	}
+	return true
+}
+
+// SetPruningPolicy sets the pruning policy applied by Save
+func (v *State) SetPruningPolicy(policy PruningPolicy) error {
+	switch policy.Strategy {
+	case PruningArchive, "":
+		v.pruning = nil
+		return nil
+	case PruningKeepEvery:
+		if policy.KeepEvery == 0 {
+			return fmt.Errorf("pruning keep every must be greater than zero")
+		}
+	case PruningKeepRecent:
+	default:
+		return fmt.Errorf("unknown pruning strategy %q", policy.Strategy)
+	}
+	if policy.KeepRecent < MinPruningKeepRecent {
+		return fmt.Errorf("pruning must keep at least %d recent versions", MinPruningKeepRecent)
+	}
+	if _, ok := v.Store.(*gravitonstate.GravitonState); ok {
+		return fmt.Errorf("the graviton state backend does not support pruning")
+	}
+	v.pruning = &policy
+	log.Infof("state pruning enabled: strategy %s, keep recent %d, keep every %d",
+		policy.Strategy, policy.KeepRecent, policy.KeepEvery)
+	return nil
+}
+
+// PruningStats returns the number of state versions pruned and the bytes
+// reclaimed since the state was opened
+func (v *State) PruningStats() (versions, bytes uint64) {
+	return atomic.LoadUint64(&v.prunedVersions), atomic.LoadUint64(&v.prunedBytes)
+}
+
+// prune removes the state versions not retained by the pruning policy.
+// It must be called with the state locked.
+func (v *State) prune() {
+	if v.pruning == nil {
+		return
+	}
+	last := v.Store.Version()
+	pruned := 0
+	for _, version := range v.Store.Versions() {
+		if pruned >= pruneBatchSize || v.pruning.keep(version, last) {
+			continue
+		}
+		reclaimed, err := v.Store.DeleteVersion(version)
+		if err != nil {
+			log.Warnf("cannot prune state version %d: (%s)", version, err)
+			return
+		}
+		atomic.AddUint64(&v.prunedVersions, 1)
+		atomic.AddUint64(&v.prunedBytes, reclaimed)
+		pruned++
+	}
+	if pruned > 0 {
+		log.Debugf("pruned %d state versions", pruned)
+	}
+}
@@ -0,0 +1,70 @@
+package vochain
+
+import (
+	"testing"
+
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestStatePruning(t *testing.T) {
+	s, err := NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPruningPolicy(PruningPolicy{Strategy: PruningKeepRecent, KeepRecent: 1}); err == nil {
+		t.Fatalf("pruning policy keeping a single version accepted")
+	}
+	if err := s.SetPruningPolicy(PruningPolicy{Strategy: PruningKeepEvery, KeepRecent: 3, KeepEvery: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// processTree root of every version
+	roots := make(map[uint64][]byte)
+	for i := 0; i < 10; i++ {
+		if err := s.AddProcess(&models.Process{
+			ProcessId: util.RandomBytes(types.ProcessIDsize),
+			EntityId:  util.RandomBytes(types.EntityIDsize),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		s.Save()
+		roots[s.Store.Version()] = s.Store.ImmutableTree(ProcessTree).Hash()
+	}
+
+	last := s.Store.Version()
+	expected := []uint64{3, 6, 8, 9, 10}
+	versions := s.Store.Versions()
+	if len(versions) != len(expected) {
+		t.Fatalf("got versions %v, expected %v", versions, expected)
+	}
+	for i, v := range versions {
+		if v != expected[i] {
+			t.Fatalf("got versions %v, expected %v", versions, expected)
+		}
+	}
+	pruned, reclaimed := s.PruningStats()
+	if pruned != last-uint64(len(expected)) || reclaimed == 0 {
+		t.Errorf("got %d pruned versions and %d bytes reclaimed", pruned, reclaimed)
+	}
+
+	// the trees of the retained versions can still be found by their root
+	for v, root := range roots {
+		tree := s.Store.TreeWithRoot(root)
+		retained := s.pruning.keep(v, last)
+		if retained && (tree == nil || tree.Count() != v) {
+			t.Errorf("tree of retained version %d not found", v)
+		}
+		if !retained && tree != nil {
+			t.Errorf("tree of pruned version %d found", v)
+		}
+	}
+	// on start the state is loaded at the previous version
+	if err := s.Store.LoadVersion(-1); err != nil {
+		t.Fatal(err)
+	}
+	if count := s.Store.ImmutableTree(ProcessTree).Count(); count != last-1 {
+		t.Errorf("got %d processes on the previous version, expected %d", count, last-1)
+	}
+}
@@ -0,0 +1,145 @@
+package vochain
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmcrypto "github.com/tendermint/tendermint/proto/tendermint/crypto"
+	"go.vocdoni.io/dvote/statedb/iavlstate"
+	"go.vocdoni.io/dvote/types"
+)
+
+// ABCI query paths. The query data is the key of the requested value.
+const (
+	// QueryPathProcess returns a process, data is the processId
+	QueryPathProcess = "/process"
+	// QueryPathEnvelope returns a vote envelope, data is processId+nullifier
+	QueryPathEnvelope = "/envelope"
+	// QueryPathOracles returns the oracle list, data is empty
+	QueryPathOracles = "/oracles"
+	// QueryPathValidators returns the validator list, data is empty
+	QueryPathValidators = "/validators"
+	// QueryPathVoteCount returns the number of votes of a process (uint32 big endian),
+	// data is the processId. The vote count is not stored on the state trees, so the
+	// response carries no proof and the value must be trusted to the queried node.
+	QueryPathVoteCount = "/votecount"
+)
+
+// Proof operations returned by the ABCI queries. A value is verified in two steps:
+// first the ProofOpTree proof against the root of the tree holding the value, then
+// the hash of the concatenated ProofOpRoot data (in the returned order) must match
+// the app hash of the next block header. Only the IAVL state backend returns proofs,
+// the queries of the other paths fail on a Graviton state.
+const (
+	// ProofOpTree is the ICS23 IAVL merkle proof of the value against the root of its
+	// state tree, which iavlstate.Verify checks
+	ProofOpTree = "vochain:tree"
+	// ProofOpRoot contains the root of a state tree, its key is the name of the tree
+	ProofOpRoot = "vochain:root"
+)
+
+// Query returns a value of the state commited at the requested height (the last
+// one if zero) along with its merkle proof
+func (app *BaseApplication) Query(req abcitypes.RequestQuery) abcitypes.ResponseQuery {
+	header := app.State.Header(true)
+	if header == nil {
+		return queryError(fmt.Errorf("cannot get state header"))
+	}
+	if req.Height < 0 || req.Height > header.Height {
+		return queryError(fmt.Errorf("invalid query height %d, last height is %d", req.Height, header.Height))
+	}
+	height := header.Height
+	if req.Height != 0 {
+		height = req.Height
+	}
+	var tree string
+	var key []byte
+	switch req.Path {
+	case QueryPathProcess:
+		if len(req.Data) != types.ProcessIDsize {
+			return queryError(fmt.Errorf("wrong processID size %d", len(req.Data)))
+		}
+		tree, key = ProcessTree, req.Data
+	case QueryPathEnvelope:
+		if len(req.Data) != types.ProcessIDsize+types.VoteNullifierSize {
+			return queryError(fmt.Errorf("wrong envelope key size %d", len(req.Data)))
+		}
+		tree, key = VoteTree, req.Data
+	case QueryPathOracles:
+		tree, key = AppTree, oracleKey
+	case QueryPathValidators:
+		tree, key = AppTree, validatorKey
+	case QueryPathVoteCount:
+		if len(req.Data) != types.ProcessIDsize {
+			return queryError(fmt.Errorf("wrong processID size %d", len(req.Data)))
+		}
+		votes, err := app.State.CountVotesAtHeight(req.Data, uint32(req.Height))
+		if err != nil {
+			return queryError(err)
+		}
+		count := make([]byte, 4)
+		binary.BigEndian.PutUint32(count, votes)
+		// unproven, the vote count is not stored on the state trees
+		return abcitypes.ResponseQuery{Key: req.Data, Value: count, Height: height, Info: "unproven"}
+	default:
+		return queryError(fmt.Errorf("unknown query path %q", req.Path))
+	}
+	value, proofOps, err := app.State.QueryProofAtHeight(tree, key, uint32(req.Height))
+	if err != nil {
+		return queryError(err)
+	}
+	return abcitypes.ResponseQuery{
+		Key:      key,
+		Value:    value,
+		ProofOps: proofOps,
+		Height:   height,
+	}
+}
+
+func queryError(err error) abcitypes.ResponseQuery {
+	return abcitypes.ResponseQuery{Code: 1, Log: err.Error()}
+}
+
+// QueryProof returns the value of key in the last commited version of tree and
+// the proof operations required to verify it against the app hash.
+func (v *State) QueryProof(tree string, key []byte) ([]byte, *tmcrypto.ProofOps, error) {
+	return v.QueryProofAtHeight(tree, key, 0)
+}
+
+// QueryProofAtHeight returns the value of key in tree as commited at the block
+// height (the last one if zero) and the proof operations required to verify it
+// against the app hash of the next block header. The proofs can only be verified
+// with the IAVL spec, so it fails on other state backends.
+func (v *State) QueryProofAtHeight(tree string, key []byte, height uint32) ([]byte, *tmcrypto.ProofOps, error) {
+	v.RLock()
+	defer v.RUnlock()
+	if _, ok := v.Store.(*iavlstate.IavlState); !ok {
+		return nil, nil, fmt.Errorf("query proofs are only supported by the %s state backend", StateBackendIAVL)
+	}
+	trees, err := v.treesAtHeight(height)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := trees[tree]
+	if t == nil {
+		return nil, nil, fmt.Errorf("unknown tree %s", tree)
+	}
+	value := t.Get(key)
+	if value == nil {
+		return nil, nil, fmt.Errorf("key %x not found on tree %s", key, tree)
+	}
+	proof, err := t.Proof(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot generate proof: %w", err)
+	}
+	ops := []tmcrypto.ProofOp{{Type: ProofOpTree, Key: key, Data: proof}}
+	for _, name := range stateTrees {
+		ops = append(ops, tmcrypto.ProofOp{
+			Type: ProofOpRoot,
+			Key:  []byte(name),
+			Data: trees[name].Hash(),
+		})
+	}
+	return value, &tmcrypto.ProofOps{Ops: ops}, nil
+}
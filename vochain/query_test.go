@@ -0,0 +1,141 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/statedb/iavlstate"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestQueryProof(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddOracle(common.BytesToAddress(util.RandomBytes(20))); err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	process := &models.Process{ProcessId: pid, EntityId: util.RandomBytes(types.EntityIDsize)}
+	if err := app.State.AddProcess(process); err != nil {
+		t.Fatal(err)
+	}
+	vote := &models.Vote{ProcessId: pid, Nullifier: util.RandomBytes(types.VoteNullifierSize), VotePackage: []byte("1")}
+	if err := app.State.AddVote(vote); err != nil {
+		t.Fatal(err)
+	}
+	appHash := app.Commit().Data
+
+	// verify returns the value after checking the proof against the app hash
+	verify := func(res abcitypes.ResponseQuery, tree string) []byte {
+		t.Helper()
+		if res.Code != 0 {
+			t.Fatalf("query failed: %s", res.Log)
+		}
+		if res.Height != 1 {
+			t.Errorf("wrong query height %d", res.Height)
+		}
+		ops := res.ProofOps.GetOps()
+		if len(ops) != 1+len(stateTrees) || ops[0].Type != ProofOpTree {
+			t.Fatalf("unexpected proof operations: %v", ops)
+		}
+		var roots, root []byte
+		for _, op := range ops[1:] {
+			if op.Type != ProofOpRoot {
+				t.Fatalf("unexpected proof operation %s", op.Type)
+			}
+			if string(op.Key) == tree {
+				root = op.Data
+			}
+			roots = append(roots, op.Data...)
+		}
+		if !bytes.Equal(ethereum.HashRaw(roots), appHash) {
+			t.Fatalf("tree roots do not match with the app hash")
+		}
+		if !iavlstate.Verify(res.Key, ops[0].Data, root) {
+			t.Fatalf("invalid proof for key %x", res.Key)
+		}
+		return res.Value
+	}
+
+	value := verify(app.Query(abcitypes.RequestQuery{Path: QueryPathProcess, Data: pid}), ProcessTree)
+	p := &models.Process{}
+	if err := proto.Unmarshal(value, p); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(p.EntityId, process.EntityId) {
+		t.Errorf("wrong process returned")
+	}
+
+	value = verify(app.Query(abcitypes.RequestQuery{
+		Path: QueryPathEnvelope,
+		Data: append(append([]byte{}, pid...), vote.Nullifier...),
+	}), VoteTree)
+	v := &models.Vote{}
+	if err := proto.Unmarshal(value, v); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v.VotePackage, vote.VotePackage) {
+		t.Errorf("wrong envelope returned")
+	}
+
+	value = verify(app.Query(abcitypes.RequestQuery{Path: QueryPathOracles}), AppTree)
+	oracles := &models.OracleList{}
+	if err := proto.Unmarshal(value, oracles); err != nil {
+		t.Fatal(err)
+	}
+	if len(oracles.Oracles) != 1 {
+		t.Errorf("expected 1 oracle, got %d", len(oracles.Oracles))
+	}
+
+	res := app.Query(abcitypes.RequestQuery{Path: QueryPathVoteCount, Data: pid})
+	if res.Code != 0 || binary.BigEndian.Uint32(res.Value) != 1 {
+		t.Errorf("wrong vote count: %x (%s)", res.Value, res.Log)
+	}
+	if res.ProofOps != nil {
+		t.Errorf("vote count must not carry a proof")
+	}
+
+	// unknown keys, paths and heights must fail
+	if res := app.Query(abcitypes.RequestQuery{Path: QueryPathProcess, Data: util.RandomBytes(types.ProcessIDsize)}); res.Code == 0 {
+		t.Errorf("query for an unknown process must fail")
+	}
+	if res := app.Query(abcitypes.RequestQuery{Path: "/foo"}); res.Code == 0 {
+		t.Errorf("query for an unknown path must fail")
+	}
+	if res := app.Query(abcitypes.RequestQuery{Path: QueryPathOracles, Height: 100}); res.Code == 0 {
+		t.Errorf("query for an unknown height must fail")
+	}
+}
+
+func TestQueryProofGraviton(t *testing.T) {
+	app, err := NewBaseApplicationWithBackend(t.TempDir(), StateBackendGraviton)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddOracle(common.BytesToAddress(util.RandomBytes(20))); err != nil {
+		t.Fatal(err)
+	}
+	app.Commit()
+
+	// there is no spec to verify the graviton proofs, the query must fail
+	res := app.Query(abcitypes.RequestQuery{Path: QueryPathOracles})
+	if res.Code == 0 {
+		t.Fatalf("query proof on a graviton state must fail")
+	}
+	if !strings.Contains(res.Log, StateBackendIAVL) {
+		t.Errorf("unexpected error: %s", res.Log)
+	}
+}
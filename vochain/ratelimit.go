@@ -0,0 +1,115 @@
+package vochain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.vocdoni.io/dvote/log"
+)
+
+// RateLimits defines the maximum number of transactions accepted by CheckTx
+// from each sender address and for each process on every Window. A zero limit
+// disables it. The limits are local to the node (the mempool), they do not
+// affect the transactions delivered on a block. The rejected transactions get
+// the CodeTxRateLimited result code.
+type RateLimits struct {
+	AddressTxs uint32
+	ProcessTxs uint32
+	Window     time.Duration
+}
+
+// rateLimiter counts the transactions accepted on the current fixed window
+type rateLimiter struct {
+	sync.Mutex
+	limits      RateLimits
+	windowStart time.Time
+	addresses   map[string]uint32
+	processes   map[string]uint32
+
+	rejectedAddress uint64
+	rejectedProcess uint64
+}
+
+// SetRateLimits sets the CheckTx rate limits, replacing the current counters
+func (v *State) SetRateLimits(limits RateLimits) error {
+	if limits.AddressTxs == 0 && limits.ProcessTxs == 0 {
+		v.rateLimiter = nil
+		return nil
+	}
+	if limits.Window <= 0 {
+		return fmt.Errorf("rate limit window must be greater than zero")
+	}
+	v.rateLimiter = &rateLimiter{
+		limits:    limits,
+		addresses: make(map[string]uint32),
+		processes: make(map[string]uint32),
+	}
+	log.Infof("transaction rate limits enabled: %d per address, %d per process every %s",
+		limits.AddressTxs, limits.ProcessTxs, limits.Window)
+	return nil
+}
+
+// RateLimitStats returns the number of transactions rejected by the address
+// and by the process rate limits
+func (v *State) RateLimitStats() (address, process uint64) {
+	rl := v.rateLimiter
+	if rl == nil {
+		return 0, 0
+	}
+	rl.Lock()
+	defer rl.Unlock()
+	return rl.rejectedAddress, rl.rejectedProcess
+}
+
+// checkRateLimit returns ErrTxRateLimited if the sender address or the process
+// (either of them can be nil) already reached its limit on the current window.
+// The transaction is not accounted, so the limits can be checked before the
+// expensive validations and the transaction charged (see chargeRateLimit) only
+// once it is valid. Otherwise invalid transactions would exhaust the limits of
+// the honest senders of a process.
+func (v *State) checkRateLimit(address, processID []byte) error {
+	rl := v.rateLimiter
+	if rl == nil {
+		return nil
+	}
+	rl.Lock()
+	defer rl.Unlock()
+	rl.updateWindow()
+	if address != nil && rl.limits.AddressTxs > 0 && rl.addresses[string(address)] >= rl.limits.AddressTxs {
+		rl.rejectedAddress++
+		return fmt.Errorf("%w: address %x", ErrTxRateLimited, address)
+	}
+	if processID != nil && rl.limits.ProcessTxs > 0 && rl.processes[string(processID)] >= rl.limits.ProcessTxs {
+		rl.rejectedProcess++
+		return fmt.Errorf("%w: process %x", ErrTxRateLimited, processID)
+	}
+	return nil
+}
+
+// chargeRateLimit accounts a valid transaction from the sender address for the
+// process, either of them can be nil
+func (v *State) chargeRateLimit(address, processID []byte) {
+	rl := v.rateLimiter
+	if rl == nil {
+		return
+	}
+	rl.Lock()
+	defer rl.Unlock()
+	rl.updateWindow()
+	if address != nil {
+		rl.addresses[string(address)]++
+	}
+	if processID != nil {
+		rl.processes[string(processID)]++
+	}
+}
+
+// updateWindow resets the counters if the current window expired, the lock must be held
+func (rl *rateLimiter) updateWindow() {
+	if now := time.Now(); now.Sub(rl.windowStart) >= rl.limits.Window {
+		rl.windowStart = now
+		rl.addresses = make(map[string]uint32)
+		rl.processes = make(map[string]uint32)
+	}
+}
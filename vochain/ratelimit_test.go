@@ -0,0 +1,91 @@
+package vochain
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCheckTxRateLimits(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.State.AddOracle(oracle.Address()); err != nil {
+		t.Fatal(err)
+	}
+	window := 200 * time.Millisecond
+	if err := app.State.SetRateLimits(RateLimits{AddressTxs: 2, ProcessTxs: 3, Window: window}); err != nil {
+		t.Fatal(err)
+	}
+	checkAdminTx := func(txType models.TxType) uint32 {
+		t.Helper()
+		tx := &models.AdminTx{
+			Txtype:  txType,
+			Address: util.RandomBytes(20),
+			Nonce:   util.RandomBytes(32),
+		}
+		txBytes, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtx := &models.Tx{Payload: &models.Tx_Admin{Admin: tx}}
+		if vtx.Signature, err = oracle.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		if txBytes, err = proto.Marshal(vtx); err != nil {
+			t.Fatal(err)
+		}
+		return app.CheckTx(abcitypes.RequestCheckTx{Tx: txBytes}).Code
+	}
+	checkTx := func() uint32 { return checkAdminTx(models.TxType_ADD_ORACLE) }
+
+	// the invalid transactions are not charged
+	for i := 0; i < 3; i++ {
+		if code := checkAdminTx(models.TxType_REMOVE_VALIDATOR); code == 0 || code == CodeTxRateLimited {
+			t.Fatalf("invalid transaction %d: got code %d", i, code)
+		}
+	}
+	for i, expected := range []uint32{0, 0, CodeTxRateLimited} {
+		if code := checkTx(); code != expected {
+			t.Fatalf("transaction %d: got code %d, expected %d", i, code, expected)
+		}
+	}
+	// the counters are reset on the next window
+	time.Sleep(window)
+	if code := checkTx(); code != 0 {
+		t.Fatalf("got code %d on a new window", code)
+	}
+
+	// the process limit is shared by all the senders, and only the charged
+	// transactions count
+	pid := util.RandomBytes(32)
+	for i := 0; i < 3; i++ {
+		if err := app.State.checkRateLimit(util.RandomBytes(20), pid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		app.State.chargeRateLimit(util.RandomBytes(20), pid)
+	}
+	if err := app.State.checkRateLimit(nil, pid); !errors.Is(err, ErrTxRateLimited) {
+		t.Errorf("process limit not enforced: %v", err)
+	}
+	if address, process := app.State.RateLimitStats(); address != 1 || process != 1 {
+		t.Errorf("got %d address and %d process rejections, expected 1 and 1", address, process)
+	}
+
+	if err := app.State.SetRateLimits(RateLimits{AddressTxs: 1}); err == nil {
+		t.Errorf("rate limits without window accepted")
+	}
+}
@@ -0,0 +1,200 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	// resultsQuorumKey stores the number of oracles that must agree on the results of a process
+	resultsQuorumKey = []byte("resultsQuorum")
+	// resultsSubmissionPrefix+processId+oracle stores the results submitted by an oracle
+	resultsSubmissionPrefix = []byte("resultsSubmission/")
+	// resultsEvidencePrefix+processId stores the list of conflicting results submissions
+	resultsEvidencePrefix = []byte("resultsEvidence/")
+)
+
+// ResultsEvidence records two oracles submitting different results for the same process
+type ResultsEvidence struct {
+	ProcessID              types.HexBytes `json:"processId"`
+	Height                 int64          `json:"height"`
+	Oracle                 common.Address `json:"oracle"`
+	ResultsHash            types.HexBytes `json:"resultsHash"`
+	ConflictingOracle      common.Address `json:"conflictingOracle"`
+	ConflictingResultsHash types.HexBytes `json:"conflictingResultsHash"`
+}
+
+// ResultsSubmission is the results of a process submitted by an oracle
+type ResultsSubmission struct {
+	Oracle  common.Address
+	Hash    []byte
+	Results *models.ProcessResult
+}
+
+// ResultsHash returns the hash used to compare results submitted by different oracles
+func ResultsHash(result *models.ProcessResult) ([]byte, error) {
+	resultBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal results: %w", err)
+	}
+	return ethereum.HashRaw(resultBytes), nil
+}
+
+// SetResultsQuorum sets the number of oracles that must submit identical results
+// before they are considered final
+func (v *State) SetResultsQuorum(quorum uint32) error {
+	if quorum == 0 {
+		return fmt.Errorf("results quorum cannot be zero")
+	}
+	quorumBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(quorumBytes, quorum)
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(AppTree).Add(resultsQuorumKey, quorumBytes)
+}
+
+// ResultsQuorum returns the number of oracles that must submit identical results
+// before they are considered final. It defaults to 1.
+func (v *State) ResultsQuorum(isQuery bool) uint32 {
+	var quorumBytes []byte
+	v.RLock()
+	if isQuery {
+		quorumBytes = v.Store.ImmutableTree(AppTree).Get(resultsQuorumKey)
+	} else {
+		quorumBytes = v.Store.Tree(AppTree).Get(resultsQuorumKey)
+	}
+	v.RUnlock()
+	if len(quorumBytes) != 4 {
+		return 1
+	}
+	return binary.BigEndian.Uint32(quorumBytes)
+}
+
+// ProcessResultsSubmissions returns the results submitted by the oracles for a process
+func (v *State) ProcessResultsSubmissions(pid []byte, isQuery bool) ([]*ResultsSubmission, error) {
+	prefix := append(append([]byte{}, resultsSubmissionPrefix...), pid...)
+	var submissions []*ResultsSubmission
+	var err error
+	fn := func(key, value []byte) bool {
+		s := &ResultsSubmission{
+			Oracle:  common.BytesToAddress(key[len(prefix):]),
+			Results: new(models.ProcessResult),
+		}
+		if err = proto.Unmarshal(value, s.Results); err != nil {
+			return true
+		}
+		if s.Hash, err = ResultsHash(s.Results); err != nil {
+			return true
+		}
+		submissions = append(submissions, s)
+		return false
+	}
+	v.RLock()
+	if isQuery {
+		v.Store.ImmutableTree(AppTree).Iterate(prefix, fn)
+	} else {
+		v.Store.Tree(AppTree).Iterate(prefix, fn)
+	}
+	v.RUnlock()
+	return submissions, err
+}
+
+// ProcessResultsEvidence returns the conflicting results submissions recorded for a process
+func (v *State) ProcessResultsEvidence(pid []byte, isQuery bool) ([]*ResultsEvidence, error) {
+	key := append(append([]byte{}, resultsEvidencePrefix...), pid...)
+	var evidenceBytes []byte
+	v.RLock()
+	if isQuery {
+		evidenceBytes = v.Store.ImmutableTree(AppTree).Get(key)
+	} else {
+		evidenceBytes = v.Store.Tree(AppTree).Get(key)
+	}
+	v.RUnlock()
+	if len(evidenceBytes) == 0 {
+		return nil, nil
+	}
+	var evidence []*ResultsEvidence
+	if err := json.Unmarshal(evidenceBytes, &evidence); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal results evidence: %w", err)
+	}
+	return evidence, nil
+}
+
+// addResultsSubmission stores the results submitted by an oracle, records the
+// conflicts with the previous submissions and returns the number of current
+// oracles which submitted the same results.
+func (v *State) addResultsSubmission(pid []byte, oracle common.Address,
+	result *models.ProcessResult, hash []byte) (uint32, error) {
+	submissions, err := v.ProcessResultsSubmissions(pid, false)
+	if err != nil {
+		return 0, err
+	}
+	oracles, err := v.Oracles(false)
+	if err != nil {
+		return 0, err
+	}
+	header := v.Header(false)
+	if header == nil {
+		return 0, fmt.Errorf("cannot get state header")
+	}
+	evidence, err := v.ProcessResultsEvidence(pid, false)
+	if err != nil {
+		return 0, err
+	}
+	conflicts := len(evidence)
+	agreements := uint32(1)
+	for _, s := range submissions {
+		if !bytes.Equal(s.Hash, hash) {
+			evidence = append(evidence, &ResultsEvidence{
+				ProcessID:              pid,
+				Height:                 header.Height,
+				Oracle:                 oracle,
+				ResultsHash:            hash,
+				ConflictingOracle:      s.Oracle,
+				ConflictingResultsHash: s.Hash,
+			})
+			continue
+		}
+		// submissions of removed oracles do not count
+		for _, o := range oracles {
+			if o == s.Oracle {
+				agreements++
+				break
+			}
+		}
+	}
+
+	resultBytes, err := proto.MarshalOptions{Deterministic: true}.Marshal(result)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal results: %w", err)
+	}
+	v.Lock()
+	defer v.Unlock()
+	if err := v.Store.Tree(AppTree).Add(resultsSubmissionKey(pid, oracle), resultBytes); err != nil {
+		return 0, err
+	}
+	if len(evidence) > conflicts {
+		evidenceBytes, err := json.Marshal(evidence)
+		if err != nil {
+			return 0, fmt.Errorf("cannot marshal results evidence: %w", err)
+		}
+		key := append(append([]byte{}, resultsEvidencePrefix...), pid...)
+		if err := v.Store.Tree(AppTree).Add(key, evidenceBytes); err != nil {
+			return 0, err
+		}
+	}
+	return agreements, nil
+}
+
+func resultsSubmissionKey(pid []byte, oracle common.Address) []byte {
+	key := append(append([]byte{}, resultsSubmissionPrefix...), pid...)
+	return append(key, oracle.Bytes()...)
+}
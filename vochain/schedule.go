@@ -0,0 +1,115 @@
+package vochain
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/statedb"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// processEndPrefix+height (uint64 big endian) stores, on the process tree, the
+// list of processes (sorted by id) to be ended at the height. A process accepts
+// votes up to its StartBlock+BlockCount height, so it is scheduled for the next one.
+var processEndPrefix = []byte("processEnd/")
+
+func processEndKey(height uint64) []byte {
+	key := make([]byte, len(processEndPrefix)+8)
+	copy(key, processEndPrefix)
+	binary.BigEndian.PutUint64(key[len(processEndPrefix):], height)
+	return key
+}
+
+// isProcessEndKey returns true if the process tree key is a schedule entry and not a process
+func isProcessEndKey(key []byte) bool {
+	return len(key) == len(processEndPrefix)+8 && bytes.HasPrefix(key, processEndPrefix)
+}
+
+// processEndHeight returns the height at which the process must be ended
+func processEndHeight(p *models.Process) uint64 {
+	return uint64(p.StartBlock) + uint64(p.BlockCount) + 1
+}
+
+// scheduleProcessEnd adds the process to the list of processes ending at its
+// end height. It must be called with the state locked.
+func (v *State) scheduleProcessEnd(p *models.Process) error {
+	if p.BlockCount == 0 {
+		return nil
+	}
+	key := processEndKey(processEndHeight(p))
+	pids, err := processEndList(v.Store.Tree(ProcessTree), key)
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(pids.ProcessList), func(i int) bool {
+		return bytes.Compare(pids.ProcessList[i], p.ProcessId) >= 0
+	})
+	if i < len(pids.ProcessList) && bytes.Equal(pids.ProcessList[i], p.ProcessId) {
+		return nil
+	}
+	pids.ProcessList = append(pids.ProcessList, nil)
+	copy(pids.ProcessList[i+1:], pids.ProcessList[i:])
+	pids.ProcessList[i] = p.ProcessId
+	pidsBytes, err := proto.Marshal(pids)
+	if err != nil {
+		return fmt.Errorf("cannot marshal process end list: %w", err)
+	}
+	return v.Store.Tree(ProcessTree).Add(key, pidsBytes)
+}
+
+func processEndList(tree statedb.StateTree, key []byte) (*models.ProcessEndingList, error) {
+	pids := new(models.ProcessEndingList)
+	if err := proto.Unmarshal(tree.Get(key), pids); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal process end list: %w", err)
+	}
+	return pids, nil
+}
+
+// ScheduledProcessEnds returns the ids of the processes to be ended at height
+func (v *State) ScheduledProcessEnds(height int64, isQuery bool) ([][]byte, error) {
+	v.RLock()
+	defer v.RUnlock()
+	tree := v.Store.Tree(ProcessTree)
+	if isQuery {
+		tree = v.Store.ImmutableTree(ProcessTree)
+	}
+	pids, err := processEndList(tree, processEndKey(uint64(height)))
+	if err != nil {
+		return nil, err
+	}
+	return pids.ProcessList, nil
+}
+
+// EndProcesses ends the processes scheduled for height which are still ready,
+// in the order of their ids, and removes the schedule entry. Processes which
+// already changed their status are skipped.
+func (v *State) EndProcesses(height int64) error {
+	pids, err := v.ScheduledProcessEnds(height, false)
+	if err != nil {
+		return err
+	}
+	if len(pids) == 0 {
+		return nil
+	}
+	for _, pid := range pids {
+		p, err := v.Process(pid, false)
+		if err != nil {
+			return fmt.Errorf("cannot get scheduled process %x: %w", pid, err)
+		}
+		if p.Status != models.ProcessStatus_READY || processEndHeight(p) != uint64(height) {
+			continue
+		}
+		if err := v.SetProcessStatus(pid, models.ProcessStatus_ENDED, true); err != nil {
+			log.Warnf("cannot end process %x: (%s)", pid, err)
+			continue
+		}
+		log.Infof("process %x ended at block %d", pid, height)
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Tree(ProcessTree).Delete(processEndKey(uint64(height)))
+}
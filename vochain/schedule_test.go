@@ -0,0 +1,125 @@
+package vochain
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// statusListener records the process status changes
+type statusListener struct {
+	ended [][]byte
+}
+
+func (l *statusListener) OnVote(v *models.Vote)                                                {}
+func (l *statusListener) OnVoteOverwrite(v, previous *models.Vote)                             {}
+func (l *statusListener) OnProcess(pid, eid []byte, censusRoot, censusURI string)              {}
+func (l *statusListener) OnCancel(pid []byte)                                                  {}
+func (l *statusListener) OnProcessKeys(pid []byte, pub, com string)                            {}
+func (l *statusListener) OnRevealKeys(pid []byte, priv, rev string)                            {}
+func (l *statusListener) OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32) {}
+func (l *statusListener) Commit(height int64)                                                  {}
+func (l *statusListener) Rollback()                                                            {}
+func (l *statusListener) OnProcessStatusChange(pid []byte, status models.ProcessStatus) {
+	if status == models.ProcessStatus_ENDED {
+		l.ended = append(l.ended, pid)
+	}
+}
+
+func TestEndProcesses(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := &statusListener{}
+	app.State.AddEventListener(listener)
+
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	// three processes accepting votes up to block 3 (ended at block 4), one up
+	// to block 5 and one which is paused before its end block
+	ending := [][]byte{
+		testAddProcess(t, app, testNewProcess(2, 1)),
+		testAddProcess(t, app, testNewProcess(1, 2)),
+		testAddProcess(t, app, testNewProcess(2, 1)),
+	}
+	later := testAddProcess(t, app, testNewProcess(2, 3))
+	paused := testAddProcess(t, app, testNewProcess(2, 1))
+	app.EndBlock(abcitypes.RequestEndBlock{Height: 1})
+	app.Commit()
+
+	if count := app.State.CountProcesses(true); count != 5 {
+		t.Errorf("got %d processes, expected 5", count)
+	}
+	scheduled, err := app.State.ScheduledProcessEnds(4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 4 {
+		t.Fatalf("got %d processes scheduled for block 4, expected 4", len(scheduled))
+	}
+
+	for h := int64(2); h <= 6; h++ {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: h}})
+		if h == 2 {
+			process, err := app.State.Process(paused, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			process.Status = models.ProcessStatus_PAUSED
+			if err := app.State.setProcess(process, paused); err != nil {
+				t.Fatal(err)
+			}
+		}
+		app.EndBlock(abcitypes.RequestEndBlock{Height: h})
+		app.Commit()
+
+		switch h {
+		case 3:
+			if len(listener.ended) != 0 {
+				t.Fatalf("processes ended before their end block")
+			}
+		case 4:
+			// ended in the order of their ids
+			sort.Slice(ending, func(i, j int) bool { return bytes.Compare(ending[i], ending[j]) < 0 })
+			if len(listener.ended) != len(ending) {
+				t.Fatalf("got %d ended processes at block 4, expected %d", len(listener.ended), len(ending))
+			}
+			for i, pid := range ending {
+				if !bytes.Equal(listener.ended[i], pid) {
+					t.Errorf("process %x ended in position %d", pid, i)
+				}
+			}
+		}
+	}
+
+	for _, pid := range append(ending, later) {
+		process, err := app.State.Process(pid, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if process.Status != models.ProcessStatus_ENDED {
+			t.Errorf("process %x has status %s, expected ended", pid, process.Status)
+		}
+	}
+	process, err := app.State.Process(paused, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if process.Status != models.ProcessStatus_PAUSED {
+		t.Errorf("paused process has status %s", process.Status)
+	}
+	if len(listener.ended) != len(ending)+1 {
+		t.Errorf("got %d ended processes, expected %d", len(listener.ended), len(ending)+1)
+	}
+	// the executed schedule entries are removed
+	if scheduled, err := app.State.ScheduledProcessEnds(4, true); err != nil || len(scheduled) != 0 {
+		t.Errorf("got %d processes scheduled for an executed block (%v)", len(scheduled), err)
+	}
+	if count := app.State.CountProcesses(true); count != 5 {
+		t.Errorf("got %d processes, expected 5", count)
+	}
+}
@@ -0,0 +1,276 @@
+package scrutinizer
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/proto/build/go/models"
+)
+
+// CountingMode is the scheme used to tally the votes of a process. It is
+// chosen from the process EnvelopeType and VoteOptions (see ProcessCountingMode).
+type CountingMode int
+
+const (
+	// CountingSingleChoice votes contain the selected option of each question.
+	// Results contain the weight of each option per question.
+	CountingSingleChoice CountingMode = iota
+	// CountingApproval votes contain 1 for each approved option and 0 otherwise.
+	// Results contain a single question with the approvals of each option.
+	CountingApproval
+	// CountingRankedChoice votes contain the options sorted by preference.
+	// Final results contain one question per instant-runoff round with the
+	// tally of each option, the winner is the option with majority on the last
+	// round. Live results contain one question per preference position.
+	CountingRankedChoice
+	// CountingQuadratic votes contain the number of votes for each option, the
+	// sum of their squares cannot exceed the voter credit budget (MaxTotalCost).
+	// Results contain two questions: the votes and the credits spent per option.
+	CountingQuadratic
+	// CountingCostCapped votes contain the points given to each option, the sum
+	// of the points raised to CostExponent cannot exceed MaxTotalCost.
+	// Results contain a single question with the points of each option.
+	CountingCostCapped
+)
+
+// String returns the name of the counting mode
+func (m CountingMode) String() string {
+	switch m {
+	case CountingSingleChoice:
+		return "single-choice"
+	case CountingApproval:
+		return "approval"
+	case CountingRankedChoice:
+		return "ranked-choice"
+	case CountingQuadratic:
+		return "quadratic"
+	case CountingCostCapped:
+		return "cost-capped"
+	}
+	return "unknown"
+}
+
+// ProcessCountingMode returns the counting mode of a process. Processes without
+// VoteOptions.MaxCount use single choice. Otherwise UniqueValues selects ranked
+// choice, MaxTotalCost selects quadratic (if CostExponent is 2) or cost capped,
+// and MaxValue=1 selects approval. Other combinations fall back to single choice.
+func ProcessCountingMode(p *models.Process) CountingMode {
+	opts := p.GetVoteOptions()
+	switch {
+	case opts == nil || opts.MaxCount == 0:
+		return CountingSingleChoice
+	case p.GetEnvelopeType().GetUniqueValues():
+		return CountingRankedChoice
+	case opts.MaxTotalCost > 0 && opts.CostExponent == 2:
+		return CountingQuadratic
+	case opts.MaxTotalCost > 0:
+		return CountingCostCapped
+	case opts.MaxValue == 1:
+		return CountingApproval
+	}
+	return CountingSingleChoice
+}
+
+// validateVote checks the vote package values are valid for the process counting mode
+func validateVote(p *models.Process, mode CountingMode, votes []int) error {
+	if mode == CountingSingleChoice {
+		if len(votes) > MaxQuestions {
+			return fmt.Errorf("too many questions (%d)", len(votes))
+		}
+		for q, opt := range votes {
+			if opt < 0 || opt >= MaxOptions {
+				return fmt.Errorf("option %d overflow on question %d", opt, q)
+			}
+		}
+		return nil
+	}
+	opts := p.GetVoteOptions()
+	if len(votes) > int(opts.MaxCount) || len(votes) > MaxOptions {
+		return fmt.Errorf("too many values (%d), max count is %d", len(votes), opts.MaxCount)
+	}
+	for i, v := range votes {
+		if v < 0 || (opts.MaxValue > 0 && v > int(opts.MaxValue)) {
+			return fmt.Errorf("value %d at position %d out of range", v, i)
+		}
+	}
+	switch mode {
+	case CountingApproval:
+		// MaxValue=1 is already checked
+	case CountingRankedChoice:
+		seen := make(map[int]bool, len(votes))
+		for _, v := range votes {
+			if v >= MaxOptions {
+				return fmt.Errorf("option %d overflow", v)
+			}
+			if seen[v] {
+				return fmt.Errorf("option %d ranked twice", v)
+			}
+			seen[v] = true
+		}
+	case CountingQuadratic, CountingCostCapped:
+		exp := opts.CostExponent
+		if exp == 0 {
+			exp = 1
+		}
+		cost := uint64(0)
+		for _, v := range votes {
+			cost += valueCost(uint64(v), exp, uint64(opts.MaxTotalCost))
+			if cost > uint64(opts.MaxTotalCost) {
+				return fmt.Errorf("vote cost exceeds the maximum %d", opts.MaxTotalCost)
+			}
+		}
+	}
+	return nil
+}
+
+// valueCost returns value^exp, or max+1 if it is bigger than max
+func valueCost(value uint64, exp uint32, max uint64) uint64 {
+	if value <= 1 {
+		return value
+	}
+	if value > max {
+		return max + 1
+	}
+	cost := uint64(1)
+	for i := uint32(0); i < exp; i++ {
+		cost *= value
+		if cost > max {
+			return max + 1
+		}
+	}
+	return cost
+}
+
+// voteWeight returns the weight of a vote, an empty weight counts as 1
+func voteWeight(v *models.Vote) *big.Int {
+	if len(v.GetWeight()) == 0 {
+		return big.NewInt(1)
+	}
+	return new(big.Int).SetBytes(v.GetWeight())
+}
+
+// addVote validates a vote and adds it to the current results using the
+// process counting mode. A negative weight subtracts a previously added vote.
+// The single vote of a serial process is added to the question it targets.
+func addVote(p *models.Process, currentResults []*models.QuestionResult, vote *types.VotePackage, iweight *big.Int) error {
+	mode := ProcessCountingMode(p)
+	voteValues := vote.Votes
+	if err := validateVote(p, mode, voteValues); err != nil {
+		return fmt.Errorf("invalid %s vote: %w", mode, err)
+	}
+	question := 0
+	if p.EnvelopeType.GetSerial() {
+		if mode != CountingSingleChoice || len(voteValues) != 1 {
+			return fmt.Errorf("serial votes must be single choice with one option")
+		}
+		if vote.QuestionIndex == nil || *vote.QuestionIndex >= MaxQuestions {
+			return fmt.Errorf("invalid serial vote question index")
+		}
+		question = int(*vote.QuestionIndex)
+	}
+	// the results are stored as unsigned big endian bytes, so the new values
+	// are checked before updating them
+	type position struct{ q, opt int }
+	values := make(map[position]*big.Int)
+	add := func(q, opt int, amount *big.Int) {
+		pos := position{q, opt}
+		if values[pos] == nil {
+			values[pos] = new(big.Int).SetBytes(currentResults[q].Question[opt])
+		}
+		values[pos].Add(values[pos], amount)
+	}
+	for i, v := range voteValues {
+		switch mode {
+		case CountingSingleChoice:
+			add(question+i, v, iweight)
+		case CountingApproval:
+			if v == 1 {
+				add(0, i, iweight)
+			}
+		case CountingRankedChoice:
+			// live results: weight of each option per preference position
+			add(i, v, iweight)
+		case CountingQuadratic:
+			value := big.NewInt(int64(v))
+			add(0, i, new(big.Int).Mul(value, iweight))
+			add(1, i, new(big.Int).Mul(value.Mul(value, value), iweight))
+		case CountingCostCapped:
+			add(0, i, new(big.Int).Mul(big.NewInt(int64(v)), iweight))
+		}
+	}
+	for pos, value := range values {
+		if value.Sign() < 0 {
+			return fmt.Errorf("negative result %s for option %d on question %d", value, pos.opt, pos.q)
+		}
+	}
+	for pos, value := range values {
+		currentResults[pos.q].Question[pos.opt] = value.Bytes()
+	}
+	return nil
+}
+
+// rankedBallot is a ranked choice vote and its weight
+type rankedBallot struct {
+	ranking []int
+	weight  *big.Int
+}
+
+// instantRunoff computes the rounds of an instant-runoff tally, one question per
+// round (as returned by emptyProcess, so it can be pruned). On each round
+// every ballot counts for its most preferred option not yet eliminated. The
+// tally stops when an option has more than half of the counted weight,
+// otherwise the option with less weight is eliminated (ties eliminate the
+// highest option index).
+func instantRunoff(ballots []*rankedBallot) *models.ProcessResult {
+	candidates := make(map[int]bool)
+	for _, b := range ballots {
+		for _, opt := range b.ranking {
+			candidates[opt] = true
+		}
+	}
+	eliminated := make(map[int]bool)
+	result := emptyProcess(0, 0)
+	for r := 0; r < MaxQuestions; r++ {
+		tally := make([]*big.Int, MaxOptions)
+		for i := range tally {
+			tally[i] = new(big.Int)
+		}
+		total := new(big.Int)
+		for _, b := range ballots {
+			for _, opt := range b.ranking {
+				if !eliminated[opt] {
+					tally[opt].Add(tally[opt], b.weight)
+					total.Add(total, b.weight)
+					break
+				}
+			}
+		}
+		for i := range tally {
+			result.Votes[r].Question[i] = tally[i].Bytes()
+		}
+
+		// remaining candidates sorted by weight, the last one is the loser
+		var remaining []int
+		for c := range candidates {
+			if !eliminated[c] {
+				remaining = append(remaining, c)
+			}
+		}
+		if len(remaining) <= 1 {
+			break
+		}
+		sort.Slice(remaining, func(i, j int) bool {
+			if cmp := tally[remaining[i]].Cmp(tally[remaining[j]]); cmp != 0 {
+				return cmp > 0
+			}
+			return remaining[i] < remaining[j]
+		})
+		if new(big.Int).Lsh(tally[remaining[0]], 1).Cmp(total) > 0 {
+			break
+		}
+		eliminated[remaining[len(remaining)-1]] = true
+	}
+	return result
+}
@@ -182,6 +182,10 @@ func (s *Scrutinizer) Encode(t string, data []byte) []byte {
 		return append([]byte{types.ScrutinizerResultsPrefix}, data...)
 	case "processEnding":
 		return append([]byte{types.ScrutinizerProcessEndingPrefix}, data...)
+	case "stats":
+		return append([]byte{types.ScrutinizerStatsPrefix}, data...)
+	case "blockVotes":
+		return append([]byte{types.ScrutinizerBlockVotesPrefix}, data...)
 	}
 	panic("scrutinizer encode type not known")
 }
@@ -1,12 +1,14 @@
 package scrutinizer
 
 /*
-	Scrutinizer keeps 4 diferent database entries (splited by key prefix)
+	Scrutinizer keeps 6 diferent database entries (splited by key prefix)
 
 	+ ProcessEnding: key is block number. Used for schedule results computing
 	+ LiveProcess: key is processId. Temporary storage for live results (poll-vote)
 	+ Entity: key is entityId: List of known entities
 	+ Results: key is processId: Final results for a process
+	+ Stats: key is processId: Vote statistics of a process
+	+ BlockVotes: key is processId and block number: New envelopes of a process on a block
 */
 
 import (
@@ -40,11 +42,19 @@ func (s *Scrutinizer) AddEventListener(l EventListener) {
 	s.eventListeners = append(s.eventListeners, l)
 }
 
+// pendingVote is a vote pending to be added to the process statistics and, if
+// live is true, to the live results. Previous is the vote it overwrites (if any).
+type pendingVote struct {
+	vote     *models.Vote
+	previous *models.Vote
+	live     bool
+}
+
 // Scrutinizer is the component which makes the accounting of the voting processes and keeps it indexed in a local database
 type Scrutinizer struct {
 	VochainState   *vochain.State
 	Storage        db.Database
-	votePool       []*models.Vote
+	votePool       []*pendingVote
 	processPool    []*types.ScrutinizerOnProcessData
 	resultsPool    []*types.ScrutinizerOnProcessData
 	entityCount    int64
@@ -90,9 +100,17 @@ func (s *Scrutinizer) Commit(height int64) {
 		s.registerPendingProcess(p.ProcessID, height+int64(i+1))
 	}
 
+	// Update the process statistics with the votes collected by onVote
+	if err = s.updateProcessStats(s.votePool, height); err != nil {
+		log.Errorf("cannot update process statistics: (%s)", err)
+	}
+
 	// Add votes collected by onVote (live results)
 	for _, v := range s.votePool {
-		if err = s.addLiveResultsVote(v); err != nil {
+		if !v.live {
+			continue
+		}
+		if err = s.addLiveResultsVote(v.vote, v.previous); err != nil {
 			log.Errorf("cannot add live vote: (%s)", err)
 			continue
 		}
@@ -105,7 +123,7 @@ func (s *Scrutinizer) Commit(height int64) {
 
 //Rollback removes the non commited pending operations
 func (s *Scrutinizer) Rollback() {
-	s.votePool = []*models.Vote{}
+	s.votePool = []*pendingVote{}
 	s.processPool = []*types.ScrutinizerOnProcessData{}
 	s.resultsPool = []*types.ScrutinizerOnProcessData{}
 }
@@ -116,16 +134,22 @@ func (s *Scrutinizer) OnProcess(pid, eid []byte, censusRoot, censusURI string) {
 	s.processPool = append(s.processPool, data)
 }
 
-// OnVote scrutinizer stores the votes if liveResults enabled
+// OnVote scrutinizer stores the votes for the statistics and the live results
 func (s *Scrutinizer) OnVote(v *models.Vote) {
+	s.addPendingVote(v, nil)
+}
+
+// OnVoteOverwrite scrutinizer stores the new vote and the overwritten one
+func (s *Scrutinizer) OnVoteOverwrite(v, previous *models.Vote) {
+	s.addPendingVote(v, previous)
+}
+
+func (s *Scrutinizer) addPendingVote(v, previous *models.Vote) {
 	isLive, err := s.isLiveResultsProcess(v.ProcessId)
 	if err != nil {
 		log.Errorf("cannot check if process is live results: (%s)", err)
-		return
-	}
-	if isLive {
-		s.votePool = append(s.votePool, v)
 	}
+	s.votePool = append(s.votePool, &pendingVote{vote: v, previous: previous, live: isLive})
 }
 
 // OnCancel scrutinizer stores the processID and entityID
@@ -138,7 +162,25 @@ func (s *Scrutinizer) OnProcessKeys(pid []byte, pub, com string) {
 	// do nothing
 }
 
+// OnProcessStatusChange adds the ended processes without encrypted votes to the
+// results queue, the encrypted ones are added once their keys are revealed
 func (s *Scrutinizer) OnProcessStatusChange(pid []byte, status models.ProcessStatus) {
+	if status != models.ProcessStatus_ENDED {
+		return
+	}
+	p, err := s.VochainState.Process(pid, false)
+	if err != nil {
+		log.Errorf("cannot fetch process %x from state: (%s)", pid, err)
+		return
+	}
+	if p.EnvelopeType != nil && p.EnvelopeType.EncryptedVotes {
+		return
+	}
+	s.resultsPool = append(s.resultsPool, &types.ScrutinizerOnProcessData{EntityID: p.EntityId, ProcessID: pid})
+}
+
+// OnTransaction does nothing
+func (s *Scrutinizer) OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32) {
 	// do nothing
 }
 
@@ -253,7 +253,7 @@ func TestLiveResults(t *testing.T) {
 	}
 	v := &models.Vote{ProcessId: pid, VotePackage: vp}
 	for i := 0; i < 100; i++ {
-		if err := sc.addLiveResultsVote(v); err != nil {
+		if err := sc.addLiveResultsVote(v, nil); err != nil {
 			t.Fatal(err)
 		}
 	}
@@ -280,9 +280,333 @@ func TestLiveResults(t *testing.T) {
 			if qi == 0 && value.Cmp(v0) != 0 {
 				t.Fatalf("result is not correct, %d is not 0 as expected", value.Uint64())
 			}
-			if qi == 1 && value.Cmp(v100) != 100 {
+			if qi == 1 && value.Cmp(v100) != 0 {
 				t.Fatalf("result is not correct, %d is not 100 as expected", value.Uint64())
 			}
 		}
 	}
 }
+
+func TestLiveResultsVoteOverwrite(t *testing.T) {
+	log.Init("info", "stdout")
+	state, err := vochain.NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewScrutinizer(t.TempDir(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(32)
+	state.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxVoteOverwrites: 1},
+	})
+	sc.addLiveResultsProcess(pid)
+
+	newVote := func(votes []int, weight int64) *models.Vote {
+		vp, err := json.Marshal(types.VotePackage{Votes: votes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &models.Vote{ProcessId: pid, VotePackage: vp, Weight: big.NewInt(weight).Bytes()}
+	}
+	first := newVote([]int{0, 1}, 5)
+	if err := sc.addLiveResultsVote(first, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := sc.addLiveResultsVote(newVote([]int{1, 1}, 2), nil); err != nil {
+		t.Fatal(err)
+	}
+	// the first voter changes the vote of the first question
+	if err := sc.addLiveResultsVote(newVote([]int{2, 1}, 5), first); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sc.VoteResult(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]int64{{0, 2, 5}, {0, 7}}
+	for q, options := range expected {
+		for opt, e := range options {
+			if v := new(big.Int).SetBytes(result.Votes[q].Question[opt]); v.Int64() != e {
+				t.Fatalf("question %d option %d: got %s, expected %d", q, opt, v, e)
+			}
+		}
+	}
+}
+
+func TestCountingModes(t *testing.T) {
+	log.Init("info", "stdout")
+	type ballot struct {
+		votes  []int
+		weight int64
+	}
+	for _, tc := range []struct {
+		name    string
+		mode    CountingMode
+		process *models.Process
+		ballots []ballot
+		results [][]string
+	}{
+		{
+			name: "approval",
+			mode: CountingApproval,
+			process: &models.Process{
+				EnvelopeType: &models.EnvelopeType{},
+				VoteOptions:  &models.ProcessVoteOptions{MaxCount: 4, MaxValue: 1},
+			},
+			ballots: []ballot{
+				{[]int{1, 0, 1, 1}, 1}, {[]int{1, 0, 1, 1}, 1}, {[]int{0, 1, 1, 0}, 3},
+				{[]int{2, 0, 0, 0}, 1}, {[]int{1, 1, 1, 1, 1}, 1}, // invalid
+			},
+			results: [][]string{{"2", "3", "5", "2"}},
+		},
+		{
+			name: "rankedChoice",
+			mode: CountingRankedChoice,
+			process: &models.Process{
+				EnvelopeType: &models.EnvelopeType{UniqueValues: true},
+				VoteOptions:  &models.ProcessVoteOptions{MaxCount: 3, MaxValue: 2},
+			},
+			ballots: []ballot{
+				{[]int{0, 1, 2}, 4}, {[]int{1, 2, 0}, 1}, {[]int{1, 2, 0}, 2}, {[]int{2, 1, 0}, 2},
+				{[]int{0, 0, 1}, 1}, {[]int{3, 1, 0}, 1}, // invalid
+			},
+			// the third option is eliminated on the first round and its votes go to the second one
+			results: [][]string{{"4", "3", "2"}, {"4", "5"}},
+		},
+		{
+			name: "quadratic",
+			mode: CountingQuadratic,
+			process: &models.Process{
+				EnvelopeType: &models.EnvelopeType{},
+				VoteOptions:  &models.ProcessVoteOptions{MaxCount: 3, MaxTotalCost: 10, CostExponent: 2},
+			},
+			ballots: []ballot{
+				{[]int{3, 1, 0}, 1}, {[]int{1, 1, 2}, 2},
+				{[]int{3, 1, 1}, 1}, // invalid, costs 11 credits
+			},
+			results: [][]string{{"5", "3", "4"}, {"11", "3", "8"}},
+		},
+		{
+			name: "costCapped",
+			mode: CountingCostCapped,
+			process: &models.Process{
+				EnvelopeType: &models.EnvelopeType{},
+				VoteOptions:  &models.ProcessVoteOptions{MaxCount: 3, MaxTotalCost: 5, CostExponent: 1},
+			},
+			ballots: []ballot{
+				{[]int{2, 3, 0}, 1}, {[]int{5, 0, 0}, 1},
+				{[]int{3, 3, 0}, 1}, // invalid
+			},
+			results: [][]string{{"7", "3"}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			state, err := vochain.NewState(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			sc, err := NewScrutinizer(t.TempDir(), state)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pid := util.RandomBytes(32)
+			tc.process.ProcessId = pid
+			if err := state.AddProcess(tc.process); err != nil {
+				t.Fatal(err)
+			}
+			if mode := ProcessCountingMode(tc.process); mode != tc.mode {
+				t.Fatalf("counting mode is %s, expected %s", mode, tc.mode)
+			}
+			sc.addLiveResultsProcess(pid)
+			for _, b := range tc.ballots {
+				vp, err := json.Marshal(types.VotePackage{Votes: b.votes})
+				if err != nil {
+					t.Fatal(err)
+				}
+				v := &models.Vote{
+					ProcessId:   pid,
+					VotePackage: vp,
+					Nullifier:   util.RandomBytes(32),
+					Weight:      big.NewInt(b.weight).Bytes(),
+				}
+				if err := state.AddVote(v); err != nil {
+					t.Fatal(err)
+				}
+				// invalid votes are not counted
+				sc.addLiveResultsVote(v, nil)
+			}
+			if err := sc.ComputeResult(pid); err != nil {
+				t.Fatal(err)
+			}
+			result, err := sc.VoteResult(pid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sc.GetFriendlyResults(result); fmt.Sprint(got) != fmt.Sprint(tc.results) {
+				t.Errorf("wrong results %v, expected %v", got, tc.results)
+			}
+		})
+	}
+}
+
+func TestProcessSummary(t *testing.T) {
+	log.Init("info", "stdout")
+	state, err := vochain.NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewScrutinizer(t.TempDir(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(32)
+	if err := state.AddProcess(&models.Process{
+		ProcessId:    pid,
+		EnvelopeType: &models.EnvelopeType{EncryptedVotes: true},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	vote := func(nullifier []byte, weight int64) *models.Vote {
+		return &models.Vote{ProcessId: pid, Nullifier: nullifier, Weight: big.NewInt(weight).Bytes()}
+	}
+
+	// block 10: 3 votes, block 12: 1 vote and an overwrite, block 13: an overwrite
+	nullifiers := [][]byte{util.RandomBytes(32), util.RandomBytes(32), util.RandomBytes(32), util.RandomBytes(32)}
+	for i := 0; i < 3; i++ {
+		sc.OnVote(vote(nullifiers[i], 10))
+	}
+	sc.Commit(10)
+	sc.Rollback()
+	sc.OnVote(vote(nullifiers[3], 10))
+	sc.OnVoteOverwrite(vote(nullifiers[0], 5), vote(nullifiers[0], 10))
+	sc.Commit(12)
+	sc.Rollback()
+	sc.OnVoteOverwrite(vote(nullifiers[1], 15), vote(nullifiers[1], 10))
+	sc.Commit(13)
+	sc.Rollback()
+	// a rolled back vote is not counted
+	sc.OnVote(vote(util.RandomBytes(32), 10))
+	sc.Rollback()
+
+	summary, err := sc.ProcessSummary(pid, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.EnvelopeCount != 4 || summary.TotalWeight != "40" ||
+		summary.FirstVoteHeight != 10 || summary.LastVoteHeight != 12 {
+		t.Fatalf("unexpected process summary %+v", summary)
+	}
+	expected := []types.BlockVotes{{Height: 10, Votes: 3}, {Height: 12, Votes: 1}}
+	if fmt.Sprint(summary.VotesPerBlock) != fmt.Sprint(expected) {
+		t.Fatalf("got votes per block %v, expected %v", summary.VotesPerBlock, expected)
+	}
+
+	// pagination of the votes per block
+	if summary, err = sc.ProcessSummary(pid, 11, 10); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(summary.VotesPerBlock) != fmt.Sprint(expected[1:]) {
+		t.Fatalf("got votes per block %v, expected %v", summary.VotesPerBlock, expected[1:])
+	}
+	if summary, err = sc.ProcessSummary(pid, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(summary.VotesPerBlock) != fmt.Sprint(expected[:1]) {
+		t.Fatalf("got votes per block %v, expected %v", summary.VotesPerBlock, expected[:1])
+	}
+}
+
+func TestSerialResults(t *testing.T) {
+	log.Init("info", "stdout")
+	state, err := vochain.NewState(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := NewScrutinizer(t.TempDir(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(32)
+	questionCount := uint32(3)
+	if err := state.AddProcess(&models.Process{
+		ProcessId:     pid,
+		EnvelopeType:  &models.EnvelopeType{Serial: true},
+		VoteOptions:   &models.ProcessVoteOptions{MaxCount: 1, MaxValue: 2},
+		QuestionCount: &questionCount,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	sc.addLiveResultsProcess(pid)
+	// each vote is added to the question it targets
+	for _, b := range []struct {
+		question uint32
+		votes    []int
+	}{
+		{0, []int{1}}, {0, []int{1}}, {0, []int{2}}, {1, []int{0}}, {2, []int{2}},
+		{1, []int{0, 1}}, // invalid, a single option per vote
+	} {
+		question := b.question
+		vp, err := json.Marshal(types.VotePackage{QuestionIndex: &question, Votes: b.votes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := &models.Vote{ProcessId: pid, VotePackage: vp, Nullifier: util.RandomBytes(32)}
+		if err := state.AddVote(v); err != nil {
+			t.Fatal(err)
+		}
+		sc.addLiveResultsVote(v, nil)
+	}
+	expected := [][]string{{"0", "2", "1"}, {"1"}, {"0", "0", "1"}}
+	for _, live := range []bool{true, false} {
+		var result *models.ProcessResult
+		if live {
+			result, err = sc.computeLiveResults(pid)
+		} else {
+			p, err := state.Process(pid, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err = sc.computeNonLiveResults(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sc.GetFriendlyResults(result); fmt.Sprint(got) != fmt.Sprint(expected) {
+			t.Errorf("wrong results %v, expected %v (live %v)", got, expected, live)
+		}
+	}
+}
+
+func TestAddVoteNegative(t *testing.T) {
+	process := &models.Process{
+		EnvelopeType: &models.EnvelopeType{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxCount: 2, MaxValue: 1},
+	}
+	results := emptyProcess(1, 2).Votes
+	if err := addVote(process, results, &types.VotePackage{Votes: []int{1, 1}}, big.NewInt(2)); err != nil {
+		t.Fatal(err)
+	}
+	// subtracting more than was added must fail without changing the results
+	if err := addVote(process, results, &types.VotePackage{Votes: []int{0, 1}}, big.NewInt(-3)); err == nil {
+		t.Fatalf("a negative result must be rejected")
+	}
+	for opt, expected := range []int64{2, 2} {
+		if value := new(big.Int).SetBytes(results[0].Question[opt]); value.Int64() != expected {
+			t.Errorf("option %d is %s, expected %d", opt, value, expected)
+		}
+	}
+	if err := addVote(process, results, &types.VotePackage{Votes: []int{0, 1}}, big.NewInt(-2)); err != nil {
+		t.Fatal(err)
+	}
+	if value := new(big.Int).SetBytes(results[0].Question[1]); value.Sign() != 0 {
+		t.Errorf("option 1 is %s, expected 0", value)
+	}
+}
@@ -0,0 +1,129 @@
+package scrutinizer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/dgraph-io/badger/v2"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/types"
+)
+
+// processStats is the stored representation of the vote statistics of a process
+type processStats struct {
+	EnvelopeCount   uint64   `json:"envelopeCount"`
+	TotalWeight     *big.Int `json:"totalWeight"`
+	FirstVoteHeight uint32   `json:"firstVoteHeight"`
+	LastVoteHeight  uint32   `json:"lastVoteHeight"`
+}
+
+// ProcessSummary returns the vote statistics of a process. The votes per block
+// histogram starts at the block fromHeight and contains at most max blocks.
+func (s *Scrutinizer) ProcessSummary(pid []byte, fromHeight uint32, max int) (*types.ProcessSummary, error) {
+	if _, err := s.ProcessInfo(pid); err != nil {
+		return nil, fmt.Errorf("cannot get process %x: %w", pid, err)
+	}
+	stats, err := s.processStats(pid)
+	if err != nil {
+		return nil, err
+	}
+	summary := &types.ProcessSummary{
+		EnvelopeCount:   stats.EnvelopeCount,
+		TotalWeight:     stats.TotalWeight.String(),
+		FirstVoteHeight: stats.FirstVoteHeight,
+		LastVoteHeight:  stats.LastVoteHeight,
+	}
+
+	prefix := s.Encode("blockVotes", pid)
+	iter := s.Storage.NewIterator().(*db.BadgerIterator) // TODO(mvdan): don't type assert
+	defer iter.Release()
+	for iter.Iter.Seek(blockVotesKey(prefix, fromHeight)); iter.Iter.ValidForPrefix(prefix) && max > 0; iter.Iter.Next() {
+		key, value := iter.Key()[len(prefix):], iter.Value()
+		if len(key) != 4 || len(value) != 4 {
+			return nil, fmt.Errorf("malformed votes per block entry for process %x", pid)
+		}
+		summary.VotesPerBlock = append(summary.VotesPerBlock, types.BlockVotes{
+			Height: binary.BigEndian.Uint32(key),
+			Votes:  binary.BigEndian.Uint32(value),
+		})
+		max--
+	}
+	return summary, nil
+}
+
+// processStats returns the stored vote statistics of a process
+func (s *Scrutinizer) processStats(pid []byte) (*processStats, error) {
+	stats := &processStats{TotalWeight: new(big.Int)}
+	statsBytes, err := s.Storage.Get(s.Encode("stats", pid))
+	if err == badger.ErrKeyNotFound {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(statsBytes, stats); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal process stats: %w", err)
+	}
+	return stats, nil
+}
+
+// updateProcessStats adds the votes of a committed block to the statistics of
+// their processes. Overwritten votes only update the total weight.
+func (s *Scrutinizer) updateProcessStats(votes []*pendingVote, height int64) error {
+	if len(votes) == 0 {
+		return nil
+	}
+	stats := make(map[string]*processStats)
+	newVotes := make(map[string]uint32)
+	for _, v := range votes {
+		pid := string(v.vote.ProcessId)
+		if stats[pid] == nil {
+			ps, err := s.processStats(v.vote.ProcessId)
+			if err != nil {
+				return err
+			}
+			stats[pid] = ps
+		}
+		ps := stats[pid]
+		ps.TotalWeight.Add(ps.TotalWeight, voteWeight(v.vote))
+		if v.previous != nil {
+			ps.TotalWeight.Sub(ps.TotalWeight, voteWeight(v.previous))
+			continue
+		}
+		if ps.EnvelopeCount == 0 {
+			ps.FirstVoteHeight = uint32(height)
+		}
+		ps.EnvelopeCount++
+		ps.LastVoteHeight = uint32(height)
+		newVotes[pid]++
+	}
+
+	batch := s.Storage.NewBatch()
+	for pid, ps := range stats {
+		statsBytes, err := json.Marshal(ps)
+		if err != nil {
+			return fmt.Errorf("cannot marshal process stats: %w", err)
+		}
+		if err := batch.Put(s.Encode("stats", []byte(pid)), statsBytes); err != nil {
+			return err
+		}
+		if newVotes[pid] == 0 {
+			continue
+		}
+		count := make([]byte, 4)
+		binary.BigEndian.PutUint32(count, newVotes[pid])
+		if err := batch.Put(blockVotesKey(s.Encode("blockVotes", []byte(pid)), uint32(height)), count); err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
+
+func blockVotesKey(prefix []byte, height uint32) []byte {
+	key := make([]byte, len(prefix)+4)
+	copy(key, prefix)
+	binary.BigEndian.PutUint32(key[len(prefix):], height)
+	return key
+}
@@ -43,7 +43,13 @@ func (s *Scrutinizer) ComputeResult(processID []byte) error {
 	}
 	var pv *models.ProcessResult
 	if isLive {
-		if pv, err = s.computeLiveResults(processID); err != nil {
+		// Instant-runoff requires the whole ballots, not only the live counts
+		if ProcessCountingMode(p) == CountingRankedChoice {
+			pv, err = s.computeNonLiveResults(p)
+		} else {
+			pv, err = s.computeLiveResults(processID)
+		}
+		if err != nil {
 			return err
 		}
 		// Delete liveResults temporary storage
@@ -150,7 +156,9 @@ func unmarshalVote(votePackage []byte, keys []string) (*types.VotePackage, error
 	return &vote, nil
 }
 
-func (s *Scrutinizer) addLiveResultsVote(envelope *models.Vote) error {
+// addLiveResultsVote adds a vote to the live results of its process. If the
+// vote overwrites a previous one, the previous vote is subtracted first.
+func (s *Scrutinizer) addLiveResultsVote(envelope, previous *models.Vote) error {
 	if envelope.ProcessId == nil {
 		return fmt.Errorf("cannot find process for envelope")
 	}
@@ -161,6 +169,10 @@ func (s *Scrutinizer) addLiveResultsVote(envelope *models.Vote) error {
 	if len(vote.Votes) > MaxQuestions {
 		return fmt.Errorf("too many questions on addVote")
 	}
+	process, err := s.ProcessInfo(envelope.ProcessId)
+	if err != nil {
+		return fmt.Errorf("cannot get process %x: %w", envelope.ProcessId, err)
+	}
 	processBytes, err := s.Storage.Get(s.Encode("liveProcess", envelope.ProcessId))
 	if err != nil {
 		return fmt.Errorf("error adding vote to process %x, skipping addVote: (%s)", envelope.ProcessId, err)
@@ -170,7 +182,18 @@ func (s *Scrutinizer) addLiveResultsVote(envelope *models.Vote) error {
 	if err := proto.Unmarshal(processBytes, &pv); err != nil {
 		return fmt.Errorf("cannot unmarshal vote (%s)", err)
 	}
-	addVote(pv.Votes, vote.Votes, envelope.GetWeight())
+	if previous != nil {
+		// an invalid previous vote was never added, so there is nothing to subtract
+		if pvote, err := unmarshalVote(previous.VotePackage, []string{}); err != nil {
+			log.Warnf("cannot unmarshal overwritten vote %x: %s", previous.Nullifier, err)
+		} else if err := addVote(process, pv.Votes, pvote,
+			new(big.Int).Neg(voteWeight(previous))); err != nil {
+			log.Warnf("cannot subtract overwritten vote %x: %s", previous.Nullifier, err)
+		}
+	}
+	if err := addVote(process, pv.Votes, vote, voteWeight(envelope)); err != nil {
+		return err
+	}
 
 	processBytes, err = proto.Marshal(&pv)
 	if err != nil {
@@ -199,6 +222,8 @@ func (s *Scrutinizer) computeLiveResults(processID []byte) (*models.ProcessResul
 
 func (s *Scrutinizer) computeNonLiveResults(p *models.Process) (*models.ProcessResult, error) {
 	pv := emptyProcess(0, 0)
+	ranked := ProcessCountingMode(p) == CountingRankedChoice
+	var ballots []*rankedBallot
 	var nvotes int
 	for _, e := range s.VochainState.EnvelopeList(p.ProcessId, 0, 32<<18, false) { // 8.3M seems enough for now
 		vote, err := s.VochainState.Envelope(p.ProcessId, e, false)
@@ -233,25 +258,20 @@ func (s *Scrutinizer) computeNonLiveResults(p *models.Process) (*models.ProcessR
 			log.Warn(err)
 			continue
 		}
-		addVote(pv.Votes, vp.Votes, vote.GetWeight())
+		if err := addVote(p, pv.Votes, vp, voteWeight(vote)); err != nil {
+			log.Warnf("skipping vote %x: %s", vote.Nullifier, err)
+			continue
+		}
+		if ranked {
+			ballots = append(ballots, &rankedBallot{ranking: vp.Votes, weight: voteWeight(vote)})
+		}
 		nvotes++
 	}
 	log.Infof("computed results for process %x with %d votes", p.ProcessId, nvotes)
-	return pruneVoteResult(pv), nil
-}
-
-func addVote(currentResults []*models.QuestionResult, voteValues []int, weight []byte) {
-	value := new(big.Int)
-	iweight := new(big.Int)
-	for q, opt := range voteValues {
-		if opt > MaxOptions {
-			log.Warn("option overflow on computeResult, skipping vote...")
-			continue
-		}
-		value.SetBytes(currentResults[q].Question[opt])
-		value.Add(value, iweight.SetBytes(weight))
-		currentResults[q].Question[opt] = value.Bytes()
+	if ranked {
+		return pruneVoteResult(instantRunoff(ballots)), nil
 	}
+	return pruneVoteResult(pv), nil
 }
 
 // To-be-improved
@@ -0,0 +1,357 @@
+package vochain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/log"
+)
+
+const (
+	// snapshotFormat is the version of the snapshot serialization format
+	snapshotFormat = 1
+	// snapshotChunkSize is the size of the chunks served to other peers
+	snapshotChunkSize = 4 << 20
+)
+
+// snapshotInfo describes a stored snapshot. It is sent to other peers as
+// the snapshot metadata, so they can verify each chunk before applying it.
+type snapshotInfo struct {
+	Height      uint64             `json:"height"`
+	Hash        []byte             `json:"hash"`
+	ChunkHashes [][]byte           `json:"chunkHashes"`
+	Trees       []snapshotTreeInfo `json:"trees"`
+}
+
+// snapshotTreeInfo is the size of each exported tree inside the snapshot file
+type snapshotTreeInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// snapshotRestore keeps track of a snapshot being restored from other peers
+type snapshotRestore struct {
+	info    *snapshotInfo
+	appHash []byte
+	file    *os.File
+	next    uint32
+}
+
+// Snapshots takes periodic snapshots of the vochain state trees, serves them to
+// other peers and restores the state from a snapshot offered by Tendermint.
+type Snapshots struct {
+	dir        string
+	interval   int64
+	keepRecent int
+	state      *State
+	lock       sync.Mutex
+	restore    *snapshotRestore
+}
+
+// EnableSnapshots makes the application take a snapshot every interval blocks,
+// keeping the last keepRecent snapshots on dir. The snapshots can be listed and
+// fetched by other peers for state sync.
+func (app *BaseApplication) EnableSnapshots(dir string, interval, keepRecent int) error {
+	if interval <= 0 {
+		return fmt.Errorf("invalid snapshot interval %d", interval)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("cannot create snapshots directory: %w", err)
+	}
+	if keepRecent < 1 {
+		keepRecent = 1
+	}
+	app.snapshots = &Snapshots{
+		dir:        dir,
+		interval:   int64(interval),
+		keepRecent: keepRecent,
+		state:      app.State,
+	}
+	log.Infof("state snapshots enabled every %d blocks, keeping %d", interval, keepRecent)
+	return nil
+}
+
+func (s *Snapshots) path(height uint64, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.%s", height, ext))
+}
+
+// Take creates a new snapshot of the last commited state if height is a multiple
+// of the snapshot interval.
+func (s *Snapshots) Take(height int64) error {
+	if height <= 0 || height%s.interval != 0 {
+		return nil
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	info := &snapshotInfo{Height: uint64(height)}
+	fd, err := os.Create(s.path(info.Height, "snap"))
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	s.state.RLock()
+	for _, name := range stateTrees {
+		start, err := fd.Seek(0, io.SeekCurrent)
+		if err != nil {
+			s.state.RUnlock()
+			return err
+		}
+		if err := s.state.Store.Export(name, fd); err != nil {
+			s.state.RUnlock()
+			return fmt.Errorf("cannot export tree %s: %w", name, err)
+		}
+		end, err := fd.Seek(0, io.SeekCurrent)
+		if err != nil {
+			s.state.RUnlock()
+			return err
+		}
+		info.Trees = append(info.Trees, snapshotTreeInfo{Name: name, Size: end - start})
+	}
+	s.state.RUnlock()
+
+	// compute the chunk hashes and the hash of the whole snapshot
+	if _, err := fd.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	chunk := make([]byte, snapshotChunkSize)
+	for {
+		n, err := io.ReadFull(fd, chunk)
+		if n > 0 {
+			h := sha256.Sum256(chunk[:n])
+			info.ChunkHashes = append(info.ChunkHashes, h[:])
+			hash.Write(chunk[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	info.Hash = hash.Sum(nil)
+	infoBytes, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(s.path(info.Height, "json"), infoBytes, 0o640); err != nil {
+		return err
+	}
+	log.Infof("created state snapshot at height %d with %d chunks", height, len(info.ChunkHashes))
+	return s.prune()
+}
+
+// heights returns the heights of the stored snapshots, sorted from the oldest to the newest
+func (s *Snapshots) heights() ([]uint64, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	heights := []uint64{}
+	for _, f := range files {
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		h, err := strconv.ParseUint(strings.TrimSuffix(f.Name(), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		heights = append(heights, h)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// prune removes the oldest snapshots, keeping only the last keepRecent
+func (s *Snapshots) prune() error {
+	heights, err := s.heights()
+	if err != nil {
+		return err
+	}
+	for len(heights) > s.keepRecent {
+		os.Remove(s.path(heights[0], "json"))
+		os.Remove(s.path(heights[0], "snap"))
+		log.Debugf("removed state snapshot at height %d", heights[0])
+		heights = heights[1:]
+	}
+	return nil
+}
+
+func (s *Snapshots) info(height uint64) (*snapshotInfo, error) {
+	infoBytes, err := ioutil.ReadFile(s.path(height, "json"))
+	if err != nil {
+		return nil, err
+	}
+	info := &snapshotInfo{}
+	return info, json.Unmarshal(infoBytes, info)
+}
+
+// List returns the available snapshots in Tendermint format
+func (s *Snapshots) List() ([]*abcitypes.Snapshot, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	heights, err := s.heights()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []*abcitypes.Snapshot{}
+	for _, h := range heights {
+		info, err := s.info(h)
+		if err != nil {
+			log.Warnf("cannot read snapshot %d: %v", h, err)
+			continue
+		}
+		metadata, err := json.Marshal(info)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, &abcitypes.Snapshot{
+			Height:   info.Height,
+			Format:   snapshotFormat,
+			Chunks:   uint32(len(info.ChunkHashes)),
+			Hash:     info.Hash,
+			Metadata: metadata,
+		})
+	}
+	return snapshots, nil
+}
+
+// LoadChunk returns the chunk number index of the snapshot taken at height
+func (s *Snapshots) LoadChunk(height uint64, index uint32) ([]byte, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	fd, err := os.Open(s.path(height, "snap"))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	chunk := make([]byte, snapshotChunkSize)
+	n, err := fd.ReadAt(chunk, int64(index)*snapshotChunkSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("chunk %d not found for snapshot %d", index, height)
+	}
+	return chunk[:n], nil
+}
+
+// Offer starts the restore process of a snapshot offered by Tendermint.
+// appHash is the trusted application hash for the snapshot height.
+func (s *Snapshots) Offer(snapshot *abcitypes.Snapshot, appHash []byte) abcitypes.ResponseOfferSnapshot_Result {
+	if snapshot == nil {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	if snapshot.Format != snapshotFormat {
+		return abcitypes.ResponseOfferSnapshot_REJECT_FORMAT
+	}
+	info := &snapshotInfo{}
+	if err := json.Unmarshal(snapshot.Metadata, info); err != nil {
+		log.Warnf("cannot unmarshal snapshot metadata: %v", err)
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	if info.Height != snapshot.Height || uint32(len(info.ChunkHashes)) != snapshot.Chunks ||
+		!bytes.Equal(info.Hash, snapshot.Hash) {
+		return abcitypes.ResponseOfferSnapshot_REJECT
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.restore != nil {
+		s.restore.file.Close()
+		os.Remove(s.restore.file.Name())
+	}
+	fd, err := ioutil.TempFile(s.dir, "restore")
+	if err != nil {
+		log.Errorf("cannot create snapshot restore file: %v", err)
+		return abcitypes.ResponseOfferSnapshot_ABORT
+	}
+	s.restore = &snapshotRestore{info: info, appHash: appHash, file: fd}
+	log.Infof("accepted state snapshot at height %d with %d chunks", info.Height, len(info.ChunkHashes))
+	return abcitypes.ResponseOfferSnapshot_ACCEPT
+}
+
+// ApplyChunk adds a chunk to the snapshot being restored. Once the last chunk
+// is received, the state trees are imported and checked against the trusted
+// application hash.
+func (s *Snapshots) ApplyChunk(req abcitypes.RequestApplySnapshotChunk) abcitypes.ResponseApplySnapshotChunk {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	r := s.restore
+	if r == nil {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	if req.Index != r.next || int(req.Index) >= len(r.info.ChunkHashes) {
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result:        abcitypes.ResponseApplySnapshotChunk_RETRY,
+			RefetchChunks: []uint32{r.next},
+		}
+	}
+	if h := sha256.Sum256(req.Chunk); !bytes.Equal(h[:], r.info.ChunkHashes[req.Index]) {
+		log.Warnf("invalid snapshot chunk %d received from %s", req.Index, req.Sender)
+		return abcitypes.ResponseApplySnapshotChunk{
+			Result:        abcitypes.ResponseApplySnapshotChunk_RETRY,
+			RefetchChunks: []uint32{req.Index},
+			RejectSenders: []string{req.Sender},
+		}
+	}
+	if _, err := r.file.Write(req.Chunk); err != nil {
+		log.Errorf("cannot write snapshot chunk: %v", err)
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	r.next++
+	if int(r.next) < len(r.info.ChunkHashes) {
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT}
+	}
+
+	// last chunk, import the state trees
+	defer func() {
+		r.file.Close()
+		os.Remove(r.file.Name())
+		s.restore = nil
+	}()
+	if err := s.importTrees(r); err != nil {
+		log.Errorf("cannot restore state snapshot: %v", err)
+		return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ABORT}
+	}
+	log.Infof("state restored from snapshot at height %d, app hash %x", r.info.Height, r.appHash)
+	return abcitypes.ResponseApplySnapshotChunk{Result: abcitypes.ResponseApplySnapshotChunk_ACCEPT}
+}
+
+func (s *Snapshots) importTrees(r *snapshotRestore) error {
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r.file); err != nil {
+		return err
+	}
+	if !bytes.Equal(hash.Sum(nil), r.info.Hash) {
+		return fmt.Errorf("snapshot hash mismatch")
+	}
+	if _, err := r.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.state.Lock()
+	for _, t := range r.info.Trees {
+		if err := s.state.Store.Import(t.Name, io.LimitReader(r.file, t.Size)); err != nil {
+			s.state.Unlock()
+			return fmt.Errorf("cannot import tree %s: %w", t.Name, err)
+		}
+	}
+	s.state.Unlock()
+	if hash := s.state.WorkingHash(); len(r.appHash) > 0 && !bytes.Equal(hash, r.appHash) {
+		return fmt.Errorf("app hash mismatch, expected %x got %x", r.appHash, hash)
+	}
+	return nil
+}
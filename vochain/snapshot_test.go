@@ -0,0 +1,124 @@
+package vochain
+
+import (
+	"bytes"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.EnableSnapshots(t.TempDir(), 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create 4 blocks with processes and votes, a snapshot is taken every 2 blocks
+	var pids [][]byte
+	var appHash []byte
+	for h := int64(1); h <= 4; h++ {
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: h}})
+		for i := 0; i < 5; i++ {
+			pid := util.RandomBytes(types.ProcessIDsize)
+			pids = append(pids, pid)
+			if err := app.State.AddProcess(&models.Process{ProcessId: pid, EntityId: util.RandomBytes(types.EntityIDsize)}); err != nil {
+				t.Fatal(err)
+			}
+			for j := 0; j < 10; j++ {
+				if err := app.State.AddVote(&models.Vote{
+					ProcessId:   pid,
+					Nullifier:   util.RandomBytes(types.VoteNullifierSize),
+					VotePackage: util.RandomBytes(16),
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		appHash = app.Commit().Data
+	}
+
+	snapshots := app.ListSnapshots(abcitypes.RequestListSnapshots{}).Snapshots
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	snapshot := snapshots[len(snapshots)-1]
+	if snapshot.Height != 4 {
+		t.Fatalf("expected last snapshot at height 4, got %d", snapshot.Height)
+	}
+
+	// Restore a fresh application from the last snapshot
+	app2, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app2.EnableSnapshots(t.TempDir(), 2, 2); err != nil {
+		t.Fatal(err)
+	}
+	offer := app2.OfferSnapshot(abcitypes.RequestOfferSnapshot{Snapshot: snapshot, AppHash: appHash})
+	if offer.Result != abcitypes.ResponseOfferSnapshot_ACCEPT {
+		t.Fatalf("snapshot not accepted: %s", offer.Result)
+	}
+	for i := uint32(0); i < snapshot.Chunks; i++ {
+		chunk := app.LoadSnapshotChunk(abcitypes.RequestLoadSnapshotChunk{
+			Height: snapshot.Height,
+			Format: snapshot.Format,
+			Chunk:  i,
+		}).Chunk
+		if len(chunk) == 0 {
+			t.Fatalf("chunk %d is empty", i)
+		}
+		// a corrupted chunk must be refetched
+		bad := append([]byte{}, chunk...)
+		bad[0]++
+		resp := app2.ApplySnapshotChunk(abcitypes.RequestApplySnapshotChunk{Index: i, Chunk: bad, Sender: "bad"})
+		if resp.Result != abcitypes.ResponseApplySnapshotChunk_RETRY {
+			t.Fatalf("corrupted chunk %d not rejected: %s", i, resp.Result)
+		}
+		resp = app2.ApplySnapshotChunk(abcitypes.RequestApplySnapshotChunk{Index: i, Chunk: chunk})
+		if resp.Result != abcitypes.ResponseApplySnapshotChunk_ACCEPT {
+			t.Fatalf("chunk %d not accepted: %s", i, resp.Result)
+		}
+	}
+
+	// Check the restored state
+	info := app2.Info(abcitypes.RequestInfo{})
+	if info.LastBlockHeight != 4 {
+		t.Errorf("restored height is %d, expected 4", info.LastBlockHeight)
+	}
+	if !bytes.Equal(info.LastBlockAppHash, appHash) {
+		t.Errorf("restored app hash is %x, expected %x", info.LastBlockAppHash, appHash)
+	}
+	if n := app2.State.CountProcesses(true); n != int64(len(pids)) {
+		t.Errorf("restored %d processes, expected %d", n, len(pids))
+	}
+	for _, pid := range pids {
+		if _, err := app2.State.Process(pid, true); err != nil {
+			t.Fatal(err)
+		}
+		if votes := app2.State.CountVotes(pid, true); votes != 10 {
+			t.Errorf("restored %d votes for process %x, expected 10", votes, pid)
+		}
+	}
+
+	// The restored application must keep producing the same state than the original
+	for _, a := range []*BaseApplication{app, app2} {
+		a.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 5}})
+		if err := a.State.AddVote(&models.Vote{
+			ProcessId:   pids[0],
+			Nullifier:   make([]byte, types.VoteNullifierSize),
+			VotePackage: []byte("1"),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if h1, h2 := app.Commit().Data, app2.Commit().Data; !bytes.Equal(h1, h2) {
+		t.Errorf("app hash diverged after restore: %x != %x", h1, h2)
+	}
+}
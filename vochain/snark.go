@@ -0,0 +1,99 @@
+package vochain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/types"
+	models "go.vocdoni.io/proto/build/go/models"
+)
+
+// verificationKeysKey stores the list of zk-SNARK verification keys
+var verificationKeysKey = []byte("verificationKeys")
+
+// AddVerificationKey adds a zk-SNARK verification key to the state and returns its index
+func (v *State) AddVerificationKey(vk *snarks.VerificationKey) (uint32, error) {
+	v.Lock()
+	defer v.Unlock()
+	var vks []json.RawMessage
+	if vksBytes := v.Store.Tree(AppTree).Get(verificationKeysKey); len(vksBytes) > 0 {
+		if err := json.Unmarshal(vksBytes, &vks); err != nil {
+			return 0, fmt.Errorf("cannot unmarshal verification keys: %w", err)
+		}
+	}
+	vkBytes, err := json.Marshal(vk)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal verification key: %w", err)
+	}
+	vks = append(vks, vkBytes)
+	vksBytes, err := json.Marshal(vks)
+	if err != nil {
+		return 0, fmt.Errorf("cannot marshal verification keys: %w", err)
+	}
+	return uint32(len(vks) - 1), v.Store.Tree(AppTree).Add(verificationKeysKey, vksBytes)
+}
+
+// VerificationKeys returns the list of zk-SNARK verification keys
+func (v *State) VerificationKeys(isQuery bool) ([]*snarks.VerificationKey, error) {
+	var vksBytes []byte
+	v.RLock()
+	if isQuery {
+		vksBytes = v.Store.ImmutableTree(AppTree).Get(verificationKeysKey)
+	} else {
+		vksBytes = v.Store.Tree(AppTree).Get(verificationKeysKey)
+	}
+	v.RUnlock()
+	if len(vksBytes) == 0 {
+		return nil, nil
+	}
+	var vks []*snarks.VerificationKey
+	if err := json.Unmarshal(vksBytes, &vks); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal verification keys: %w", err)
+	}
+	return vks, nil
+}
+
+// SnarkVoteInputs returns the public inputs of the anonymous voting circuit:
+// the census root, the nullifier, the two 128 bits halves of the processId and
+// the Poseidon hash of the vote package. The census root and the nullifier are
+// Poseidon hashes, so they are encoded as little-endian field elements.
+func SnarkVoteInputs(censusRoot, nullifier, processID, votePackage []byte) []*big.Int {
+	return []*big.Int{
+		snarks.BytesToInput(censusRoot),
+		snarks.BytesToInput(nullifier),
+		new(big.Int).SetBytes(processID[:types.ProcessIDsize/2]),
+		new(big.Int).SetBytes(processID[types.ProcessIDsize/2:]),
+		snarks.BytesToInput(snarks.Poseidon.Hash(votePackage)),
+	}
+}
+
+// checkSnarkVote checks the Groth16 proof of an anonymous vote, against the
+// verification key with index vkIndex, proves the voter is part of the census
+// of the process and the nullifier and vote package are the ones of the vote.
+//
+// The anonymous votes cannot reach it yet: the protocol buffers release in use
+// has neither a zk-SNARK proof type for the vote envelope nor a process field
+// selecting its verification key, and both are required to verify a vote.
+func checkSnarkVote(state *State, process *models.Process, vkIndex uint32,
+	nullifier, votePackage []byte, proof *snarks.Proof) error {
+	if process.CensusOrigin != models.CensusOrigin_OFF_CHAIN_TREE {
+		return fmt.Errorf("census origin not compatible with anonymous voting")
+	}
+	if len(nullifier) != types.VoteNullifierSize {
+		return fmt.Errorf("wrong nullifier size %d", len(nullifier))
+	}
+	vks, err := state.VerificationKeys(false)
+	if err != nil {
+		return err
+	}
+	if int(vkIndex) >= len(vks) {
+		return fmt.Errorf("verification key %d does not exist", vkIndex)
+	}
+	if err := snarks.Verify(vks[vkIndex], proof,
+		SnarkVoteInputs(process.CensusRoot, nullifier, process.ProcessId, votePackage)); err != nil {
+		return fmt.Errorf("%w: zk-SNARK proof not valid: %v", ErrInvalidProof, err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package vochain
+
+import (
+	"encoding/json"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/snarks"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// Anonymous vote fixture. The census root, nullifier, processId and vote
+// package are the public inputs the proof was generated for (see
+// SnarkVoteInputs), the fixture does not build the census tree. The
+// verification key and proof are precomputed with a throwaway setup.
+const (
+	snarkFixtureVK          = `{"protocol":"groth16","nPublic":5,"vk_alpha_1":["2517755449759024468595703676082930659346723032751838966699061344007324581010","21797036417240938311589182703725369182601349551228365638664505098312840596770","1"],"vk_beta_2":[["3425740665459755312660359968175341218275121085186437215564791085649138615885","13521369596868241138833647924601283292855819162790200562238303747260412449895"],["15683513602350877416778333247510770680266775016813051207519301780190245499434","3107534930001682268509203402383483278782369151053384645116720666111619174310"],["1","0"]],"vk_gamma_2":[["9332169361339083453367610941073009797270732327893435332156455324268653677909","561455328153982679330314774776402615120163328033472579711351033371262217208"],["553098390902748593796929880610775303236807840613941639613071628067763510165","5888405263226363110655287406622759833077203765063005595347010544041185594377"],["1","0"]],"vk_delta_2":[["4078691465411330821677071752297077199109668774848349041674474491937006622604","1821661208547607836039895350444139491004829681943253001769181738447346050435"],["16246508956130030388921684681683701004861288698511947682181771812554649674897","7947976559889159206324970932562979089920951629047131542206285045088975122665"],["1","0"]],"IC":[["6246543795532569319323487451602725352863945894152734227396191515678828528915","2947406700362252310722900249762654901342342035762556903053620854354568841879","1"],["21746635448476618040536958359387903477335819553707282260853428339187299074152","16312336548548831417611404177783161459973142288220903407628920387859734904397","1"],["15715468580661718711931647400722503658150685606047320703271291594422974824458","10564413658188047792962685230708418937179772704642372788467641426699901087652","1"],["21137178838856333193672001257975061759339460643797239838370125431243389391975","2653491391166159519888319830989072961998134356181405976952945246452051035279","1"],["16900013193540608834811334498580752933429357358854912701261301352898863805838","7213069251675124174806366029909286843571762016711020494253271028840162998015","1"],["19442738926570886418111683110523441833001234027991456016419891654594470399560","16519425930441114012642638201143191626005485421098975239451247641664671391776","1"]]}`
+	snarkFixtureProof       = `{"pi_a":["14991552497341759400638492160252297731228786890094142953821561220688326699306","8521585101464581633082033419568738189993400351481722511093092167460547255622","1"],"pi_b":[["19377700143204791362173479649480445333914687827282992687655017925657922343016","8088008884880182660826194304108410057880238393983321929142674420327384521361"],["4856070833611963408023288856820295194607708625200197323669226117616350343593","10071974076404074760132901205938577148942509296441928678456663443656672135951"],["1","0"]],"pi_c":["2669118968279433865952217550970148090250813522128770094428665017303593204667","5733297364021610637880712166104777317216553605930268702142761519102210598592","1"]}`
+	snarkFixtureProcessID   = "0dd21f0fb345b59b79c16aa397da0cf8b7b7c7eb834e28b828926bd5670aa0b7"
+	snarkFixtureCensusRoot  = "46651501b37f10e92ab1d0648503ce1856f01b10bb46461dc911405b3a14e521"
+	snarkFixtureNullifier   = "84af8697b9481def98d9af6518fc9c61c72e4a6f9a7f3556b77c869834830a0b"
+	snarkFixtureVotePackage = `{"nonce":"1","votes":[1]}`
+)
+
+func TestSnarkVote(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	// the verification keys are loaded from the genesis app state, the second
+	// one does not match the fixture proof
+	wrongVK := new(snarks.VerificationKey)
+	if err := json.Unmarshal([]byte(snarkFixtureVK), wrongVK); err != nil {
+		t.Fatal(err)
+	}
+	wrongVK.Alpha = wrongVK.IC[0]
+	wrongVKBytes, err := json.Marshal(wrongVK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis, err := json.Marshal(map[string]interface{}{
+		"oracles":           []string{oracle.Address().Hex()},
+		"verification_keys": []json.RawMessage{json.RawMessage(snarkFixtureVK), wrongVKBytes},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.InitChain(abcitypes.RequestInitChain{AppStateBytes: genesis})
+	vks, err := app.State.VerificationKeys(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vks) != 2 {
+		t.Fatalf("got %d verification keys, expected 2", len(vks))
+	}
+
+	pid := testutil.Hex2byte(t, snarkFixtureProcessID)
+	process := testNewProcess(3, 1024)
+	process.ProcessId = pid
+	process.EnvelopeType.Anonymous = true
+	process.CensusRoot = testutil.Hex2byte(t, snarkFixtureCensusRoot)
+	process.CensusOrigin = models.CensusOrigin_OFF_CHAIN_TREE
+	// the votes cannot carry their proof yet, so anonymous processes are not created
+	txBytes := testNewProcessTx(t, oracle, process)
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if r := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txBytes}); r.Code == 0 {
+		t.Fatal("anonymous process created")
+	}
+	app.Commit()
+
+	proof := new(snarks.Proof)
+	if err := json.Unmarshal([]byte(snarkFixtureProof), proof); err != nil {
+		t.Fatal(err)
+	}
+	nullifier := testutil.Hex2byte(t, snarkFixtureNullifier)
+	vp := []byte(snarkFixtureVotePackage)
+	if err := checkSnarkVote(app.State, process, 0, nullifier, vp, proof); err != nil {
+		t.Fatalf("valid vote rejected: %v", err)
+	}
+
+	// tampered public inputs or proofs, and other verification keys, must be rejected
+	caProcess := proto.Clone(process).(*models.Process)
+	caProcess.CensusOrigin = models.CensusOrigin_OFF_CHAIN_CA
+	for name, check := range map[string]func() error{
+		"nullifier": func() error {
+			return checkSnarkVote(app.State, process, 0, snarks.Poseidon.Hash([]byte("another voter")), vp, proof)
+		},
+		"nullifierSize": func() error {
+			return checkSnarkVote(app.State, process, 0, nullifier[1:], vp, proof)
+		},
+		"votePackage": func() error {
+			return checkSnarkVote(app.State, process, 0, nullifier, []byte(`{"nonce":"1","votes":[2]}`), proof)
+		},
+		"proof": func() error {
+			return checkSnarkVote(app.State, process, 0, nullifier, vp, &snarks.Proof{A: proof.C, B: proof.B, C: proof.A})
+		},
+		"verificationKey": func() error {
+			return checkSnarkVote(app.State, process, 1, nullifier, vp, proof)
+		},
+		"unknownVerificationKey": func() error {
+			return checkSnarkVote(app.State, process, 2, nullifier, vp, proof)
+		},
+		"censusOrigin": func() error {
+			return checkSnarkVote(app.State, caProcess, 0, nullifier, vp, proof)
+		},
+	} {
+		if err := check(); err == nil {
+			t.Errorf("vote with a wrong %s accepted", name)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"go.vocdoni.io/dvote/config"
+	"go.vocdoni.io/dvote/db"
 
 	tmcfg "github.com/tendermint/tendermint/config"
 	crypto25519 "github.com/tendermint/tendermint/crypto/ed25519"
@@ -27,10 +28,37 @@ import (
 // NewVochain starts a node with an ABCI application
 func NewVochain(vochaincfg *config.VochainCfg, genesis []byte) *BaseApplication {
 	// creating new vochain app
-	app, err := NewBaseApplication(vochaincfg.DataDir + "/data")
+	app, err := NewBaseApplicationWithBackend(vochaincfg.DataDir+"/data", vochaincfg.StateBackend)
 	if err != nil {
 		log.Fatalf("cannot init vochain application: %s", err)
 	}
+	if err := app.State.SetPruningPolicy(PruningPolicy{
+		Strategy:   vochaincfg.Pruning,
+		KeepRecent: vochaincfg.PruningKeepRecent,
+		KeepEvery:  vochaincfg.PruningKeepEvery,
+	}); err != nil {
+		log.Fatalf("cannot set state pruning policy: %s", err)
+	}
+	if err := app.State.SetRateLimits(RateLimits{
+		AddressTxs: vochaincfg.RateLimitAddress,
+		ProcessTxs: vochaincfg.RateLimitProcess,
+		Window:     vochaincfg.RateLimitWindow,
+	}); err != nil {
+		log.Fatalf("cannot set transaction rate limits: %s", err)
+	}
+	voteCacheDB, err := db.NewBadgerDB(vochaincfg.DataDir + "/votecache")
+	if err != nil {
+		log.Fatalf("cannot open vote cache database: %s", err)
+	}
+	if err := app.State.SetVoteCacheDB(voteCacheDB); err != nil {
+		log.Fatalf("cannot load vote cache: %s", err)
+	}
+	if vochaincfg.SnapshotInterval > 0 {
+		if err := app.EnableSnapshots(vochaincfg.DataDir+"/snapshots",
+			vochaincfg.SnapshotInterval, vochaincfg.SnapshotKeepRecent); err != nil {
+			log.Fatalf("cannot enable state snapshots: %s", err)
+		}
+	}
 	log.Info("creating tendermint node and application")
 	app.Node, err = newTendermint(app, vochaincfg, genesis)
 	if err != nil {
@@ -180,6 +208,15 @@ func newTendermint(app *BaseApplication, localConfig *config.VochainCfg, genesis
 	// indexing
 	tconfig.TxIndex.Indexer = "kv"
 
+	// state sync config
+	if localConfig.StateSync {
+		tconfig.StateSync.Enable = true
+		tconfig.StateSync.RPCServers = localConfig.StateSyncRPCServers
+		tconfig.StateSync.TrustHeight = localConfig.StateSyncTrustHeight
+		tconfig.StateSync.TrustHash = localConfig.StateSyncTrustHash
+		log.Infof("state sync enabled, trusted height %d", tconfig.StateSync.TrustHeight)
+	}
+
 	// mempool config
 	tconfig.Mempool.Size = localConfig.MempoolSize
 	tconfig.Mempool.Recheck = false
@@ -2,17 +2,25 @@ package vochain
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	abcitypes "github.com/tendermint/tendermint/abci/types"
 	tmcrypto "github.com/tendermint/tendermint/crypto"
 	ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	"go.vocdoni.io/dvote/db"
 	"go.vocdoni.io/dvote/log"
 	"go.vocdoni.io/dvote/statedb"
+	"go.vocdoni.io/dvote/statedb/gravitonstate"
 	"go.vocdoni.io/dvote/statedb/iavlstate"
 	"go.vocdoni.io/dvote/types"
 	models "go.vocdoni.io/proto/build/go/models"
@@ -27,15 +35,27 @@ const (
 	voteCachePurgeThreshold = time.Minute * 10
 )
 
+// stateTrees is the list of state trees, sorted by name as they are hashed
+// to compute the app hash
+var stateTrees = []string{AppTree, ProcessTree, VoteTree}
+
 var (
 	// keys; not constants because of []byte
 	headerKey    = []byte("header")
 	oracleKey    = []byte("oracle")
 	validatorKey = []byte("validator")
+	// voteOverwritesPrefix+processId+nullifier stores the number of times a vote has been overwritten
+	voteOverwritesPrefix = []byte("voteOverwrites/")
 )
 
-var (
-	ErrProcessNotFound = fmt.Errorf("process not found")
+const (
+	// StateBackendIAVL stores the state trees using IAVL (the default backend)
+	StateBackendIAVL = "iavl"
+	// StateBackendGraviton stores the state trees using Graviton
+	StateBackendGraviton = "graviton"
+
+	// stateBackendFile is the file of the state data directory which records its backend
+	stateBackendFile = "backend"
 )
 
 // PrefixDBCacheSize is the size of the cache for the MutableTree IAVL databases
@@ -43,16 +63,22 @@ var PrefixDBCacheSize = 0
 
 // EventListener is an interface used for executing custom functions during the
 // events of the block creation process.
-// The order in which events are executed is: Rollback, OnVote or OnProcess, Commit.
+// The order in which events are executed is: Rollback, OnVote, OnVoteOverwrite or OnProcess,
+// OnTransaction, Commit.
 // The process is concurrency safe, meaning that there cannot be two sequences
 // happening in parallel.
 type EventListener interface {
 	OnVote(*models.Vote)
+	OnVoteOverwrite(vote, previous *models.Vote)
 	OnProcess(pid, eid []byte, censusRoot, censusURI string)
 	OnProcessStatusChange(pid []byte, status models.ProcessStatus)
 	OnCancel(pid []byte)
 	OnProcessKeys(pid []byte, encryptionPub, commitment string)
 	OnRevealKeys(pid []byte, encryptionPriv, reveal string)
+	// OnTransaction is called for every delivered transaction with its position
+	// in the block and the ABCI result code. The transaction is nil if it cannot
+	// be decoded.
+	OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32)
 	Commit(height int64)
 	Rollback()
 }
@@ -62,9 +88,17 @@ type State struct {
 	Store         statedb.StateDB
 	voteCache     map[[32]byte]*types.CacheTx
 	voteCacheLock sync.RWMutex
+	voteCacheDB   db.Database
+	// voteCacheHits and voteCacheMisses are updated atomically
+	voteCacheHits   uint64
+	voteCacheMisses uint64
 	ImmutableState
 	MemPoolRemoveTxKey func([32]byte, bool)
 	eventListeners     []EventListener
+	pruning            *PruningPolicy
+	prunedVersions     uint64
+	prunedBytes        uint64
+	rateLimiter        *rateLimiter
 }
 
 // ImmutableState holds the latest trees version saved on disk
@@ -75,13 +109,24 @@ type ImmutableState struct {
 	sync.RWMutex
 }
 
-// NewState creates a new State
+// NewState creates a new State using the IAVL backend
 func NewState(dataDir string) (*State, error) {
+	return NewStateWithBackend(dataDir, StateBackendIAVL)
+}
+
+// NewStateWithBackend creates a new State using the given state database
+// backend. The app hash depends on the backend, so all the nodes of a network
+// must use the same one. An existing dataDir can only be opened with the
+// backend used to create it.
+func NewStateWithBackend(dataDir, backend string) (*State, error) {
 	var err error
 	vs := &State{}
-	//vs.Store = new(gravitonstate.GravitonState)
-	vs.Store = new(iavlstate.IavlState)
-
+	if vs.Store, err = NewStateDB(backend); err != nil {
+		return nil, err
+	}
+	if err = checkStateBackend(dataDir, backend); err != nil {
+		return nil, err
+	}
 	if err = vs.Store.Init(dataDir, "disk"); err != nil {
 		return nil, err
 	}
@@ -106,6 +151,64 @@ func NewState(dataDir string) (*State, error) {
 	return vs, nil
 }
 
+// Close closes the state database and the vote cache database, releasing
+// their locks. The state cannot be used afterwards.
+func (v *State) Close() error {
+	v.voteCacheLock.Lock()
+	defer v.voteCacheLock.Unlock()
+	if v.voteCacheDB != nil {
+		if err := v.voteCacheDB.Close(); err != nil {
+			return fmt.Errorf("cannot close vote cache database: %w", err)
+		}
+		v.voteCacheDB = nil
+	}
+	v.Lock()
+	defer v.Unlock()
+	return v.Store.Close()
+}
+
+// NewStateDB returns an uninitialized state database of the given backend
+func NewStateDB(backend string) (statedb.StateDB, error) {
+	switch backend {
+	case StateBackendIAVL, "":
+		return new(iavlstate.IavlState), nil
+	case StateBackendGraviton:
+		return new(gravitonstate.GravitonState), nil
+	}
+	return nil, fmt.Errorf("unknown state backend %q", backend)
+}
+
+// checkStateBackend records the backend of a new state data directory, or
+// checks it matches the recorded one. Directories created before the backend
+// was recorded use IAVL.
+func checkStateBackend(dataDir, backend string) error {
+	if backend == "" {
+		backend = StateBackendIAVL
+	}
+	backendFile := filepath.Join(dataDir, stateBackendFile)
+	current, err := ioutil.ReadFile(backendFile)
+	if err == nil {
+		if string(current) != backend {
+			return fmt.Errorf("state data directory %s uses the %s backend, not %s", dataDir, current, backend)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	files, err := ioutil.ReadDir(dataDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(files) > 0 && backend != StateBackendIAVL {
+		return fmt.Errorf("state data directory %s uses the %s backend, not %s", dataDir, StateBackendIAVL, backend)
+	}
+	if err := os.MkdirAll(dataDir, 0o750); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(backendFile, []byte(backend), 0o640)
+}
+
 // AddEventListener adds a new event listener, to receive method calls on block
 // events as documented in EventListener.
 func (v *State) AddEventListener(l EventListener) {
@@ -191,19 +294,19 @@ func (v *State) Oracles(isQuery bool) ([]common.Address, error) {
 }
 
 func hexPubKeyToTendermintEd25519(pubKey string) (tmcrypto.PubKey, error) {
-	var tmkey ed25519.PubKey
 	pubKeyBytes, err := hex.DecodeString(pubKey)
 	if err != nil {
 		return nil, err
 	}
-	if len(pubKeyBytes) != 32 {
+	if len(pubKeyBytes) != ed25519.PubKeySize {
 		return nil, fmt.Errorf("pubKey lenght is invalid")
 	}
-	copy(tmkey[:], pubKeyBytes[:])
+	tmkey := make(ed25519.PubKey, ed25519.PubKeySize)
+	copy(tmkey, pubKeyBytes)
 	return tmkey, nil
 }
 
-// AddValidator adds a tendemint validator if it is not already added
+// AddValidator adds a tendemint validator, or updates its power if it is already added
 func (v *State) AddValidator(validator *models.Validator) error {
 	var err error
 	v.Lock()
@@ -215,17 +318,24 @@ func (v *State) AddValidator(validator *models.Validator) error {
 			return err
 		}
 	}
+	found := false
 	for _, v := range validatorsList.Validators {
 		if bytes.Equal(v.Address, validator.Address) {
-			return nil
+			if v.Power == validator.GetPower() {
+				return nil
+			}
+			v.Power = validator.GetPower()
+			found = true
+			break
 		}
 	}
-	newVal := &models.Validator{
-		Address: validator.GetAddress(),
-		PubKey:  validator.GetPubKey(),
-		Power:   validator.GetPower(),
+	if !found {
+		validatorsList.Validators = append(validatorsList.Validators, &models.Validator{
+			Address: validator.GetAddress(),
+			PubKey:  validator.GetPubKey(),
+			Power:   validator.GetPower(),
+		})
 	}
-	validatorsList.Validators = append(validatorsList.Validators, newVal)
 	validatorsBytes, err = proto.Marshal(&validatorsList)
 	if err != nil {
 		return fmt.Errorf("cannot marshal validators: %v", err)
@@ -276,6 +386,44 @@ func (v *State) Validators(isQuery bool) ([]*models.Validator, error) {
 	return validators.Validators, err
 }
 
+// ValidatorUpdates returns the changes of the validator set made on the current
+// block (compared to the last commited state), sorted by address. Removed
+// validators have zero power.
+func (v *State) ValidatorUpdates() ([]abcitypes.ValidatorUpdate, error) {
+	committed, err := v.Validators(true)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get commited validators: %w", err)
+	}
+	current, err := v.Validators(false)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get validators: %w", err)
+	}
+	changed := make(map[string]*models.Validator)
+	for _, val := range current {
+		changed[string(val.Address)] = val
+	}
+	for _, val := range committed {
+		c, ok := changed[string(val.Address)]
+		switch {
+		case !ok:
+			changed[string(val.Address)] = &models.Validator{Address: val.Address, PubKey: val.PubKey}
+		case c.Power == val.Power:
+			delete(changed, string(val.Address))
+		}
+	}
+	updates := make([]abcitypes.ValidatorUpdate, 0, len(changed))
+	addresses := make([]string, 0, len(changed))
+	for address := range changed {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		val := changed[address]
+		updates = append(updates, abcitypes.Ed25519ValidatorUpdate(val.PubKey, int64(val.Power)))
+	}
+	return updates, nil
+}
+
 // AddProcessKeys adds the keys to the process
 func (v *State) AddProcessKeys(tx *models.AdminTx) error {
 	if tx.ProcessId == nil || tx.KeyIndex == nil {
@@ -340,12 +488,19 @@ func (v *State) RevealProcessKeys(tx *models.AdminTx) error {
 	return nil
 }
 
-// AddVote adds a new vote to a process if the process exists and the vote is not already submmited
+// AddVote adds a new vote to a process, or overwrites it if the nullifier already exists
 func (v *State) AddVote(vote *models.Vote) error {
 	vid, err := v.voteID(vote.ProcessId, vote.Nullifier)
 	if err != nil {
 		return err
 	}
+	// if the vote already exists, it is overwritten
+	var previous *models.Vote
+	if v.EnvelopeExists(vote.ProcessId, vote.Nullifier, false) {
+		if previous, err = v.Envelope(vote.ProcessId, vote.Nullifier, false); err != nil {
+			return err
+		}
+	}
 	// save block number
 	vote.Height = uint32(v.Header(false).Height)
 	newVoteBytes, err := proto.Marshal(vote)
@@ -354,16 +509,51 @@ func (v *State) AddVote(vote *models.Vote) error {
 	}
 	v.Lock()
 	err = v.Store.Tree(VoteTree).Add(vid, newVoteBytes)
+	if err == nil && previous != nil {
+		overwrites := make([]byte, 4)
+		binary.BigEndian.PutUint32(overwrites, v.voteOverwrites(vid, false)+1)
+		err = v.Store.Tree(AppTree).Add(append(append([]byte{}, voteOverwritesPrefix...), vid...), overwrites)
+	}
 	v.Unlock()
 	if err != nil {
 		return err
 	}
 	for _, l := range v.eventListeners {
-		l.OnVote(vote)
+		if previous != nil {
+			l.OnVoteOverwrite(vote, previous)
+		} else {
+			l.OnVote(vote)
+		}
 	}
 	return nil
 }
 
+// VoteOverwrites returns the number of times a vote has been overwritten
+func (v *State) VoteOverwrites(processID, nullifier []byte, isQuery bool) uint32 {
+	vid, err := v.voteID(processID, nullifier)
+	if err != nil {
+		return 0
+	}
+	v.RLock()
+	defer v.RUnlock()
+	return v.voteOverwrites(vid, isQuery)
+}
+
+// voteOverwrites must be called with the state lock held
+func (v *State) voteOverwrites(vid []byte, isQuery bool) uint32 {
+	key := append(append([]byte{}, voteOverwritesPrefix...), vid...)
+	var overwrites []byte
+	if isQuery {
+		overwrites = v.Store.ImmutableTree(AppTree).Get(key)
+	} else {
+		overwrites = v.Store.Tree(AppTree).Get(key)
+	}
+	if len(overwrites) != 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(overwrites)
+}
+
 // voteID = byte( processID+nullifier )
 func (v *State) voteID(pid, nullifier []byte) ([]byte, error) {
 	if len(pid) != types.ProcessIDsize {
@@ -515,6 +705,7 @@ func (v *State) Save() []byte {
 	if err != nil {
 		panic(fmt.Sprintf("cannot commit state trees: (%s)", err))
 	}
+	v.prune()
 	v.Unlock()
 	if h := v.Header(false); h != nil {
 		for _, l := range v.eventListeners {
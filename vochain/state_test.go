@@ -4,9 +4,16 @@ import (
 	"fmt"
 	"testing"
 
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	"go.vocdoni.io/dvote/censustree/iden3tree"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/crypto/snarks"
 	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/test/testcommon/testutil"
+	"go.vocdoni.io/dvote/types"
 	"go.vocdoni.io/dvote/util"
 	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
 )
 
 func TestState(t *testing.T) {
@@ -63,3 +70,96 @@ func TestState(t *testing.T) {
 	}
 
 }
+
+func TestVoteOverwrite(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := iden3tree.NewTree("testoverwrite", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := ethereum.NewSignKeys()
+	if err := k.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	pub, _ := k.HexString()
+	if pub, err = ethereum.DecompressPubKey(pub); err != nil {
+		t.Fatal(err)
+	}
+	digest := snarks.Poseidon.Hash(testutil.Hex2byte(t, pub))
+	if err := tr.Add(digest, nil); err != nil {
+		t.Fatal(err)
+	}
+	mproof, err := tr.GenProof(digest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid := util.RandomBytes(types.ProcessIDsize)
+	if err := app.State.AddProcess(&models.Process{
+		ProcessId:    pid,
+		StartBlock:   0,
+		EnvelopeType: &models.EnvelopeType{},
+		Mode:         &models.ProcessMode{},
+		VoteOptions:  &models.ProcessVoteOptions{MaxVoteOverwrites: 2},
+		Status:       models.ProcessStatus_READY,
+		EntityId:     util.RandomBytes(types.EntityIDsize),
+		CensusRoot:   tr.Root(),
+		CensusOrigin: models.CensusOrigin_OFF_CHAIN_TREE,
+		BlockCount:   1024,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := func(votePackage string) (uint32, []byte) {
+		tx := &models.VoteEnvelope{
+			Nonce:       util.RandomBytes(32),
+			ProcessId:   pid,
+			Proof:       &models.Proof{Payload: &models.Proof_Iden3{Iden3: &models.ProofIden3{Siblings: mproof}}},
+			VotePackage: []byte(votePackage),
+		}
+		txBytes, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtx := &models.Tx{Payload: &models.Tx_Vote{Vote: tx}}
+		if vtx.Signature, err = k.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		vtxBytes, err := proto.Marshal(vtx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := app.CheckTx(abcitypes.RequestCheckTx{Tx: vtxBytes}); r.Code != 0 {
+			return r.Code, r.Data
+		}
+		r := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: vtxBytes})
+		app.Commit()
+		return r.Code, r.Data
+	}
+
+	nullifier := GenerateNullifier(k.Address(), pid)
+	// the first vote and two overwrites are accepted
+	for i := 0; i < 3; i++ {
+		if code, data := vote(fmt.Sprintf("[%d]", i)); code != 0 {
+			t.Fatalf("vote %d failed: %s", i, data)
+		}
+		if overwrites := app.State.VoteOverwrites(pid, nullifier, true); overwrites != uint32(i) {
+			t.Fatalf("got %d overwrites, expected %d", overwrites, i)
+		}
+		v, err := app.State.Envelope(pid, nullifier, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v.VotePackage) != fmt.Sprintf("[%d]", i) {
+			t.Fatalf("vote package not overwritten: %s", v.VotePackage)
+		}
+	}
+	if code, _ := vote("[3]"); code == 0 {
+		t.Fatalf("vote overwritten beyond the max vote overwrites")
+	}
+	if votes := app.State.CountVotes(pid, true); votes != 1 {
+		t.Fatalf("got %d votes, expected 1", votes)
+	}
+}
@@ -1,11 +1,15 @@
 package vochain
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	tmtypes "github.com/tendermint/tendermint/types"
 	ethtoken "github.com/vocdoni/eth-storage-proof/token"
 	"go.vocdoni.io/dvote/crypto/ethereum"
 	"go.vocdoni.io/dvote/crypto/nacl"
@@ -28,14 +32,37 @@ func AddTx(vtx *models.Tx, state *State, txID [32]byte, commit bool) ([]byte, er
 			return []byte{}, fmt.Errorf("voteTxCheck %w", err)
 		}
 		if commit {
-			return v.Nullifier, state.AddVote(v)
+			if err := state.AddVote(v); err != nil {
+				return v.Nullifier, err
+			}
+			return v.Nullifier, state.checkPetitionThreshold(v.ProcessId)
 		}
 		return v.Nullifier, nil
 	case *models.Tx_Admin:
-		if err := AdminTxCheck(vtx, state); err != nil {
-			return []byte{}, fmt.Errorf("adminTxChek %w", err)
-		}
 		tx := vtx.GetAdmin()
+		addr, err := adminTxCheck(vtx, state)
+		if err != nil {
+			if !errors.Is(err, ErrKeyMismatch) {
+				return []byte{}, fmt.Errorf("adminTxChek %w", err)
+			}
+			// a well-formed reveal of a mismatched key is accepted on the mempool,
+			// so it reaches a block where it is rejected and the misbehavior recorded
+			if commit {
+				if err := recordKeyMismatch(state, tx, addr, err); err != nil {
+					log.Warnf("cannot record keykeeper evidence: %v", err)
+				}
+				return []byte{}, fmt.Errorf("adminTxChek %w", err)
+			}
+			if recorded, rerr := keyMismatchRecorded(state, tx, addr); rerr != nil || recorded {
+				return []byte{}, fmt.Errorf("adminTxChek %w", err)
+			}
+		}
+		if !commit {
+			if err := state.checkRateLimit(addr.Bytes(), tx.ProcessId); err != nil {
+				return []byte{}, fmt.Errorf("adminTxChek %w", err)
+			}
+			state.chargeRateLimit(addr.Bytes(), tx.ProcessId)
+		}
 		if commit {
 			switch tx.Txtype {
 			case models.TxType_ADD_ORACLE:
@@ -44,25 +71,34 @@ func AddTx(vtx *models.Tx, state *State, txID [32]byte, commit bool) ([]byte, er
 				return []byte{}, state.RemoveOracle(common.BytesToAddress(tx.Address))
 			case models.TxType_ADD_VALIDATOR:
 				pk, err := hexPubKeyToTendermintEd25519(fmt.Sprintf("%x", tx.PublicKey))
-				if err == nil {
-					if tx.Power == nil {
-						return []byte{}, fmt.Errorf("power not specified on add validator transaction")
-					}
-					validator := &models.Validator{
-						Address: pk.Address().Bytes(),
-						PubKey:  pk.Bytes(),
-						Power:   *tx.Power,
-					}
-					return []byte{}, state.AddValidator(validator)
-
+				if err != nil {
+					return []byte{}, fmt.Errorf("addValidator %w", err)
 				}
-				return []byte{}, fmt.Errorf("addValidator %w", err)
+				return []byte{}, state.AddValidator(&models.Validator{
+					Address: pk.Address().Bytes(),
+					PubKey:  pk.Bytes(),
+					Power:   tx.GetPower(),
+				})
 
 			case models.TxType_REMOVE_VALIDATOR:
 				return []byte{}, state.RemoveValidator(tx.Address)
 			case models.TxType_ADD_PROCESS_KEYS:
+				pubKey, err := signerPubKey(vtx)
+				if err != nil {
+					return []byte{}, fmt.Errorf("addProcessKeys %w", err)
+				}
+				if err := state.AddKeyKeeper(*tx.KeyIndex, pubKey); err != nil {
+					return []byte{}, fmt.Errorf("addProcessKeys %w", err)
+				}
 				return []byte{}, state.AddProcessKeys(tx)
 			case models.TxType_REVEAL_PROCESS_KEYS:
+				holder, _, err := keyShareHolder(state, tx, addr)
+				if err != nil {
+					return []byte{}, fmt.Errorf("revealProcessKeys %w", err)
+				}
+				if holder != 0 {
+					return []byte{}, state.RevealProcessKeyShare(tx, holder)
+				}
 				return []byte{}, state.RevealProcessKeys(tx)
 			}
 		}
@@ -81,7 +117,8 @@ func AddTx(vtx *models.Tx, state *State, txID [32]byte, commit bool) ([]byte, er
 		}
 
 	case *models.Tx_SetProcess:
-		if err := SetProcessTxCheck(vtx, state); err != nil {
+		oracle, err := SetProcessTxCheck(vtx, state)
+		if err != nil {
 			return []byte{}, fmt.Errorf("setProcess %w", err)
 		}
 		if commit {
@@ -96,12 +133,17 @@ func AddTx(vtx *models.Tx, state *State, txID [32]byte, commit bool) ([]byte, er
 				if tx.GetResults() == nil {
 					return []byte{}, fmt.Errorf("set process results, results is nil")
 				}
-				return []byte{}, state.SetProcessResults(tx.ProcessId, tx.Results, true)
+				return []byte{}, state.SetProcessResults(tx.ProcessId, tx.Results, oracle, true)
 			case models.TxType_SET_PROCESS_CENSUS:
 				if tx.GetCensusRoot() == nil {
 					return []byte{}, fmt.Errorf("set process census, census root is nil")
 				}
 				return []byte{}, state.SetProcessCensus(tx.ProcessId, tx.CensusRoot, tx.GetCensusURI(), true)
+			case models.TxType_SET_PROCESS_QUESTION_INDEX:
+				if tx.QuestionIndex == nil {
+					return []byte{}, fmt.Errorf("set process question index, question index is nil")
+				}
+				return []byte{}, state.SetProcessQuestionIndex(tx.ProcessId, *tx.QuestionIndex, true)
 			default:
 				return []byte{}, fmt.Errorf("unknown set process tx type")
 			}
@@ -116,7 +158,10 @@ func AddTx(vtx *models.Tx, state *State, txID [32]byte, commit bool) ([]byte, er
 // UnmarshalTx splits a tx into method and args parts and does some basic checks
 func UnmarshalTx(content []byte) (*models.Tx, error) {
 	vtx := models.Tx{}
-	return &vtx, proto.Unmarshal(content, &vtx)
+	if err := proto.Unmarshal(content, &vtx); err != nil {
+		return &vtx, fmt.Errorf("%w: %v", ErrTxMalformed, err)
+	}
+	return &vtx, nil
 }
 
 // VoteTxCheck is an abstraction of ABCI checkTx for submitting a vote
@@ -142,16 +187,23 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 		if process.EnvelopeType.EncryptedVotes && process.KeyIndex != nil && *process.KeyIndex < 1 {
 			return nil, fmt.Errorf("no keys available, voting is not possible")
 		}
+		// Serial processes only accept votes for the current question, it is
+		// checked on every delivery since the question might change meanwhile
+		if process.EnvelopeType.Serial {
+			if err := checkSerialVote(process, tx.VotePackage); err != nil {
+				return nil, err
+			}
+		}
 
 		switch {
 		case process.EnvelopeType.Anonymous:
-			// TODO check snark
+			// the vote envelope has no zk-SNARK proof type yet, see checkSnarkVote
 			return nil, fmt.Errorf("snark vote not implemented")
 		default: // Signature based voting
 			var vote models.Vote
 			vote.ProcessId = tx.ProcessId
 			if vtx.Signature == nil {
-				return nil, fmt.Errorf("signature missing on voteTx")
+				return nil, fmt.Errorf("%w: signature missing on voteTx", ErrInvalidSignature)
 			}
 			vote.VotePackage = tx.VotePackage
 			if process.EnvelopeType.EncryptedVotes {
@@ -170,16 +222,19 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 			if forCommit && vp != nil {
 				// if vote is in cache, lazy check and remove it from cache
 				defer state.CacheDel(txID)
-				if state.EnvelopeExists(vote.ProcessId, vp.Nullifier, false) {
-					return nil, fmt.Errorf("vote already exist")
+				if err := checkVoteOverwrite(state, process, vp.Nullifier); err != nil {
+					return nil, err
+				}
+				if err := checkVoteLimits(state, process, vp.Nullifier); err != nil {
+					return nil, err
 				}
 			} else {
 				if vp != nil {
-					return nil, fmt.Errorf("vote already exist in cache")
+					return nil, fmt.Errorf("%w: vote already exist in cache", ErrDuplicateTx)
 				}
 				// if not in cache, extract pubKey, generate nullifier and check census proof
 				if tx.Proof == nil {
-					return nil, fmt.Errorf("proof not found on transaction")
+					return nil, fmt.Errorf("%w: proof not found on transaction", ErrInvalidProof)
 				}
 
 				vp = new(types.CacheTx)
@@ -194,7 +249,7 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 				}
 				pubk, err := ethereum.PubKeyFromSignature(signedBytes, vtx.Signature)
 				if err != nil {
-					return nil, fmt.Errorf("cannot extract public key from signature: (%w)", err)
+					return nil, fmt.Errorf("%w: cannot extract public key from signature: (%v)", ErrInvalidSignature, err)
 				}
 				vp.PubKey, err = hex.DecodeString(pubk)
 				if err != nil {
@@ -205,13 +260,28 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 					return nil, fmt.Errorf("cannot extract address from public key: (%w)", err)
 				}
 
+				// the sender is known, so check the rate limits before the census proof, the
+				// vote is only charged once valid
+				if !forCommit {
+					if err := state.checkRateLimit(addr.Bytes(), vote.ProcessId); err != nil {
+						return nil, err
+					}
+				}
+
 				// assign a nullifier
-				vp.Nullifier = GenerateNullifier(addr, vote.ProcessId)
+				if process.EnvelopeType.Serial {
+					vp.Nullifier = GenerateQuestionNullifier(addr, vote.ProcessId, process.GetQuestionIndex())
+				} else {
+					vp.Nullifier = GenerateNullifier(addr, vote.ProcessId)
+				}
 				log.Debugf("new vote %x for address %s and process %x", vp.Nullifier, addr.Hex(), tx.ProcessId)
 
-				// check if vote exists
-				if state.EnvelopeExists(vote.ProcessId, vp.Nullifier, false) {
-					return nil, fmt.Errorf("vote %x already exists", vp.Nullifier)
+				// check if vote exists and can be overwritten
+				if err := checkVoteOverwrite(state, process, vp.Nullifier); err != nil {
+					return nil, err
+				}
+				if err := checkVoteLimits(state, process, vp.Nullifier); err != nil {
+					return nil, err
 				}
 
 				// check census origin and compute vote digest identifier
@@ -241,10 +311,13 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 				var valid bool
 				valid, vp.Weight, err = checkProof(tx.Proof, process.CensusOrigin, process.CensusRoot, process.ProcessId, vp.PubKeyDigest)
 				if err != nil {
-					return nil, fmt.Errorf("proof not valid: (%w)", err)
+					return nil, fmt.Errorf("%w: (%v)", ErrInvalidProof, err)
 				}
 				if !valid {
-					return nil, fmt.Errorf("proof not valid")
+					return nil, ErrInvalidProof
+				}
+				if !forCommit {
+					state.chargeRateLimit(addr.Bytes(), vote.ProcessId)
 				}
 				vp.Created = time.Now()
 				state.CacheAdd(txID, vp)
@@ -256,34 +329,83 @@ func VoteTxCheck(vtx *models.Tx, state *State, txID [32]byte, forCommit bool) (*
 			return &vote, nil
 		}
 	}
-	return nil, fmt.Errorf("cannot add vote, invalid block frame or process stop/paused/cancel")
+	if process.Status != models.ProcessStatus_READY {
+		return nil, fmt.Errorf("%w: process status is %s", ErrProcessNotActive, process.Status)
+	}
+	return nil, fmt.Errorf("%w: height %d is out of the process blocks %d to %d",
+		ErrInvalidBlockFrame, height, process.StartBlock, endBlock)
+}
+
+// checkSerialVote checks the vote package of a serial process targets its
+// current question with a single option
+func checkSerialVote(process *models.Process, votePackage []byte) error {
+	var vp types.VotePackage
+	if err := json.Unmarshal(votePackage, &vp); err != nil {
+		return fmt.Errorf("cannot unmarshal vote package: %w", err)
+	}
+	if vp.QuestionIndex == nil || *vp.QuestionIndex != process.GetQuestionIndex() {
+		return fmt.Errorf("%w: current question index is %d", ErrInvalidQuestion, process.GetQuestionIndex())
+	}
+	if len(vp.Votes) != 1 {
+		return fmt.Errorf("serial process votes must contain a single option, got %d", len(vp.Votes))
+	}
+	return nil
+}
+
+// checkVoteOverwrite returns an error if the vote identified by nullifier
+// already exists and the process does not allow to overwrite it again
+func checkVoteOverwrite(state *State, process *models.Process, nullifier []byte) error {
+	if !state.EnvelopeExists(process.ProcessId, nullifier, false) {
+		return nil
+	}
+	overwrites := state.VoteOverwrites(process.ProcessId, nullifier, false)
+	if overwrites >= process.GetVoteOptions().GetMaxVoteOverwrites() {
+		return fmt.Errorf("%w: vote %x cannot be overwritten (%d overwrites)",
+			ErrDuplicateNullifier, nullifier, overwrites)
+	}
+	return nil
 }
 
 // AdminTxCheck is an abstraction of ABCI checkTx for an admin transaction
 func AdminTxCheck(vtx *models.Tx, state *State) error {
+	_, err := adminTxCheck(vtx, state)
+	return err
+}
+
+// adminTxCheck checks an admin transaction and returns the oracle which signed it
+func adminTxCheck(vtx *models.Tx, state *State) (common.Address, error) {
 	tx := vtx.GetAdmin()
 	// check signature available
 	if vtx.Signature == nil || tx == nil {
-		return fmt.Errorf("missing signature or admin transaction")
+		return common.Address{}, fmt.Errorf("%w: missing signature or admin transaction", ErrInvalidSignature)
 	}
 	// get oracles
 	oracles, err := state.Oracles(false)
 	if err != nil || len(oracles) == 0 {
-		return fmt.Errorf("cannot check authorization against a nil or empty oracle list")
+		return common.Address{}, fmt.Errorf("cannot check authorization against a nil or empty oracle list")
 	}
 
 	signedBytes, err := proto.Marshal(tx)
 	if err != nil {
-		return fmt.Errorf("cannot marshal new process transaction")
+		return common.Address{}, fmt.Errorf("cannot marshal new process transaction")
 	}
 
-	if authorized, addr, err := verifySignatureAgainstOracles(oracles, signedBytes, vtx.Signature); err != nil {
-		return err
+	authorized, addr, err := verifySignatureAgainstOracles(oracles, signedBytes, vtx.Signature)
+	if err != nil {
+		return common.Address{}, err
 	} else if !authorized {
-		return fmt.Errorf("unauthorized to perform an adminTx, address: %s", addr.Hex())
+		return common.Address{}, fmt.Errorf("%w: unauthorized to perform an adminTx, address: %s", ErrUnauthorized, addr.Hex())
 	}
+	return addr, adminTxPayloadCheck(tx, state, addr)
+}
 
+// adminTxPayloadCheck checks the admin transaction fields, signed by addr, against the state
+func adminTxPayloadCheck(tx *models.AdminTx, state *State, addr common.Address) error {
 	switch tx.Txtype {
+	case models.TxType_ADD_VALIDATOR:
+		return checkAddValidator(tx, state)
+	case models.TxType_REMOVE_VALIDATOR:
+		return checkRemoveValidator(tx, state)
 	case models.TxType_ADD_PROCESS_KEYS, models.TxType_REVEAL_PROCESS_KEYS:
 		if tx.ProcessId == nil {
 			return fmt.Errorf("missing processId on AdminTxCheck")
@@ -294,7 +416,7 @@ func AdminTxCheck(vtx *models.Tx, state *State) error {
 			return err
 		}
 		if process == nil {
-			return fmt.Errorf("process with id (%x) does not exist", tx.ProcessId)
+			return fmt.Errorf("%w: process with id (%x) does not exist", ErrProcessNotFound, tx.ProcessId)
 		}
 		// check process actually requires keys
 		if !process.EnvelopeType.EncryptedVotes && !process.EnvelopeType.Anonymous {
@@ -326,6 +448,13 @@ func AdminTxCheck(vtx *models.Tx, state *State) error {
 			if err := checkAddProcessKeys(tx, process); err != nil {
 				return err
 			}
+			if err := checkKeyKeeperIndex(state, *tx.KeyIndex, addr); err != nil {
+				return err
+			}
+			// the key dealing has no field of its own yet, see KeyDealing
+			if tx.PublicKey != nil {
+				return fmt.Errorf("process keys do not carry a key dealing")
+			}
 		case models.TxType_REVEAL_PROCESS_KEYS:
 			if tx.KeyIndex == nil {
 				return fmt.Errorf("missing keyIndexon AdminTxCheck")
@@ -338,6 +467,19 @@ func AdminTxCheck(vtx *models.Tx, state *State) error {
 			if len(process.EncryptionPrivateKeys[*tx.KeyIndex])+len(process.RevealKeys[*tx.KeyIndex]) > 0 {
 				return fmt.Errorf("keys for process %x already revealed", tx.ProcessId)
 			}
+			// a keykeeper holding a share of the key reveals its share instead
+			holder, dealing, err := keyShareHolder(state, tx, addr)
+			if err != nil {
+				return err
+			}
+			if holder != 0 {
+				return checkKeyShare(state, tx, holder, dealing)
+			}
+			// only the keykeeper which published the keys reveals them, otherwise
+			// a mismatch would be recorded as the misbehavior of another keykeeper
+			if err := checkKeyKeeperIndex(state, *tx.KeyIndex, addr); err != nil {
+				return err
+			}
 			// check the keys are valid
 			if err := checkRevealProcessKeys(tx, process); err != nil {
 				return err
@@ -347,6 +489,50 @@ func AdminTxCheck(vtx *models.Tx, state *State) error {
 	return nil
 }
 
+// checkAddValidator checks the public key and power of a new validator (or of
+// an existing one whose power changes), the total power must remain valid for Tendermint
+func checkAddValidator(tx *models.AdminTx, state *State) error {
+	pk, err := hexPubKeyToTendermintEd25519(fmt.Sprintf("%x", tx.PublicKey))
+	if err != nil {
+		return fmt.Errorf("invalid validator public key: %w", err)
+	}
+	if tx.Power == nil || *tx.Power == 0 {
+		return fmt.Errorf("validator power must be greater than zero")
+	}
+	validators, err := state.Validators(false)
+	if err != nil {
+		return fmt.Errorf("cannot get validators: %w", err)
+	}
+	total := *tx.Power
+	for _, v := range validators {
+		if bytes.Equal(v.Address, pk.Address()) {
+			continue
+		}
+		total += v.Power
+	}
+	if *tx.Power > uint64(tmtypes.MaxTotalVotingPower) || total > uint64(tmtypes.MaxTotalVotingPower) {
+		return fmt.Errorf("validator power %d exceeds the maximum total power", *tx.Power)
+	}
+	return nil
+}
+
+// checkRemoveValidator checks the validator exists and it is not the last one
+func checkRemoveValidator(tx *models.AdminTx, state *State) error {
+	validators, err := state.Validators(false)
+	if err != nil {
+		return fmt.Errorf("cannot get validators: %w", err)
+	}
+	for _, v := range validators {
+		if bytes.Equal(v.Address, tx.Address) {
+			if len(validators) == 1 {
+				return fmt.Errorf("cannot remove the last validator")
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("validator %x not found", tx.Address)
+}
+
 func checkAddProcessKeys(tx *models.AdminTx, process *models.Process) error {
 	if tx.KeyIndex == nil {
 		return fmt.Errorf("key index is nil")
@@ -357,9 +543,23 @@ func checkAddProcessKeys(tx *models.AdminTx, process *models.Process) error {
 	}
 	// check if provided keyIndex is not already used
 	if len(process.EncryptionPublicKeys[*tx.KeyIndex]) > 0 || len(process.CommitmentKeys[*tx.KeyIndex]) > 0 {
-		return fmt.Errorf("key index %d alrady exist", tx.KeyIndex)
+		return fmt.Errorf("key index %d alrady exist", *tx.KeyIndex)
+	}
+	// check the process gets the keys it requires, so they can be checked on reveal
+	if process.EnvelopeType.EncryptedVotes && tx.EncryptionPublicKey == nil {
+		return fmt.Errorf("missing encryption key for a process with encrypted votes")
+	}
+	if process.EnvelopeType.Anonymous && tx.CommitmentKey == nil {
+		return fmt.Errorf("missing commitment key for an anonymous process")
+	}
+	if tx.EncryptionPublicKey != nil {
+		if _, err := nacl.DecodePublic(fmt.Sprintf("%x", tx.EncryptionPublicKey)); err != nil {
+			return fmt.Errorf("invalid encryption key: %w", err)
+		}
+	}
+	if tx.CommitmentKey != nil && len(tx.CommitmentKey) != types.CommitmentKeySize {
+		return fmt.Errorf("invalid commitment key size %d", len(tx.CommitmentKey))
 	}
-	// TBD check that provided keys are correct (ed25519 for encryption and size for Commitment)
 	return nil
 }
 
@@ -372,28 +572,36 @@ func checkRevealProcessKeys(tx *models.AdminTx, process *models.Process) error {
 		return fmt.Errorf("no keys provided or invalid key index")
 	}
 	// check if provided keyIndex exists
-	if len(process.EncryptionPublicKeys[*tx.KeyIndex]) < 1 || len(process.CommitmentKeys[*tx.KeyIndex]) < 1 {
+	if len(process.EncryptionPublicKeys[*tx.KeyIndex]) < 1 && len(process.CommitmentKeys[*tx.KeyIndex]) < 1 {
 		return fmt.Errorf("key index %d does not exist", *tx.KeyIndex)
 	}
+	// check all the published keys are revealed, and only them
+	if (tx.EncryptionPrivateKey != nil) != (len(process.EncryptionPublicKeys[*tx.KeyIndex]) > 0) {
+		return fmt.Errorf("the encryption key on index %d must be revealed if and only if it was published", *tx.KeyIndex)
+	}
+	if (tx.RevealKey != nil) != (len(process.CommitmentKeys[*tx.KeyIndex]) > 0) {
+		return fmt.Errorf("the reveal key on index %d must be revealed if and only if its commitment was published", *tx.KeyIndex)
+	}
 	// check keys actually work
 	if tx.EncryptionPrivateKey != nil {
-		if priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", tx.EncryptionPrivateKey)); err == nil {
-			pub := priv.Public().Bytes()
-			if fmt.Sprintf("%x", pub) != process.EncryptionPublicKeys[*tx.KeyIndex] {
-				log.Debugf("%x != %s", pub, process.EncryptionPublicKeys[*tx.KeyIndex])
-				return fmt.Errorf("the provided private key does not match with the stored public key on index %d", *tx.KeyIndex)
-			}
-		} else {
-			return err
+		priv, err := nacl.DecodePrivate(fmt.Sprintf("%x", tx.EncryptionPrivateKey))
+		if err != nil {
+			return fmt.Errorf("invalid encryption key on index %d: %w", *tx.KeyIndex, err)
+		}
+		pub := priv.Public().Bytes()
+		if fmt.Sprintf("%x", pub) != process.EncryptionPublicKeys[*tx.KeyIndex] {
+			log.Debugf("%x != %s", pub, process.EncryptionPublicKeys[*tx.KeyIndex])
+			return fmt.Errorf("%w: the provided private key does not match with the stored public key on index %d",
+				ErrKeyMismatch, *tx.KeyIndex)
 		}
 	}
 	if tx.RevealKey != nil {
 		commitment := snarks.Poseidon.Hash(tx.RevealKey)
 		if fmt.Sprintf("%x", commitment) != process.CommitmentKeys[*tx.KeyIndex] {
 			log.Debugf("%x != %s", commitment, process.CommitmentKeys[*tx.KeyIndex])
-			return fmt.Errorf("the provided commitment reveal key does not match with the stored on index %d", *tx.KeyIndex)
+			return fmt.Errorf("%w: the provided commitment reveal key does not match with the stored on index %d",
+				ErrKeyMismatch, *tx.KeyIndex)
 		}
-
 	}
 	return nil
 }
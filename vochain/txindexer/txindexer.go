@@ -0,0 +1,202 @@
+// Package txindexer keeps a local index of the delivered Vochain transactions,
+// so they can be looked up by hash or listed per block.
+package txindexer
+
+/*
+	TxIndexer keeps 2 different database entries (splited by key prefix)
+
+	+ Hash: key is the transaction hash. The transaction reference (JSON encoded)
+	+ Block: key is the block height and the transaction index. The transaction hash
+*/
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v2"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/db"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/vochain"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+// TxIndexer is the component which stores a reference of every transaction
+// delivered to the Vochain (including the failed ones) in a local database
+type TxIndexer struct {
+	Storage db.Database
+	txPool  []*types.TxReference
+}
+
+// NewTxIndexer returns an instance of the TxIndexer using the local storage
+// database of dbPath and integrated into the state vochain instance
+func NewTxIndexer(dbPath string, state *vochain.State) (*TxIndexer, error) {
+	t := &TxIndexer{}
+	var err error
+	t.Storage, err = db.NewBadgerDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	state.AddEventListener(t)
+	return t, nil
+}
+
+// Commit is called by the APP when a block is confirmed and included into the chain
+func (t *TxIndexer) Commit(height int64) {
+	if len(t.txPool) == 0 {
+		return
+	}
+	batch := t.Storage.NewBatch()
+	for _, tx := range t.txPool {
+		tx.Height = uint32(height)
+		txBytes, err := json.Marshal(tx)
+		if err != nil {
+			log.Errorf("cannot marshal transaction %x: (%s)", tx.Hash, err)
+			continue
+		}
+		if err := batch.Put(hashKey(tx.Hash), txBytes); err != nil {
+			log.Errorf("cannot index transaction %x: (%s)", tx.Hash, err)
+			continue
+		}
+		if err := batch.Put(blockKey(tx.Height, tx.Index), tx.Hash); err != nil {
+			log.Errorf("cannot index transaction %x: (%s)", tx.Hash, err)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Errorf("cannot write transactions of block %d: (%s)", height, err)
+	}
+	log.Debugf("indexed %d transactions from block %d", len(t.txPool), height)
+	t.txPool = nil
+}
+
+// Rollback removes the non commited pending operations
+func (t *TxIndexer) Rollback() {
+	t.txPool = nil
+}
+
+// OnTransaction stores the transaction reference until the block is committed
+func (t *TxIndexer) OnTransaction(tx *models.Tx, txHash []byte, index int32, code uint32) {
+	ref := &types.TxReference{
+		Hash:  txHash,
+		Index: index,
+		Type:  models.TxType_TX_UNKNOWN.String(),
+		Code:  code,
+	}
+	if tx != nil {
+		fillTxReference(ref, tx)
+	}
+	t.txPool = append(t.txPool, ref)
+}
+
+// OnVote does nothing
+func (t *TxIndexer) OnVote(v *models.Vote) {}
+
+// OnVoteOverwrite does nothing
+func (t *TxIndexer) OnVoteOverwrite(v, previous *models.Vote) {}
+
+// OnProcess does nothing
+func (t *TxIndexer) OnProcess(pid, eid []byte, censusRoot, censusURI string) {}
+
+// OnProcessStatusChange does nothing
+func (t *TxIndexer) OnProcessStatusChange(pid []byte, status models.ProcessStatus) {}
+
+// OnCancel does nothing
+func (t *TxIndexer) OnCancel(pid []byte) {}
+
+// OnProcessKeys does nothing
+func (t *TxIndexer) OnProcessKeys(pid []byte, pub, com string) {}
+
+// OnRevealKeys does nothing
+func (t *TxIndexer) OnRevealKeys(pid []byte, priv, rev string) {}
+
+// Transaction returns the reference of the transaction identified by hash
+func (t *TxIndexer) Transaction(hash []byte) (*types.TxReference, error) {
+	txBytes, err := t.Storage.Get(hashKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return nil, fmt.Errorf("transaction %x not found", hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tx := &types.TxReference{}
+	if err := json.Unmarshal(txBytes, tx); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal transaction %x: %w", hash, err)
+	}
+	return tx, nil
+}
+
+// BlockTransactions returns the references of the transactions of a block,
+// sorted by their position in the block
+func (t *TxIndexer) BlockTransactions(height uint32) ([]*types.TxReference, error) {
+	var txs []*types.TxReference
+	for i := int32(0); ; i++ {
+		hash, err := t.Storage.Get(blockKey(height, i))
+		if err == badger.ErrKeyNotFound {
+			return txs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		tx, err := t.Transaction(hash)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+}
+
+// fillTxReference adds the type, signer, process and entity of a transaction to its reference
+func fillTxReference(ref *types.TxReference, tx *models.Tx) {
+	var payload proto.Message
+	switch p := tx.Payload.(type) {
+	case *models.Tx_Vote:
+		payload = p.Vote
+		ref.Type = models.TxType_VOTE.String()
+		ref.ProcessID = p.Vote.GetProcessId()
+	case *models.Tx_Admin:
+		payload = p.Admin
+		ref.Type = p.Admin.GetTxtype().String()
+		ref.ProcessID = p.Admin.GetProcessId()
+	case *models.Tx_NewProcess:
+		payload = p.NewProcess
+		ref.Type = models.TxType_NEW_PROCESS.String()
+		ref.ProcessID = p.NewProcess.GetProcess().GetProcessId()
+		ref.EntityID = p.NewProcess.GetProcess().GetEntityId()
+	case *models.Tx_SetProcess:
+		payload = p.SetProcess
+		ref.Type = p.SetProcess.GetTxtype().String()
+		ref.ProcessID = p.SetProcess.GetProcessId()
+	default:
+		return
+	}
+	// anonymous votes are not signed
+	if len(tx.Signature) == 0 {
+		return
+	}
+	signedBytes, err := proto.Marshal(payload)
+	if err != nil {
+		log.Warnf("cannot marshal transaction %x: (%s)", ref.Hash, err)
+		return
+	}
+	addr, err := ethereum.AddrFromSignature(signedBytes, tx.Signature)
+	if err != nil {
+		log.Debugf("cannot extract signer of transaction %x: (%s)", ref.Hash, err)
+		return
+	}
+	ref.Signer = addr.Bytes()
+}
+
+func hashKey(hash []byte) []byte {
+	return append([]byte{types.TxIndexerHashPrefix}, hash...)
+}
+
+func blockKey(height uint32, index int32) []byte {
+	key := make([]byte, 9)
+	key[0] = types.TxIndexerBlockPrefix
+	binary.BigEndian.PutUint32(key[1:], height)
+	binary.BigEndian.PutUint32(key[5:], uint32(index))
+	return key
+}
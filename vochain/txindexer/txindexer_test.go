@@ -0,0 +1,108 @@
+package txindexer
+
+import (
+	"bytes"
+	"testing"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/log"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	"go.vocdoni.io/dvote/vochain"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestTxIndexer(t *testing.T) {
+	log.Init("info", "stdout")
+	app, err := vochain.NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ti, err := NewTxIndexer(t.TempDir(), app.State)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 1}})
+	if err := app.State.AddOracle(oracle.Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	newOracle := util.RandomBytes(20)
+	adminTx := &models.AdminTx{Txtype: models.TxType_ADD_ORACLE, Address: newOracle, Nonce: util.RandomBytes(32)}
+	adminTxBytes, err := proto.Marshal(adminTx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := &models.Tx{Payload: &models.Tx_Admin{Admin: adminTx}}
+	if tx.Signature, err = oracle.Sign(adminTxBytes); err != nil {
+		t.Fatal(err)
+	}
+	txs := make([][]byte, 3)
+	if txs[0], err = proto.Marshal(tx); err != nil {
+		t.Fatal(err)
+	}
+	// a vote for a process which does not exist
+	if txs[1], err = proto.Marshal(&models.Tx{Payload: &models.Tx_Vote{Vote: &models.VoteEnvelope{
+		ProcessId: util.RandomBytes(types.ProcessIDsize),
+	}}}); err != nil {
+		t.Fatal(err)
+	}
+	// a transaction which cannot be decoded
+	txs[2] = []byte("invalid transaction")
+
+	for _, tx := range txs {
+		app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx})
+	}
+	app.Commit()
+	// transactions of a discarded block are not indexed
+	app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: 2}})
+	app.DeliverTx(abcitypes.RequestDeliverTx{Tx: txs[1]})
+
+	refs, err := ti.BlockTransactions(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != len(txs) {
+		t.Fatalf("got %d transactions on block 1, expected %d", len(refs), len(txs))
+	}
+	expected := []struct {
+		txType string
+		code   uint32
+		signer []byte
+	}{
+		{models.TxType_ADD_ORACLE.String(), 0, oracle.Address().Bytes()},
+		{models.TxType_VOTE.String(), vochain.CodeProcessNotFound, nil},
+		{models.TxType_TX_UNKNOWN.String(), vochain.CodeTxMalformed, nil},
+	}
+	for i, e := range expected {
+		ref := refs[i]
+		hash := vochain.TxKey(txs[i])
+		if !bytes.Equal(ref.Hash, hash[:]) || ref.Height != 1 || ref.Index != int32(i) {
+			t.Errorf("transaction %d has wrong hash, height or index: %+v", i, ref)
+		}
+		if ref.Type != e.txType || ref.Code != e.code || !bytes.Equal(ref.Signer, e.signer) {
+			t.Errorf("transaction %d: got %+v, expected %+v", i, ref, e)
+		}
+	}
+
+	ref, err := ti.Transaction(refs[0].Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Type != models.TxType_ADD_ORACLE.String() {
+		t.Errorf("got transaction type %s, expected %s", ref.Type, models.TxType_ADD_ORACLE)
+	}
+	if _, err := ti.Transaction(util.RandomBytes(types.TxHashSize)); err == nil {
+		t.Errorf("unknown transaction found")
+	}
+	if refs, err := ti.BlockTransactions(2); err != nil || len(refs) != 0 {
+		t.Errorf("got %d transactions on a discarded block (%v)", len(refs), err)
+	}
+}
@@ -0,0 +1,306 @@
+package vochain
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	abcitypes "github.com/tendermint/tendermint/abci/types"
+	tmcfg "github.com/tendermint/tendermint/config"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+	tmlog "github.com/tendermint/tendermint/libs/log"
+	nm "github.com/tendermint/tendermint/node"
+	"github.com/tendermint/tendermint/p2p"
+	"github.com/tendermint/tendermint/privval"
+	tmprototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	"github.com/tendermint/tendermint/proxy"
+	tmtypes "github.com/tendermint/tendermint/types"
+	"go.vocdoni.io/dvote/config"
+	"go.vocdoni.io/dvote/crypto/ethereum"
+	"go.vocdoni.io/dvote/types"
+	"go.vocdoni.io/dvote/util"
+	models "go.vocdoni.io/proto/build/go/models"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestValidatorUpdates(t *testing.T) {
+	app, err := NewBaseApplication(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	keys := []ed25519.PubKey{
+		ed25519.GenPrivKey().PubKey().(ed25519.PubKey),
+		ed25519.GenPrivKey().PubKey().(ed25519.PubKey),
+	}
+	power := func(p uint64) *uint64 { return &p }
+	// block runs the transactions in a block and returns the validator updates,
+	// the transactions must succeed only if valid is true
+	block := func(height int64, valid bool, txs ...[]byte) []abcitypes.ValidatorUpdate {
+		t.Helper()
+		app.BeginBlock(abcitypes.RequestBeginBlock{Header: tmprototypes.Header{Height: height}})
+		if height == 1 {
+			if err := app.State.AddOracle(oracle.Address()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, tx := range txs {
+			if res := app.DeliverTx(abcitypes.RequestDeliverTx{Tx: tx}); (res.Code == 0) != valid {
+				t.Fatalf("block %d, transaction %d: got code %d (%s)", height, i, res.Code, res.Data)
+			}
+		}
+		updates := app.EndBlock(abcitypes.RequestEndBlock{Height: height}).ValidatorUpdates
+		app.Commit()
+		return updates
+	}
+
+	updates := block(1, true,
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_ADD_VALIDATOR, PublicKey: keys[0], Power: power(10)}),
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_ADD_VALIDATOR, PublicKey: keys[1], Power: power(20)}),
+	)
+	if len(updates) != 2 {
+		t.Fatalf("got %d validator updates, expected 2", len(updates))
+	}
+	for _, u := range updates {
+		expected := int64(10)
+		if bytes.Equal(u.PubKey.GetEd25519(), keys[1]) {
+			expected = 20
+		}
+		if u.Power != expected {
+			t.Errorf("validator %x has power %d, expected %d", u.PubKey.GetEd25519(), u.Power, expected)
+		}
+	}
+
+	// invalid public key, zero power and unknown validator
+	block(2, false,
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_ADD_VALIDATOR, PublicKey: util.RandomBytes(20), Power: power(10)}),
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_ADD_VALIDATOR, PublicKey: keys[0], Power: power(0)}),
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_REMOVE_VALIDATOR, Address: util.RandomBytes(20)}),
+	)
+	if updates := block(3, true); len(updates) != 0 {
+		t.Errorf("got %d validator updates on a block without changes", len(updates))
+	}
+
+	// a power change and a removal
+	updates = block(4, true,
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_ADD_VALIDATOR, PublicKey: keys[0], Power: power(15)}),
+		testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_REMOVE_VALIDATOR, Address: keys[1].Address()}),
+	)
+	if len(updates) != 2 {
+		t.Fatalf("got %d validator updates, expected 2", len(updates))
+	}
+	for _, u := range updates {
+		expected := int64(15)
+		if bytes.Equal(u.PubKey.GetEd25519(), keys[1]) {
+			expected = 0
+		}
+		if u.Power != expected {
+			t.Errorf("validator %x has power %d, expected %d", u.PubKey.GetEd25519(), u.Power, expected)
+		}
+	}
+
+	// the last validator cannot be removed
+	block(5, false, testAdminTx(t, oracle, &models.AdminTx{Txtype: models.TxType_REMOVE_VALIDATOR, Address: keys[0].Address()}))
+}
+
+// testNode is a vochain node of an in-process network
+type testNode struct {
+	app  *BaseApplication
+	node *nm.Node
+	pv   *privval.FilePV
+}
+
+// freePort returns a local TCP port not in use
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// newTestNetwork starts an in-process network of nodes connected between them,
+// the first validators nodes are the genesis validators
+func newTestNetwork(t *testing.T, nodes, validators int, oracle *ethereum.SignKeys) []*testNode {
+	cfgs := make([]*tmcfg.Config, nodes)
+	pvs := make([]*privval.FilePV, nodes)
+	peers := make([]string, nodes)
+	for i := range cfgs {
+		dir := t.TempDir()
+		cfgs[i] = tmcfg.TestConfig()
+		cfgs[i].SetRoot(dir)
+		tmcfg.EnsureRoot(dir)
+		cfgs[i].RPC.ListenAddress = ""
+		cfgs[i].P2P.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%d", freePort(t))
+		cfgs[i].P2P.AllowDuplicateIP = true
+		cfgs[i].P2P.AddrBookStrict = false
+		pvs[i] = privval.GenFilePV(cfgs[i].PrivValidatorKeyFile(), cfgs[i].PrivValidatorStateFile())
+		pvs[i].Save()
+		nodeKey, err := p2p.LoadOrGenNodeKey(cfgs[i].NodeKeyFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers[i] = p2p.IDAddressString(nodeKey.ID(), strings.TrimPrefix(cfgs[i].P2P.ListenAddress, "tcp://"))
+	}
+
+	tmConsensusParams := tmtypes.DefaultConsensusParams()
+	consensusParams := &types.ConsensusParams{
+		Block: types.BlockParams(tmConsensusParams.Block),
+		Evidence: types.EvidenceParams{
+			MaxAgeNumBlocks: tmConsensusParams.Evidence.MaxAgeNumBlocks,
+			MaxAgeDuration:  tmConsensusParams.Evidence.MaxAgeDuration,
+		},
+		Validator: types.ValidatorParams(tmConsensusParams.Validator),
+	}
+	genesisValidators := make([]privval.FilePV, validators)
+	for i := range genesisValidators {
+		genesisValidators[i] = *pvs[i]
+	}
+	genBytes, err := NewGenesis(new(config.VochainCfg), strconv.Itoa(rand.Int()),
+		consensusParams, genesisValidators, []string{oracle.AddressString()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	network := make([]*testNode, nodes)
+	for i, cfg := range cfgs {
+		if err := ioutil.WriteFile(cfg.GenesisFile(), genBytes, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var otherPeers []string
+		for j, peer := range peers {
+			if j != i {
+				otherPeers = append(otherPeers, peer)
+			}
+		}
+		cfg.P2P.PersistentPeers = strings.Join(otherPeers, ",")
+		app, err := NewBaseApplication(filepath.Join(cfg.RootDir, "vochain"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		nodeKey, err := p2p.LoadOrGenNodeKey(cfg.NodeKeyFile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		node, err := nm.NewNode(cfg, pvs[i], nodeKey, proxy.NewLocalClientCreator(app),
+			nm.DefaultGenesisDocProviderFunc(cfg), nm.DefaultDBProvider,
+			nm.DefaultMetricsProvider(cfg.Instrumentation), tmlog.NewNopLogger())
+		if err != nil {
+			t.Fatal(err)
+		}
+		app.Node = node
+		network[i] = &testNode{app: app, node: node, pv: pvs[i]}
+	}
+	for _, n := range network {
+		if err := n.node.Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		for _, n := range network {
+			if err := n.node.Stop(); err != nil {
+				t.Error(err)
+			}
+			n.node.Wait()
+		}
+	})
+	return network
+}
+
+// waitFor polls cond until it returns true, failing the test after the timeout
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+func TestValidatorSetUpdates(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the in-process network test in short mode")
+	}
+	oracle := ethereum.NewSignKeys()
+	if err := oracle.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	// three genesis validators and a full node which becomes a validator
+	network := newTestNetwork(t, 4, 3, oracle)
+
+	sendAdminTx := func(tx *models.AdminTx) {
+		t.Helper()
+		tx.Nonce = util.RandomBytes(32)
+		txBytes, err := proto.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vtx := &models.Tx{Payload: &models.Tx_Admin{Admin: tx}}
+		if vtx.Signature, err = oracle.Sign(txBytes); err != nil {
+			t.Fatal(err)
+		}
+		if txBytes, err = proto.Marshal(vtx); err != nil {
+			t.Fatal(err)
+		}
+		res, err := network[0].app.SendTX(txBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != 0 {
+			t.Fatalf("admin transaction rejected: %s", res.Data)
+		}
+	}
+	isValidator := func(n *testNode, pv *privval.FilePV) bool {
+		return n.node.ConsensusState().GetState().Validators.HasAddress(pv.Key.Address)
+	}
+	height := func() int64 { return network[0].node.BlockStore().Height() }
+
+	waitFor(t, 30*time.Second, "the first block", func() bool { return height() > 1 })
+
+	newValidator := network[3].pv
+	power := uint64(10)
+	sendAdminTx(&models.AdminTx{
+		Txtype:    models.TxType_ADD_VALIDATOR,
+		PublicKey: newValidator.Key.PubKey.Bytes(),
+		Power:     &power,
+	})
+	for i, n := range network {
+		waitFor(t, 30*time.Second, fmt.Sprintf("node %d to add the validator", i), func() bool {
+			return isValidator(n, newValidator)
+		})
+	}
+
+	removed := network[0].pv
+	sendAdminTx(&models.AdminTx{
+		Txtype:  models.TxType_REMOVE_VALIDATOR,
+		Address: removed.Key.Address.Bytes(),
+	})
+	for i, n := range network {
+		waitFor(t, 30*time.Second, fmt.Sprintf("node %d to remove the validator", i), func() bool {
+			return !isValidator(n, removed)
+		})
+	}
+	validators, err := network[3].app.State.Validators(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(validators) != 3 {
+		t.Errorf("got %d validators on the vochain state, expected 3", len(validators))
+	}
+
+	// the new validator set keeps producing blocks
+	last := height()
+	waitFor(t, 30*time.Second, "new blocks", func() bool { return height() > last+2 })
+}
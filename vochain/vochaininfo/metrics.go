@@ -54,7 +54,54 @@ func (vi *VochainInfo) registerMetrics(ma *metrics.Agent) {
 	ma.Register(VochainProcessTree)
 	ma.Register(VochainVoteTree)
 	ma.Register(VochainVoteCache)
-
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "state_pruned_versions",
+		Help:      "Number of state versions pruned",
+	}, func() float64 {
+		versions, _ := vi.vnode.State.PruningStats()
+		return float64(versions)
+	}))
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "state_pruned_bytes",
+		Help:      "Bytes reclaimed by the state pruning",
+	}, func() float64 {
+		_, bytes := vi.vnode.State.PruningStats()
+		return float64(bytes)
+	}))
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "tx_ratelimited_address",
+		Help:      "Number of transactions rejected by the per address rate limit",
+	}, func() float64 {
+		address, _ := vi.vnode.State.RateLimitStats()
+		return float64(address)
+	}))
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "tx_ratelimited_process",
+		Help:      "Number of transactions rejected by the per process rate limit",
+	}, func() float64 {
+		_, process := vi.vnode.State.RateLimitStats()
+		return float64(process)
+	}))
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "vote_cache_hits",
+		Help:      "Number of vote cache lookups which found the vote",
+	}, func() float64 {
+		hits, _ := vi.vnode.State.CacheStats()
+		return float64(hits)
+	}))
+	ma.Register(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: "vochain",
+		Name:      "vote_cache_misses",
+		Help:      "Number of vote cache lookups which did not find the vote",
+	}, func() float64 {
+		_, misses := vi.vnode.State.CacheStats()
+		return float64(misses)
+	}))
 }
 
 func (vi *VochainInfo) getMetrics() {
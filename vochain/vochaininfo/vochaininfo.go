@@ -158,8 +158,8 @@ func (vi *VochainInfo) Start(sleepSecs int64) {
 			vi.avg60 = a60
 			vi.avg360 = a360
 			vi.avg1440 = a1440
+			vi.processTreeSize = uint64(vi.vnode.State.CountProcesses(true))
 			vi.vnode.State.RLock()
-			vi.processTreeSize = vi.vnode.State.Store.Tree(vochain.ProcessTree).Count()
 			vi.voteTreeSize = vi.vnode.State.Store.Tree(vochain.VoteTree).Count()
 			vi.voteCacheSize = vi.vnode.State.CacheSize()
 			vi.vnode.State.RUnlock()